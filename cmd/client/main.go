@@ -1,53 +1,77 @@
 package main
 
 import (
-	"bufio"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net"
-	"strings"
+	"time"
 	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
 )
 
-const difficulty = 4 // Match server difficulty
+func main() {
+	addr := flag.String("addr", "wisdom-server:9000", "server address") // Server hostname in Docker
+	stream := flag.Bool("stream", false, "keep the connection open and receive quotes until the server is done")
+	collection := flag.String("collection", "", "quote collection to request, the server must serve collections")
+	seed := flag.String("seed", "", "seed selecting the quote, the server must serve seeded quotes")
+	hello := flag.Bool("hello", false, "announce the protocol version first, required by servers serving legacy clients")
+	echo := flag.Bool("echo", false, "send each solution together with its challenge, the server must require echoes")
+	retry := flag.Bool("retry", true, "retry connecting with backoff while the server is unavailable")
+	verbose := flag.Bool("verbose", false, "print the protocol version and server instance announced by the server")
+	flag.Parse()
+
+	var opts []wowclient.Option
+	if *collection != "" {
+		opts = append(opts, wowclient.WithCollection(*collection))
+	}
+	if *seed != "" {
+		opts = append(opts, wowclient.WithSeed(*seed))
+	}
+	if *hello {
+		opts = append(opts, wowclient.WithVersionHello())
+	}
+	if *retry {
+		opts = append(opts, wowclient.WithRetry(100*time.Millisecond, 2*time.Second, true))
+	}
+	if *echo {
+		opts = append(opts, wowclient.WithChallengeEcho())
+	}
+	if *verbose {
+		opts = append(opts, wowclient.WithVersionHandler(printVersion))
+	}
 
-func solvePoW(challenge string) string {
-	var solution int64 = 0
-	for {
-		hash := sha256.Sum256([]byte(fmt.Sprintf("%s%d", challenge, solution)))
-		hashStr := hex.EncodeToString(hash[:])
-		if strings.HasPrefix(hashStr, strings.Repeat("0", difficulty)) {
-			return fmt.Sprintf("%d", solution)
+	if *stream {
+		err := wowclient.Stream(context.Background(), *addr, printQuote, opts...)
+		if err != nil {
+			log.Fatalf("Failed to stream quotes: %v", err)
 		}
-		solution++
+		return
 	}
-}
 
-func main() {
-	conn, err := net.Dial("tcp", "wisdom-server:9000") // Server hostname in Docker
+	quote, err := wowclient.Fetch(context.Background(), *addr, opts...)
 	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+		log.Fatalf("Failed to fetch quote: %v", err)
 	}
-	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
-	message, _ := reader.ReadString('\n')
-	fmt.Println("Server Message:", message)
-
-	if strings.HasPrefix(message, protocol.PrefixChallenge) {
-		challenge := strings.TrimPrefix(message, protocol.PrefixChallenge)
-		challenge = strings.TrimSpace(challenge)
+	printQuote(quote)
+}
 
-		// Solve PoW
-		solution := solvePoW(challenge)
-		fmt.Fprintf(conn, "%s\n", solution)
+// printVersion prints the version announcement of the server
+func printVersion(version int, instanceID string) {
+	if instanceID == "" {
+		instanceID = "unknown"
+	}
+	log.Printf("Connected to server instance %s, protocol version %d", instanceID, version)
+}
 
-		// Read response
-		quote, _ := reader.ReadString('\n')
-		fmt.Println("Server Response:", quote)
-	} else {
-		fmt.Println("Unexpected response from server:", message)
+// printQuote prints the quote text followed by its author when known
+func printQuote(line string) {
+	quote := protocol.ParseQuote(line)
+	if quote.Author == "" {
+		fmt.Printf("\n%s\n", quote.Text)
+		return
 	}
+
+	fmt.Printf("\n%s\n  — %s\n", quote.Text, quote.Author)
 }
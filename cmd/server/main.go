@@ -1,40 +1,223 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"github.com/cloudflare/tableflip"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"word-of-wisdom/internal/app"
 	"word-of-wisdom/internal/config"
 	"word-of-wisdom/internal/pow"
 	"word-of-wisdom/internal/quotes"
 	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
 )
 
 func main() {
+	pidFile := flag.String("pid-file", "", "path to the PID file, updated on every graceful upgrade")
+	dryRun := flag.Bool("dry-run", false, "validate the config, recommend a PoW difficulty for this machine and exit")
+	targetSolveMs := flag.Int("target-solve-ms", 500, "median client solve time the --dry-run recommendation aims for")
+	flag.Parse()
+
+	log := logger.GetLogger()
+
 	cfg := config.Config{
-		Port:                ":9000",
-		MaxConnections:      100,
-		ConnectionTimeout:   2 * time.Second,
-		ShutdownTimeout:     5 * time.Second,
-		RateLimitEvery100MS: 5,
-	}
-
-	s := app.NewServer(
-		cfg,
-		logger.GetLogger(),
-		app.NewHandler(
-			quotes.NewRandomQuoteProvider([]string{
-				"We are not what we know but what we are willing to learn.",
-				"Good people are good because they've come to wisdom through failure.",
-				"Your word is a lamp for my feet, a light for my path.",
-				"The first problem for all of us, men and women, is not to learn, but to unlearn.",
-				"The only limit to our realization of tomorrow is our doubts of today.",
-				"Do what you can, with what you have, where you are.",
-				"The journey of a thousand miles begins with one step.",
-				"Opportunities don't happen. You create them.",
-			}),
-			pow.NewSHA256PoW(4),
-		),
+		Port:                     ":9000",
+		ConnectionTimeout:        2 * time.Second,
+		ShutdownTimeout:          5 * time.Second,
+		RateLimitEvery100MS:      5,
+		MaxRequestsPerConnection: 1,
+		HTTPPort:                 os.Getenv("HTTP_PORT"),
+		WebSocketPort:            os.Getenv("WEBSOCKET_PORT"),
+		AdminPort:                os.Getenv("ADMIN_PORT"),
+		CaptureFile:              os.Getenv("CAPTURE_FILE"),
+		StatsDAddr:               os.Getenv("STATSD_ADDR"),
+		StatsDPrefix:             "word_of_wisdom",
+		ProxyProtocol:            os.Getenv("PROXY_PROTOCOL") != "",
+		LineEnding:               os.Getenv("LINE_ENDING"),
+		MaintenanceMessage:       os.Getenv("MAINTENANCE_MESSAGE"),
+		ChallengeCommitment:      os.Getenv("CHALLENGE_COMMITMENT") != "",
+		InstanceID:               cmp.Or(os.Getenv("INSTANCE_ID"), config.DefaultInstanceID()),
+		SelfTestAttempts:         pow.DefaultSelfTestAttempts,
+		ChallengeSalt:            os.Getenv("CHALLENGE_SALT"),
+	}.AutoTune()
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	if *dryRun {
+		difficulty, err := pow.TuneDifficulty(context.Background(), *targetSolveMs, pow.AlgorithmSHA256)
+		if err != nil {
+			log.Fatalf("Failed to tune difficulty: %v", err)
+		}
+		log.Infof("Config is valid. Recommended difficulty for a median solve time of %dms: %d", *targetSolveMs, difficulty)
+		return
+	}
+
+	upg, err := tableflip.New(tableflip.Options{PIDFile: *pidFile})
+	if err != nil {
+		log.Fatalf("Failed to init upgrader: %v", err)
+	}
+	defer upg.Stop()
+
+	// Fork a new process on SIGUSR2, handing over the listening socket
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGUSR2)
+		for range sig {
+			log.Info("Received SIGUSR2, starting graceful upgrade...")
+			if err := upg.Upgrade(); err != nil {
+				log.Errorf("Graceful upgrade failed: %v", err)
+			}
+		}
+	}()
+
+	listener, err := upg.Listen("tcp", cfg.Port)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+
+	quoteProvider := quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{
+		{Text: "We are not what we know but what we are willing to learn.", Author: "Carl Rogers"},
+		{Text: "Good people are good because they've come to wisdom through failure.", Author: "William Saroyan"},
+		{Text: "Your word is a lamp for my feet, a light for my path.", Author: "Psalm 119:105"},
+		{Text: "The first problem for all of us, men and women, is not to learn, but to unlearn.", Author: "Gloria Steinem"},
+		{Text: "The only limit to our realization of tomorrow is our doubts of today.", Author: "Franklin D. Roosevelt"},
+		{Text: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"},
+		{Text: "The journey of a thousand miles begins with one step.", Author: "Lao Tzu"},
+		{Text: "Opportunities don't happen. You create them.", Author: "Chris Grosser"},
+	})
+	powChallenge := pow.NewSHA256PoW(
+		4,
+		pow.WithSalt(cfg.ChallengeSalt),
+		pow.WithLegacyHashing(cfg.LegacyPoWHashing),
+		pow.WithCompatDifficulty(cfg.CompatDifficulty),
 	)
 
-	s.Start()
+	if cfg.SelfTestAttempts > 0 {
+		result, err := pow.SelfTest(context.Background(), powChallenge, cfg.SelfTestAttempts)
+		if err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
+		log.Infof("PoW self-test solved difficulty %d in %d attempts (%v)", powChallenge.Difficulty(), result.Attempts, result.Duration)
+	}
+
+	var serverOpts []app.ServerOption
+	if cfg.CaptureFile != "" {
+		captureFile, err := os.OpenFile(cfg.CaptureFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			log.Fatalf("Failed to open capture file: %v", err)
+		}
+		defer captureFile.Close()
+		log.Warnf("Capturing client exchanges to %s", cfg.CaptureFile)
+		serverOpts = append(serverOpts, app.WithRecorder(app.NewRecorder(captureFile, cfg.CaptureMaxBytes)))
+	}
+	if cfg.StatsDAddr != "" {
+		serverOpts = append(serverOpts, app.WithStatsDClient(cfg.StatsDAddr, cfg.StatsDPrefix))
+	}
+
+	handlerOpts := []app.HandlerOption{
+		app.WithMaxRequestsPerConnection(cfg.MaxRequestsPerConnection),
+		app.WithRejectStub(cfg.RejectStubQuote),
+		app.WithInvalidDelay(cfg.InvalidPoWDelay, cfg.InvalidPoWMaxDelay),
+		app.WithInvalidPenalty(cfg.InvalidPoWPenaltyStep, cfg.InvalidPoWMaxPenalty),
+		app.WithGrantCooldown(cfg.QuoteCooldown),
+		app.WithFraming(protocol.LineFraming{Ending: cfg.LineTerminator()}),
+	}
+	if cfg.RequireAck {
+		handlerOpts = append(handlerOpts, app.WithRequireAck(cfg.AckTimeout))
+	}
+	if cfg.ChallengeCommitment {
+		handlerOpts = append(handlerOpts, app.WithChallengeCommitment())
+	}
+	if cfg.CompatDifficulty > 0 {
+		handlerOpts = append(handlerOpts, app.WithLegacyClients(cfg.LegacyHelloWait))
+		if cfg.WorkerPoolSize > 0 {
+			log.Warnf("Legacy clients hold one of the %d workers while waiting for their hello", cfg.WorkerPoolSize)
+		}
+	}
+	handlerOpts = append(handlerOpts, app.WithInstanceID(cfg.InstanceID))
+
+	s := app.NewServer(cfg, log, app.NewHandler(quoteProvider, powChallenge, handlerOpts...), serverOpts...)
+
+	// Serve HTTP-only clients from the same quotes and PoW
+	if cfg.HTTPPort != "" {
+		httpListener, err := upg.Listen("tcp", cfg.HTTPPort)
+		if err != nil {
+			log.Fatalf("Failed to start HTTP server: %v", err)
+		}
+		httpServer := &http.Server{
+			Handler:           s.RateLimitHTTP(app.NewHTTPHandler(quoteProvider, powChallenge, app.DefaultHTTPChallengeTTL)),
+			ReadHeaderTimeout: cfg.ConnectionTimeout,
+		}
+		go func() {
+			if err := httpServer.Serve(httpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("HTTP server failed: %v", err)
+			}
+		}()
+		defer httpServer.Close()
+	}
+
+	// Serve browser clients over WebSocket from the same quotes and PoW
+	if cfg.WebSocketPort != "" {
+		wsListener, err := upg.Listen("tcp", cfg.WebSocketPort)
+		if err != nil {
+			log.Fatalf("Failed to start WebSocket server: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("GET "+app.WebSocketPath, app.NewWebSocketHandler(quoteProvider, powChallenge, cfg.ConnectionTimeout))
+		wsServer := &http.Server{
+			Handler:           s.RateLimitHTTP(mux),
+			ReadHeaderTimeout: cfg.ConnectionTimeout,
+		}
+		go func() {
+			if err := wsServer.Serve(wsListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("WebSocket server failed: %v", err)
+			}
+		}()
+		defer wsServer.Close()
+	}
+
+	if cfg.AdminPort != "" {
+		adminListener, err := upg.Listen("tcp", cfg.AdminPort)
+		if err != nil {
+			log.Fatalf("Failed to start admin server: %v", err)
+		}
+		adminServer := &http.Server{
+			Handler:           s.AdminHandler(),
+			ReadHeaderTimeout: cfg.ConnectionTimeout,
+		}
+		go func() {
+			if err := adminServer.Serve(adminListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("Admin server failed: %v", err)
+			}
+		}()
+		defer adminServer.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Serve(listener)
+		close(done)
+	}()
+
+	if err := upg.Ready(); err != nil {
+		log.Fatalf("Failed to signal readiness: %v", err)
+	}
+
+	// Stop serving once the new process is ready or a shutdown signal arrives
+	select {
+	case <-upg.Exit():
+		log.Info("New process is ready, shutting down the old one...")
+		s.Shutdown()
+		<-done
+	case <-done:
+	}
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// verify-pow checks a challenge and solution pair without starting a server.
+// It prints VALID or INVALID and exits with 0 or 1 respectively.
+func main() {
+	algorithm := flag.String("algorithm", pow.AlgorithmSHA256, "PoW algorithm: sha256 or blake2b")
+	difficulty := flag.Int("difficulty", -1, "expected difficulty, defaults to the one embedded in the challenge")
+	challenge := flag.String("challenge", "", "challenge as sent by the server, without the CHALLENGE: prefix")
+	solution := flag.String("solution", "", "solution sent by the client")
+	salt := flag.String("salt", "", "challenge salt of the server deployment, if any")
+	legacyHashing := flag.Bool("legacy-hashing", false, "hash the challenge and solution without separator, as old clients do")
+	flag.Parse()
+
+	if *challenge == "" {
+		fmt.Fprintln(os.Stderr, "--challenge is required")
+		os.Exit(2)
+	}
+
+	embedded, err := protocol.ChallengeDifficulty(*challenge)
+	if err != nil {
+		invalid(err.Error())
+	}
+	if *difficulty >= 0 && *difficulty != embedded {
+		invalid(fmt.Sprintf("challenge difficulty is %d, expected %d", embedded, *difficulty))
+	}
+
+	p, err := pow.New(*algorithm, embedded, pow.WithSalt(*salt), pow.WithLegacyHashing(*legacyHashing))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if !p.ValidateChallenge(*challenge, *solution) {
+		invalid("solution does not meet the difficulty")
+	}
+
+	fmt.Println("VALID")
+}
+
+// invalid prints INVALID with the reason on stderr and exits with 1
+func invalid(reason string) {
+	fmt.Println("INVALID")
+	fmt.Fprintln(os.Stderr, reason)
+	os.Exit(1)
+}
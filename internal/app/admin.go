@@ -0,0 +1,183 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+	"word-of-wisdom/internal/quotes"
+)
+
+// ipStateHandler is implemented by handlers keeping per-IP state, e.g. H
+type ipStateHandler interface {
+	Failures(ip string) int
+	ResetFailures(ip string)
+	ChallengeDifficulty(ip string) int
+}
+
+// IPState is the state kept for a client IP, see Server.GetIPState
+type IPState struct {
+	IP string `json:"ip"`
+	// RateLimiterTracked reports whether the IP has a rate limiter. Untracked
+	// IPs start with a full burst.
+	RateLimiterTracked bool `json:"rate_limiter_tracked"`
+	// Tokens is the number of connections the IP may open right away
+	Tokens float64 `json:"tokens"`
+	// Failures is the number of consecutive invalid solutions, see WithInvalidDelay and WithInvalidPenalty
+	Failures int `json:"failures"`
+	// Difficulty is the difficulty of the next challenge issued to the IP
+	Difficulty int `json:"difficulty"`
+}
+
+// quoteSource is implemented by handlers exposing their quote provider, e.g. H
+type quoteSource interface {
+	QuoteProvider() quotes.QuoteProvider
+}
+
+// trackSession registers an accepted connection in StateNew
+func (s *Server) trackSession(id, ip string, start time.Time) *session {
+	sess := &session{id: id, ip: ip, start: start, events: s.events}
+	s.sessions.Store(id, sess)
+	sess.setState(StateNew)
+	return sess
+}
+
+// untrackSession forgets the connection and moves it to StateDone
+func (s *Server) untrackSession(sess *session) {
+	s.sessions.Delete(sess.id)
+	sess.setState(StateDone)
+}
+
+// Connections returns the active connections with their current state, oldest first
+func (s *Server) Connections() []ConnectionInfo {
+	var conns []ConnectionInfo
+	s.sessions.Range(func(_, value any) bool {
+		conns = append(conns, value.(*session).info())
+		return true
+	})
+
+	slices.SortFunc(conns, func(a, b ConnectionInfo) int {
+		return a.ConnectedAt.Compare(b.ConnectedAt)
+	})
+	return conns
+}
+
+// GetIPState returns the rate limiter and penalty state of a client IP
+func (s *Server) GetIPState(ip string) IPState {
+	state := IPState{IP: ip, Tokens: float64(s.config.RateLimitEvery100MS)}
+	if entry, ok := s.limiterMap.Peek(ip); ok {
+		state.RateLimiterTracked = true
+		state.Tokens = entry.limiter.TokensAt(s.now())
+	}
+	if handler, ok := s.Handler().(ipStateHandler); ok {
+		state.Failures = handler.Failures(ip)
+		state.Difficulty = handler.ChallengeDifficulty(ip)
+	}
+	return state
+}
+
+// ResetIPState drops the rate limiter and the penalties of a client IP, e.g.
+// one penalized by mistake. The subnet limiter is shared with other clients
+// and kept.
+func (s *Server) ResetIPState(ip string) {
+	s.limiterMap.Remove(ip)
+	if handler, ok := s.Handler().(ipStateHandler); ok {
+		handler.ResetFailures(ip)
+	}
+}
+
+// AdminHandler serves the admin API:
+//
+//	GET /admin/connections lists the active connections, see Connections
+//	GET /admin/stats shows the effective configuration as JSON, see config.Config.SafeCopy
+//	GET /admin/quotes.csv downloads the quotes of the handler, see quotes.WriteCsv
+//	GET /admin/ips/{ip} shows the state kept for a client IP, see GetIPState
+//	DELETE /admin/ips/{ip} resets it, see ResetIPState
+//	GET /admin/livez answers 200 while the process runs, the liveness probe
+//	GET /admin/readyz answers 200 while the server is Ready and 503 otherwise, the readiness probe
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	// A paused or draining server is alive, restarting it would drop the
+	// in-flight connections, so only readiness reflects the server state
+	mux.HandleFunc("GET /admin/livez", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("GET /admin/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !s.Ready() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("GET /admin/connections", func(w http.ResponseWriter, _ *http.Request) {
+		conns := s.Connections()
+		if conns == nil {
+			conns = []ConnectionInfo{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(conns); err != nil {
+			s.logger.Errorf("Failed to write connections: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /admin/stats", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.config.SafeCopy()); err != nil {
+			s.logger.Errorf("Failed to write config: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /admin/quotes.csv", func(w http.ResponseWriter, _ *http.Request) {
+		source, ok := s.Handler().(quoteSource)
+		if !ok {
+			http.Error(w, "handler does not expose its quotes", http.StatusNotImplemented)
+			return
+		}
+
+		// Buffer the export so a failure still gets an error status
+		var buf bytes.Buffer
+		err := quotes.WriteCsv(&buf, source.QuoteProvider())
+		if errors.Is(err, quotes.ErrNotEnumerable) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to export quotes: %v", err)
+			http.Error(w, "failed to export quotes", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="quotes.csv"`)
+		if _, err := buf.WriteTo(w); err != nil {
+			s.logger.Errorf("Failed to write quotes: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /admin/ips/{ip}", func(w http.ResponseWriter, r *http.Request) {
+		ip := net.ParseIP(r.PathValue("ip"))
+		if ip == nil {
+			http.Error(w, "invalid IP", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.GetIPState(ip.String())); err != nil {
+			s.logger.Errorf("Failed to write IP state: %v", err)
+		}
+	})
+	mux.HandleFunc("DELETE /admin/ips/{ip}", func(w http.ResponseWriter, r *http.Request) {
+		ip := net.ParseIP(r.PathValue("ip"))
+		if ip == nil {
+			http.Error(w, "invalid IP", http.StatusBadRequest)
+			return
+		}
+
+		s.ResetIPState(ip.String())
+		s.logger.Infof("Reset the state of IP %s", ip)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCaptureMaxBytes bounds a capture when no limit is given
+const DefaultCaptureMaxBytes = 10 << 20
+
+// Capture directions, see CaptureRecord.Direction
+const (
+	CaptureIn  = "in"
+	CaptureOut = "out"
+)
+
+// CaptureRecord is a single read or write of a captured connection, written
+// as a JSON line. Replaying the records of a connection in order reproduces the exchange.
+type CaptureRecord struct {
+	Time      time.Time `json:"time"`
+	Conn      uint64    `json:"conn"`
+	Client    string    `json:"client"`
+	Direction string    `json:"direction"`
+	Data      []byte    `json:"data"`
+}
+
+// Recorder writes the raw bytes exchanged on connections to w for debugging
+// client interop. Client IPs are hashed with a random per-recorder key, so a
+// client keeps its hash within a capture but cannot be recovered from it.
+// Records that would exceed the size limit are dropped.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	limit   int64
+	written int64
+	key     []byte
+	conns   atomic.Uint64
+}
+
+// NewRecorder creates a recorder writing at most limit bytes to w,
+// DefaultCaptureMaxBytes when limit is not positive
+func NewRecorder(w io.Writer, limit int64) *Recorder {
+	if limit <= 0 {
+		limit = DefaultCaptureMaxBytes
+	}
+
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	return &Recorder{w: w, limit: limit, key: key}
+}
+
+// Wrap records everything read from and written to conn
+func (r *Recorder) Wrap(conn Conn, clientIP string) Conn {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(clientIP))
+
+	return &captureConn{
+		Conn:     conn,
+		recorder: r,
+		id:       r.conns.Add(1),
+		client:   hex.EncodeToString(mac.Sum(nil)[:8]),
+	}
+}
+
+// record writes a copy of data unless the capture is full
+func (r *Recorder) record(conn uint64, client, direction string, data []byte) {
+	line, err := json.Marshal(CaptureRecord{
+		Time:      time.Now(),
+		Conn:      conn,
+		Client:    client,
+		Direction: direction,
+		Data:      data,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.written+int64(len(line)) > r.limit {
+		return
+	}
+	n, _ := r.w.Write(line)
+	r.written += int64(n)
+}
+
+// captureConn reports reads and writes to its recorder
+type captureConn struct {
+	Conn
+	recorder *Recorder
+	id       uint64
+	client   string
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.recorder.record(c.id, c.client, CaptureIn, p[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.recorder.record(c.id, c.client, CaptureOut, p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"word-of-wisdom/internal/app"
+	"word-of-wisdom/internal/quotes"
+)
+
+// readCapture decodes the recorded JSON lines
+func readCapture(t *testing.T, buf *bytes.Buffer) []app.CaptureRecord {
+	t.Helper()
+
+	var records []app.CaptureRecord
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record app.CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid capture line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Test the recorded bytes match the exchange and the client IP is hashed
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := app.NewRecorder(&buf, 0)
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"captured"}), newAcceptingPoW(t))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), recorder.Wrap(serverConn, "203.0.113.7"))
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	version, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, err = fmt.Fprintln(clientConn, "solution-1234")
+	assert.NoError(t, err)
+	quote, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+
+	var sent, received strings.Builder
+	records := readCapture(t, &buf)
+	for _, record := range records {
+		switch record.Direction {
+		case app.CaptureOut:
+			sent.Write(record.Data)
+		case app.CaptureIn:
+			received.Write(record.Data)
+		}
+		assert.Equal(t, records[0].Client, record.Client)
+		assert.Equal(t, uint64(1), record.Conn)
+	}
+
+	assert.Equal(t, version+challenge+quote, sent.String())
+	assert.Equal(t, "solution-1234\n", received.String())
+	assert.NotContains(t, records[0].Client, "203.0.113.7")
+	assert.Len(t, records[0].Client, 16)
+}
+
+// Test records beyond the size limit are dropped
+func TestRecorderLimit(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := app.NewRecorder(&buf, 300)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go func() { _, _ = io.Copy(io.Discard, clientConn) }()
+
+	conn := recorder.Wrap(serverConn, "203.0.113.7")
+	_, err := conn.Write(bytes.Repeat([]byte("a"), 10))
+	assert.NoError(t, err)
+	_, err = conn.Write(bytes.Repeat([]byte("b"), 300))
+	assert.NoError(t, err)
+
+	assert.LessOrEqual(t, buf.Len(), 300)
+	records := readCapture(t, &buf)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, bytes.Repeat([]byte("a"), 10), records[0].Data)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	// ErrDataLimitExceeded is returned by reads once a connection has sent MaxBytesPerConnection bytes
+	ErrDataLimitExceeded = errors.New("data limit exceeded")
+
+	// ErrSlowloris is returned by reads of a client trickling bytes to hold
+	// the connection, see config.Config.SlowReadStrikes
+	ErrSlowloris = errors.New("likely slowloris")
+)
+
+// byteLimitedConn fails reads once the client has sent limit bytes in total,
+// so a client streaming junk solutions cannot keep the connection busy
+type byteLimitedConn struct {
+	net.Conn
+	limit int64
+	read  int64
+}
+
+func newByteLimitedConn(conn net.Conn, limit int64) *byteLimitedConn {
+	return &byteLimitedConn{Conn: conn, limit: limit}
+}
+
+func (c *byteLimitedConn) Read(p []byte) (int, error) {
+	remaining := c.limit - c.read
+	if remaining <= 0 {
+		return 0, ErrDataLimitExceeded
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := c.Conn.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// slowReadConn detects slowloris clients sending a few bytes at a time to
+// keep the connection busy. Every read of at most maxBytes not finishing a
+// line halves the time left until the read deadline, and the strikes-th such
+// read of the same line fails with ErrSlowloris. A shortened deadline
+// expiring fails with ErrSlowloris too, until a read makes progress again.
+// Short but complete lines, e.g. an ACK, are not slow reads.
+type slowReadConn struct {
+	net.Conn
+	maxBytes  int
+	strikes   int
+	slowReads int
+	shortened bool
+	deadline  time.Time
+}
+
+func newSlowReadConn(conn net.Conn, maxBytes, strikes int, deadline time.Time) *slowReadConn {
+	return &slowReadConn{Conn: conn, maxBytes: maxBytes, strikes: strikes, deadline: deadline}
+}
+
+func (c *slowReadConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		var netErr net.Error
+		if c.shortened && errors.As(err, &netErr) && netErr.Timeout() {
+			return n, fmt.Errorf("%w: %w", ErrSlowloris, err)
+		}
+		return n, err
+	}
+	if bytes.IndexByte(p[:n], '\n') >= 0 {
+		c.slowReads = 0
+		c.shortened = false
+		return n, nil
+	}
+	if n > c.maxBytes {
+		c.shortened = false
+		return n, nil
+	}
+
+	c.slowReads++
+	c.shortened = true
+	if c.slowReads >= c.strikes {
+		return n, fmt.Errorf("%w: %d reads of at most %d bytes", ErrSlowloris, c.slowReads, c.maxBytes)
+	}
+	if !c.deadline.IsZero() {
+		c.deadline = time.Now().Add(time.Until(c.deadline) / 2)
+		_ = c.Conn.SetReadDeadline(c.deadline)
+	}
+	return n, nil
+}
+
+// SetDeadline keeps a read deadline shortened by slow reads
+func (c *slowReadConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline keeps a read deadline shortened by slow reads, so the
+// handler restoring the connection deadline cannot extend it again
+func (c *slowReadConn) SetReadDeadline(t time.Time) error {
+	if c.shortened && (t.IsZero() || t.After(c.deadline)) {
+		t = c.deadline
+	}
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
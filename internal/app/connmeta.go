@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// ConnMeta describes the connection a handler is serving
+type ConnMeta struct {
+	// IP is the client address, as reported by the PROXY protocol header if enabled
+	IP string
+	// SessionID identifies the connection in logs and in Server.Connections
+	SessionID string
+	// ConnSeq is the sequence number of the connection since the server started
+	ConnSeq uint64
+	// StartTime is when the server started handling the connection
+	StartTime time.Time
+}
+
+type connKey struct{}
+
+// WithConnMeta returns a copy of ctx carrying the connection metadata
+func WithConnMeta(ctx context.Context, meta ConnMeta) context.Context {
+	return context.WithValue(ctx, connKey{}, meta)
+}
+
+// ConnMetaFromCtx returns the connection metadata stored in ctx, if any
+func ConnMetaFromCtx(ctx context.Context) (ConnMeta, bool) {
+	meta, ok := ctx.Value(connKey{}).(ConnMeta)
+	return meta, ok
+}
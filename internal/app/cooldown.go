@@ -0,0 +1,64 @@
+package app
+
+import (
+	"sync/atomic"
+	"time"
+	"word-of-wisdom/internal/ratelimit"
+)
+
+// grantCooldown enforces a minimum interval between quote grants to an IP, so
+// a client solving every PoW still cannot harvest the quotes quickly
+type grantCooldown struct {
+	interval time.Duration
+	// grants holds the time of the last grant per IP in unix nanoseconds,
+	// bounded like the failure counts
+	grants *ratelimit.LRU[*atomic.Int64]
+	now    func() time.Time
+}
+
+func newGrantCooldown(interval time.Duration) *grantCooldown {
+	return &grantCooldown{
+		interval: interval,
+		grants:   ratelimit.NewLRU[*atomic.Int64](failureTrackedIPs),
+		now:      time.Now,
+	}
+}
+
+// reserve claims the next grant of ip, so concurrent connections of the same
+// IP cannot all pass the check before any of them is granted. It returns how
+// long ip still has to wait, zero once reserved, and a release func restoring
+// the previous grant for connections ending without quotes.
+func (c *grantCooldown) reserve(ip string) (time.Duration, func()) {
+	now := c.now()
+	last := c.entry(ip, now)
+	for {
+		prev := last.Load()
+		if wait := time.Unix(0, prev).Add(c.interval).Sub(now); wait > 0 {
+			return wait, nil
+		}
+		if last.CompareAndSwap(prev, now.UnixNano()) {
+			// A later grant or reservation is kept
+			return 0, func() { last.CompareAndSwap(now.UnixNano(), prev) }
+		}
+	}
+}
+
+// granted records a quote grant to ip, starting its cooldown
+func (c *grantCooldown) granted(ip string) {
+	now := c.now()
+	c.entry(ip, now).Store(now.UnixNano())
+}
+
+// entry returns the last grant of ip, dropping the grants whose cooldown has
+// passed once the map is full so they do not evict IPs still cooling down
+func (c *grantCooldown) entry(ip string, now time.Time) *atomic.Int64 {
+	if c.grants.Len() >= failureTrackedIPs {
+		expired := now.Add(-c.interval).UnixNano()
+		c.grants.RemoveIf(func(last *atomic.Int64) bool {
+			return last.Load() <= expired
+		})
+	}
+
+	last, _ := c.grants.GetOrAdd(ip, func() *atomic.Int64 { return new(atomic.Int64) })
+	return last
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"runtime"
+	"time"
+)
+
+// DefaultGoroutineCheckInterval is how often the goroutine count is checked
+// when Config.GoroutineCheckInterval is not set
+const DefaultGoroutineCheckInterval = 10 * time.Second
+
+// goroutineGuardLoop warns about an excessive goroutine count until shutdown
+func (s *Server) goroutineGuardLoop() {
+	if s.config.MaxGoroutines <= 0 {
+		return
+	}
+
+	interval := s.config.GoroutineCheckInterval
+	if interval <= 0 {
+		interval = DefaultGoroutineCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.CheckGoroutines()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckGoroutines logs a warning when the process runs more goroutines than
+// MaxGoroutines, which usually points to a leak, and returns the count. The
+// count includes every goroutine of the process, not only the server's.
+func (s *Server) CheckGoroutines() int {
+	count := runtime.NumGoroutine()
+	if s.config.MaxGoroutines > 0 && count > s.config.MaxGoroutines {
+		s.logger.WithField("goroutines", count).Warnf("Goroutine count exceeds the limit of %d, possible leak", s.config.MaxGoroutines)
+	}
+	return count
+}
@@ -2,78 +2,884 @@ package app
 
 import (
 	"bufio"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	"io"
+	"net"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/internal/transport"
+	"word-of-wisdom/pkg/logger"
 	"word-of-wisdom/pkg/protocol"
 )
 
-const InvalidMsg = "Invalid PoW solution"
+const (
+	InvalidMsg = "Invalid PoW solution"
+
+	// QuotesUnavailableMsg replaces the stub quote when WithRejectStub is set
+	QuotesUnavailableMsg = "Quotes are not available. Please try again later."
+
+	// DataLimitMsg closes connections exceeding MaxBytesPerConnection
+	DataLimitMsg = "Data limit exceeded."
+
+	// InvalidSeedMsg rejects hellos with a seed failing protocol.ValidSeed
+	InvalidSeedMsg = "Invalid seed"
+
+	// ChallengeMismatchMsg rejects solutions not echoing the issued challenge
+	ChallengeMismatchMsg = "Challenge mismatch"
+
+	// CooldownMsg turns away clients asking for quotes within WithGrantCooldown of the last one
+	CooldownMsg = "Quote already granted. Please come back later."
+
+	// CommitmentRequiredMsg rejects clients not committing to a challenge with WithChallengeCommitment
+	CommitmentRequiredMsg = "Commitment required"
+
+	// DefaultAckTimeout is how long WithRequireAck waits for the acknowledgement
+	DefaultAckTimeout = time.Second
+
+	// DefaultHandlerAcquireTimeout is how long HandleConnection waits for a free
+	// handler slot when the handler concurrency is limited
+	DefaultHandlerAcquireTimeout = time.Second
+
+	// DefaultLegacyHelloWait is how long WithLegacyClients waits for the
+	// client hello before treating the client as legacy. Handlers with quote
+	// collections or seeds wait as long for an optional hello.
+	DefaultLegacyHelloWait = 250 * time.Millisecond
+)
+
+var (
+	ErrHandlerBusy = errors.New("handler is busy")
+
+	// ErrResponseTooLong is returned when a client line exceeds the read limit,
+	// so a truncated prefix is never mistaken for the whole response
+	ErrResponseTooLong = errors.New("client response too long")
+
+	// ErrQuotesUnavailable is returned when the stub would be served while WithRejectStub is set
+	ErrQuotesUnavailable = errors.New("quotes are not available")
+
+	// ErrInvalidSeed is returned when the client hello carries an invalid seed
+	ErrInvalidSeed = errors.New("invalid seed")
+
+	// ErrCooldown is returned when the client asks for quotes within WithGrantCooldown of the last grant
+	ErrCooldown = errors.New("quote grant cooldown")
+
+	// ErrCommitmentRequired is returned when the client answers a challenge
+	// commitment with anything but protocol.PrefixCommit
+	ErrCommitmentRequired = errors.New("commitment required")
+
+	// ErrClientDisconnected is returned when a write fails because the client
+	// already closed the connection, an expected case rather than a server error
+	ErrClientDisconnected = errors.New("client disconnected")
+
+	// ErrQuoteDeliveryFailed is returned when the write carrying a quote fails,
+	// so the client may have received a truncated quote. It is combined with
+	// ErrClientDisconnected when the client went away mid-quote.
+	ErrQuoteDeliveryFailed = errors.New("quote delivery failed")
+)
+
+// Lifecycle events logged at debug level under the "event" field
+const (
+	EventPowIssued   = "pow_issued"
+	EventPowAccepted = "pow_accepted"
+	EventPowRejected = "pow_rejected"
+	EventQuoteServed = "quote_served"
+)
 
 type H struct {
-	quoteProvider quoteProvider
-	powChallenge  powChallenge
+	quoteProvider  quoteProvider
+	powChallenge   powChallenge
+	semaphore      chan struct{}
+	acquireTimeout time.Duration
+	maxRequests    int
+	collections    quoteCollections
+	clientQuotes   clientQuoteProvider
+	solutionQuotes clientQuoteProvider
+	seededQuotes   clientQuoteProvider
+	echoChallenge  bool
+	commitment     bool
+	rejectStub     bool
+	framing        protocol.Framing
+	tarpit         *tarpit
+	penalty        *difficultyPenalty
+	failures       *failureTracker
+	progression    *difficultyProgression
+	versionLine    string
+	ackTimeout     time.Duration
+	cooldown       *grantCooldown
+	helloWait      time.Duration
+}
+
+// difficultyProgression raises the difficulty with every round of a keep-alive connection
+type difficultyProgression struct {
+	start, max, increment int
 }
 
-func NewHandler(quoteProvider quoteProvider, powChallenge powChallenge) Handler {
-	return &H{
-		quoteProvider: quoteProvider,
-		powChallenge:  powChallenge,
+// at returns the difficulty of the given zero-based round
+func (p *difficultyProgression) at(round int) int {
+	return min(p.start+round*p.increment, p.max)
+}
+
+// HandlerOption configures optional handler behavior
+type HandlerOption func(*H)
+
+// WithHandlerConcurrency limits the number of connections handled at once,
+// independently of the server connection limit
+func WithHandlerConcurrency(n int) HandlerOption {
+	return func(h *H) {
+		h.semaphore = make(chan struct{}, n)
 	}
 }
 
-// sendMessage sends a message to the client and logs errors.
-func sendMessage(conn Conn, message string) error {
-	_, err := fmt.Fprintln(conn, message)
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+// WithHandlerAcquireTimeout overrides DefaultHandlerAcquireTimeout
+func WithHandlerAcquireTimeout(timeout time.Duration) HandlerOption {
+	return func(h *H) {
+		h.acquireTimeout = timeout
+	}
+}
+
+// WithMaxRequestsPerConnection keeps the connection open for up to n
+// challenge-quote rounds, finishing with a DONE message when n > 1
+func WithMaxRequestsPerConnection(n int) HandlerOption {
+	return func(h *H) {
+		h.maxRequests = max(n, 1)
+	}
+}
+
+// WithQuoteCollections lets clients start with a hello line naming the
+// quote collection to serve, e.g. "COLLECTION:stoicism". Any other hello,
+// including an empty line, selects the primary collection, as does no hello
+// within DefaultLegacyHelloWait, or the wait of WithLegacyClients.
+func WithQuoteCollections(collections quoteCollections) HandlerOption {
+	return func(h *H) {
+		h.collections = collections
+	}
+}
+
+// WithClientQuotes selects quotes per client IP, e.g. to walk each client
+// through the list in order with quotes.RoundRobinProvider. Collections
+// requested in the client hello take precedence.
+func WithClientQuotes(provider clientQuoteProvider) HandlerOption {
+	return func(h *H) {
+		h.clientQuotes = provider
+	}
+}
+
+// WithSolutionQuotes selects the quote by the solved challenge, keyed by
+// "challenge:solution", so the same solution always yields the same quote,
+// e.g. with quotes.DeterministicProvider. It takes precedence over the
+// other quote sources.
+func WithSolutionQuotes(provider clientQuoteProvider) HandlerOption {
+	return func(h *H) {
+		h.solutionQuotes = provider
+	}
+}
+
+// WithSeededQuotes lets clients start with a hello line, e.g.
+// "SEED:game-42", whose seed selects the quote from provider, keyed by the
+// seed, e.g. with quotes.DeterministicProvider. Clients sending the same seed
+// get the same quote, while the PoW still guards access. Any other hello,
+// including an empty line, gets a quote of the default source, as does no
+// hello within the wait of WithQuoteCollections. Seeds failing
+// protocol.ValidSeed are rejected with InvalidSeedMsg. Together with
+// WithQuoteCollections a single hello selects either a collection or a seed.
+func WithSeededQuotes(provider clientQuoteProvider) HandlerOption {
+	return func(h *H) {
+		h.seededQuotes = provider
+	}
+}
+
+// WithChallengeEcho requires clients to answer with "challenge:solution",
+// binding each solution to the challenge it solves. Solutions echoing
+// another challenge, or none, are rejected with ChallengeMismatchMsg.
+func WithChallengeEcho() HandlerOption {
+	return func(h *H) {
+		h.echoChallenge = true
+	}
+}
+
+// WithChallengeCommitment keeps the challenge, and so its difficulty, secret
+// until the client committed to solve it: the server first sends a
+// commitment to the challenge, see protocol.CommitChallenge, and reveals the
+// challenge only once the client answered with protocol.PrefixCommit. Clients
+// answering anything else are rejected with CommitmentRequiredMsg. Difficulty
+// announcements are left out, as they would reveal the difficulty early.
+// Committed clients leaving before answering count as an invalid solution for
+// WithInvalidDelay and WithInvalidPenalty, so peeking at the difficulty is not free.
+func WithChallengeCommitment() HandlerOption {
+	return func(h *H) {
+		h.commitment = true
+	}
+}
+
+// WithRejectStub treats the quotes.Stub fallback of an empty provider as a
+// not-ready condition: clients get QuotesUnavailableMsg and may retry instead
+// of receiving the placeholder.
+func WithRejectStub(reject bool) HandlerOption {
+	return func(h *H) {
+		h.rejectStub = reject
+	}
+}
+
+// WithInvalidDelay holds the response to an invalid PoW solution for delay,
+// doubling it with every consecutive failure of the client IP up to maxDelay.
+// The connection deadline and server shutdown cut the wait short.
+func WithInvalidDelay(delay, maxDelay time.Duration) HandlerOption {
+	return func(h *H) {
+		if delay > 0 {
+			h.tarpit = newTarpit(delay, maxDelay)
+		}
+	}
+}
+
+// WithInvalidPenalty raises the difficulty of the challenges issued to a
+// client IP by step for every consecutive invalid solution, up to maxPenalty
+// above the baseline. A valid solution, or ResetFailures, lifts the penalty.
+// It has no effect unless the PoW can issue challenges at a given
+// difficulty, e.g. pow.SHA256PoW.
+func WithInvalidPenalty(step, maxPenalty int) HandlerOption {
+	return func(h *H) {
+		if step > 0 && maxPenalty > 0 {
+			h.penalty = &difficultyPenalty{step: step, max: maxPenalty}
+		}
+	}
+}
+
+// WithProgressiveDifficulty issues the first challenge of a connection at
+// startDifficulty and raises the difficulty by increment after every solved
+// round up to maxDifficulty, so clients reusing a connection cannot amortize
+// the cost of the first PoW. Every challenge is preceded by a DIFFICULTY
+// message. It has no effect unless the PoW can issue challenges at a given
+// difficulty, e.g. pow.SHA256PoW.
+func WithProgressiveDifficulty(startDifficulty, maxDifficulty, increment int) HandlerOption {
+	return func(h *H) {
+		h.progression = &difficultyProgression{
+			start:     startDifficulty,
+			max:       max(startDifficulty, maxDifficulty),
+			increment: max(increment, 0),
+		}
+	}
+}
+
+// WithInstanceID names the server instance in the VERSION line that starts
+// every connection, so clients behind a load balancer can tell which instance
+// they hit. See protocol.FormatVersionLine.
+func WithInstanceID(instanceID string) HandlerOption {
+	return func(h *H) {
+		h.versionLine = protocol.FormatVersionLine(protocol.Version, instanceID)
+	}
+}
+
+// WithRequireAck waits up to timeout, DefaultAckTimeout when zero, for the
+// client to acknowledge the quotes with an ACK line before closing the
+// connection. The quotes are followed by DONE even for a single request, so
+// clients know to acknowledge them. A missing acknowledgement is logged as a
+// warning, not an error.
+func WithRequireAck(timeout time.Duration) HandlerOption {
+	return func(h *H) {
+		h.ackTimeout = cmp.Or(timeout, DefaultAckTimeout)
+	}
+}
+
+// WithGrantCooldown turns away a client IP with CooldownMsg for interval after
+// it was granted quotes, before any challenge is issued. A connection reserves
+// the grant when it starts, so concurrent connections of an IP get at most one
+// grant; the reservation is released if the connection ends without quotes.
+// It is independent of the connection rate limiter, which admits clients
+// regardless of their grants.
+func WithGrantCooldown(interval time.Duration) HandlerOption {
+	return func(h *H) {
+		if interval > 0 {
+			h.cooldown = newGrantCooldown(interval)
+		}
+	}
+}
+
+// WithLegacyClients serves clients predating the advertised difficulty. Every
+// other client must start with a hello line, e.g. protocol.FormatVersionLine
+// or a collection or seed hello, within helloWait, DefaultLegacyHelloWait when
+// zero. Clients staying silent get a challenge without difficulty, validated
+// at the compatibility difficulty of the PoW, see pow.WithCompatDifficulty,
+// and quotes of the default source, even while the regular difficulty is
+// higher. WithInvalidPenalty and WithProgressiveDifficulty raise the
+// compatibility difficulty for such clients, see
+// pow.SHA256PoW.GenerateCompatChallengeAt. It has no effect unless the PoW
+// can issue such challenges, e.g. pow.SHA256PoW.
+func WithLegacyClients(helloWait time.Duration) HandlerOption {
+	return func(h *H) {
+		h.helloWait = cmp.Or(helloWait, DefaultLegacyHelloWait)
+	}
+}
+
+// WithFraming sets how messages sent to the client are delimited, the
+// newline of the line protocol by default. Client messages are still read as lines.
+func WithFraming(framing protocol.Framing) HandlerOption {
+	return func(h *H) {
+		h.framing = framing
+	}
+}
+
+func NewHandler(quoteProvider quoteProvider, powChallenge powChallenge, opts ...HandlerOption) Handler {
+	h := &H{
+		quoteProvider:  quoteProvider,
+		powChallenge:   powChallenge,
+		acquireTimeout: DefaultHandlerAcquireTimeout,
+		maxRequests:    1,
+		framing:        protocol.LineFraming{},
+		versionLine:    protocol.FormatVersionLine(protocol.Version, ""),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.tarpit != nil || h.penalty != nil {
+		h.failures = newFailureTracker()
+	}
+	return h
+}
+
+// Failures returns the consecutive invalid solutions of the client IP that
+// escalate WithInvalidDelay and WithInvalidPenalty
+func (h *H) Failures(ip string) int {
+	if h.failures == nil {
+		return 0
+	}
+	return int(h.failures.count(ip))
+}
+
+// ResetFailures forgets the invalid solutions of the client IP, lifting its
+// delay and difficulty penalty
+func (h *H) ResetFailures(ip string) {
+	if h.failures != nil {
+		h.failures.reset(ip)
+	}
+}
+
+// ChallengeDifficulty returns the difficulty of the first challenge issued to
+// the client IP, including its penalty, or zero when the PoW does not report it
+func (h *H) ChallengeDifficulty(ip string) int {
+	if _, ok := h.powChallenge.(leveledPowChallenge); !ok {
+		return h.Difficulty()
+	}
+	return h.difficultyFor(ip, 0)
+}
+
+// QuoteProvider returns the provider of quotes served by default
+func (h *H) QuoteProvider() quotes.QuoteProvider {
+	return h.quoteProvider
+}
+
+// Difficulty returns the difficulty of new challenges, or zero when the PoW does not report it
+func (h *H) Difficulty() int {
+	if p, ok := h.powChallenge.(interface{ Difficulty() int }); ok {
+		return p.Difficulty()
+	}
+	return 0
+}
+
+// acquire takes a handler slot, giving up after the acquire timeout
+func (h *H) acquire(ctx context.Context) error {
+	if h.semaphore == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(h.acquireTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.semaphore <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrHandlerBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a handler slot taken by acquire
+func (h *H) release() {
+	if h.semaphore != nil {
+		<-h.semaphore
+	}
+}
+
+// sendMessage sends a message to the client, delimited per the handler framing.
+func (h *H) sendMessage(conn Conn, message string) error {
+	if err := h.framing.WriteMessage(conn, message); err != nil {
+		return fmt.Errorf("failed to send message: %w", classifyWriteError(err))
 	}
 
 	return nil
 }
 
+// flushMessages sends the buffered messages of the current protocol turn in a single write.
+func flushMessages(conn *transport.BufferedConn) error {
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("failed to send message: %w", classifyWriteError(err))
+	}
+
+	return nil
+}
+
+// classifyWriteError marks errors of writes to a connection closed by the client with ErrClientDisconnected
+func classifyWriteError(err error) error {
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("%w: %w", ErrClientDisconnected, err)
+	}
+	return err
+}
+
+// quoteDeliveryError marks errors of writes carrying a buffered quote with ErrQuoteDeliveryFailed
+func quoteDeliveryError(err error) error {
+	return fmt.Errorf("%w: %w", ErrQuoteDeliveryFailed, err)
+}
+
 // HandleConnection manages a single client connection and performs PoW validation.
-func (h *H) HandleConnection(conn Conn) error {
+func (h *H) HandleConnection(ctx context.Context, rawConn Conn) error {
+	if err := h.acquire(ctx); err != nil {
+		return err
+	}
+	defer h.release()
+
+	log := logger.FromContext(ctx)
+	stats := statsFromContext(ctx)
+
+	// Buffer writes so each protocol turn reaches the client in a single write
+	conn := transport.NewBufferedConn(rawConn)
+
+	// Legacy clients, and clients not asking for a collection or seed, wait
+	// for the challenge without sending a hello
+	var input io.Reader = rawConn
+	var legacy, greeted bool
+	_, compat := h.powChallenge.(compatPowChallenge)
+	compat = compat && h.helloWait > 0
+	if compat || h.collections != nil || h.seededQuotes != nil {
+		buffered := bufio.NewReader(rawConn)
+		silent := h.awaitHello(ctx, rawConn, buffered)
+		legacy = compat && silent
+		greeted = !silent
+		input = buffered
+	}
+	if legacy {
+		log = log.WithField("legacy_client", true)
+	}
+
+	// A single scanner for the connection lifetime keeps pipelined messages
+	// buffered between rounds instead of dropping them with a per-read reader
+	reader := protocol.ScannerWithLimit(input, maxResponseSize)
+
+	// The announcement is flushed together with the first challenge
+	if !legacy {
+		if err := h.sendMessage(conn, h.versionLine); err != nil {
+			return fmt.Errorf("failed to send version: %w", err)
+		}
+	}
+
+	// grant starts the cooldown of the client IP once quotes were delivered
+	grant := func() {}
+	if h.cooldown != nil {
+		clientIP := remoteIP(rawConn)
+		wait, release := h.cooldown.reserve(clientIP)
+		if wait > 0 {
+			log.Debugf("Quote cooldown for another %v", wait)
+			return errors.Join(ErrCooldown, h.sendError(conn, CooldownMsg))
+		}
+
+		var granted bool
+		grant = func() {
+			granted = true
+			h.cooldown.granted(clientIP)
+		}
+		defer func() {
+			if !granted {
+				release()
+			}
+		}()
+	}
+
+	getQuote := func(context.Context) (protocol.QuoteMessage, error) {
+		return h.quoteProvider.GetQuote(), nil
+	}
+	if p, ok := h.quoteProvider.(contextQuoteProvider); ok {
+		getQuote = p.Quote
+	}
+	if h.clientQuotes != nil {
+		clientIP := remoteIP(rawConn)
+		getQuote = func(context.Context) (protocol.QuoteMessage, error) {
+			return h.clientQuotes.GetQuoteFor(clientIP), nil
+		}
+	}
+	if greeted {
+		hello, err := readClientResponse(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read client hello: %w", err)
+		}
+
+		if h.collections != nil {
+			// Hellos not naming a collection select the primary one
+			collection, ok := strings.CutPrefix(hello, protocol.PrefixCollection)
+			if !ok {
+				collection = ""
+			}
+			log = log.WithField("collection", collection)
+			getQuote = func(context.Context) (protocol.QuoteMessage, error) {
+				return h.collections.GetCollectionQuote(collection), nil
+			}
+		}
+
+		if seed, ok := strings.CutPrefix(hello, protocol.PrefixSeed); ok && h.seededQuotes != nil {
+			if !protocol.ValidSeed(seed) {
+				return errors.Join(ErrInvalidSeed, h.sendError(conn, InvalidSeedMsg))
+			}
+			log = log.WithField("seed", seed)
+			getQuote = func(context.Context) (protocol.QuoteMessage, error) {
+				return h.seededQuotes.GetQuoteFor(seed), nil
+			}
+		}
+	}
+
+	for round := 0; round < h.maxRequests; round++ {
+		served, err := h.serveRound(ctx, log, stats, conn, reader, round, legacy, getQuote)
+		if errors.Is(err, ErrDataLimitExceeded) {
+			return errors.Join(err, h.sendError(conn, DataLimitMsg))
+		}
+		if err != nil {
+			return err
+		}
+		if !served {
+			// The quotes of the previous rounds were flushed with this challenge
+			if round > 0 {
+				grant()
+			}
+			return nil
+		}
+	}
+
+	// Tell streaming clients that no more challenges will follow, and clients
+	// of a server requiring acknowledgements that they should send one
+	if h.maxRequests > 1 || h.ackTimeout > 0 {
+		if err := h.sendMessage(conn, protocol.PrefixDone); err != nil {
+			return fmt.Errorf("failed to send done: %w", quoteDeliveryError(err))
+		}
+	}
+
+	if err := flushMessages(conn); err != nil {
+		return fmt.Errorf("failed to send quote: %w", quoteDeliveryError(err))
+	}
+	stats.flushed()
+	grant()
+
+	if h.ackTimeout > 0 {
+		h.awaitAck(ctx, log, rawConn, reader)
+	}
+
+	return nil
+}
+
+// awaitHello reports whether the client stays silent for the hello wait, as
+// legacy clients and clients without a collection or seed do. Nothing is
+// consumed from reader.
+func (h *H) awaitHello(ctx context.Context, rawConn Conn, reader *bufio.Reader) bool {
+	deadline, _ := ctx.Deadline()
+	wait := time.Now().Add(cmp.Or(h.helloWait, DefaultLegacyHelloWait))
+	if !deadline.IsZero() {
+		wait = earliest(wait, deadline)
+	}
+	if err := rawConn.SetReadDeadline(wait); err != nil {
+		return false
+	}
+	_, err := reader.Peek(1)
+	// Restore the connection deadline, none without one in ctx
+	_ = rawConn.SetReadDeadline(deadline)
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// awaitAck waits for the client to acknowledge the quotes, within the ack
+// timeout and the connection deadline. The quotes are already delivered, so
+// a missing acknowledgement is only logged.
+func (h *H) awaitAck(ctx context.Context, log *logrus.Entry, rawConn Conn, reader *bufio.Scanner) {
+	deadline := time.Now().Add(h.ackTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := rawConn.SetReadDeadline(deadline); err != nil {
+		log.Warnf("Quote not acknowledged: %v", err)
+		return
+	}
+
+	line, err := readClientResponse(reader)
+	switch {
+	case err != nil:
+		log.Warnf("Quote not acknowledged: %v", err)
+	case line != protocol.PrefixAck:
+		log.Warnf("Quote not acknowledged, got %q", line)
+	default:
+		log.Debug("Quote acknowledged")
+	}
+}
+
+// serveRound performs a single challenge-response exchange. The quote is left
+// buffered so it is sent together with the next challenge or the final flush.
+// It reports whether the client solved the challenge. Context-aware PoW
+// validation and quote providers are bound by the deadline of ctx.
+func (h *H) serveRound(ctx context.Context, log *logrus.Entry, stats *connStats, conn *transport.BufferedConn, reader *bufio.Scanner, round int, legacy bool, getQuote func(context.Context) (protocol.QuoteMessage, error)) (bool, error) {
 	// Generate and send PoW challenge
-	challenge := h.powChallenge.GenerateChallenge()
-	if err := sendMessage(conn, protocol.PrefixChallenge+challenge); err != nil {
-		return fmt.Errorf("failed to send challenge: %w", err)
+	stats.setState(StateChallenging)
+	challenge, err := h.generateChallenge(conn, round, legacy)
+	if err != nil {
+		return false, err
 	}
+	commit := h.commitment && !legacy
+	if commit {
+		if committed, err := h.awaitCommit(log, stats, conn, reader, challenge, round); !committed {
+			return false, err
+		}
+	}
+	if err := h.sendMessage(conn, protocol.PrefixChallenge+challenge); err != nil {
+		return false, fmt.Errorf("failed to send challenge: %w", err)
+	}
+	if err := flushMessages(conn); err != nil {
+		// The quote of the previous round is flushed together with the
+		// challenge, or with the commitment if any
+		if round > 0 && !commit {
+			err = quoteDeliveryError(err)
+		}
+		return false, fmt.Errorf("failed to send challenge: %w", err)
+	}
+	stats.flushed()
+	stats.setState(StateSolving)
+	log.WithFields(logrus.Fields{"event": EventPowIssued, "challenge": challenge}).Debug("PoW challenge issued")
 
-	// Read and validate client response
-	solution, err := readClientResponse(conn)
+	// Read and validate client response. Clients committed to the challenge
+	// ended the session already, or they abandon it.
+	solution, err := readClientResponse(reader)
+	if !commit && sessionEnded(round, solution, err) {
+		log.Debug("Client ended the session")
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read client response: %w", err)
+		if commit && h.failures != nil {
+			log.WithFields(logrus.Fields{"event": EventPowRejected, "challenge": challenge}).Debug("Committed PoW challenge abandoned")
+			h.failures.add(remoteIP(conn))
+		}
+		return false, fmt.Errorf("failed to read client response: %w", err)
 	}
 
-	// Validate Proof of Work (PoW)
-	if !h.powChallenge.ValidateChallenge(challenge, solution) {
-		if err := sendMessage(conn, protocol.PrefixError+InvalidMsg); err != nil {
-			return fmt.Errorf("failed to send validate: %w", err)
+	if h.echoChallenge {
+		echoed, ok := strings.CutPrefix(solution, challenge+protocol.ChallengeSeparator)
+		if !ok {
+			log.WithFields(logrus.Fields{"event": EventPowRejected, "challenge": challenge}).Debug("PoW solution does not echo the challenge")
+			stats.rejected()
+			return false, h.sendError(conn, ChallengeMismatchMsg)
 		}
+		solution = echoed
+	}
 
-		return nil
+	// Validate Proof of Work (PoW)
+	valid, err := h.validate(ctx, challenge, solution)
+	if err != nil {
+		return false, fmt.Errorf("failed to validate solution: %w", err)
+	}
+	if !valid {
+		log.WithFields(logrus.Fields{"event": EventPowRejected, "challenge": challenge}).Debug("PoW solution rejected")
+		stats.rejected()
+		if h.failures != nil {
+			failures := h.failures.add(remoteIP(conn))
+			if h.tarpit != nil {
+				if err := h.tarpit.wait(ctx, h.tarpit.delay(failures)); err != nil {
+					return false, fmt.Errorf("invalid solution delay interrupted: %w", err)
+				}
+			}
+		}
+		return false, h.sendError(conn, InvalidMsg)
+	}
+	if h.failures != nil {
+		h.failures.reset(remoteIP(conn))
 	}
+	log.WithFields(logrus.Fields{"event": EventPowAccepted, "challenge": challenge}).Debug("PoW solution accepted")
+	stats.solved()
+	stats.setState(StateDelivering)
 
 	// Send quote if PoW is valid
-	quote := h.quoteProvider.GetQuote()
-	if err := sendMessage(conn, protocol.PrefixQuote+quote); err != nil {
-		return fmt.Errorf("failed to send quote: %w", err)
+	if h.solutionQuotes != nil {
+		key := challenge + protocol.ChallengeSeparator + solution
+		getQuote = func(context.Context) (protocol.QuoteMessage, error) {
+			return h.solutionQuotes.GetQuoteFor(key), nil
+		}
+	}
+	quote, err := getQuote(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get quote: %w", err)
 	}
+	if h.rejectStub && quote.Text == quotes.Stub {
+		if err := h.sendError(conn, QuotesUnavailableMsg); err != nil {
+			return false, err
+		}
+		return false, ErrQuotesUnavailable
+	}
+	if err := h.sendMessage(conn, protocol.PrefixQuote+quote.String()); err != nil {
+		return false, fmt.Errorf("failed to send quote: %w", quoteDeliveryError(err))
+	}
+	stats.quoteBuffered()
+	log.WithField("event", EventQuoteServed).Debug("Quote served")
 
-	return nil
+	return true, nil
+}
+
+// awaitCommit sends the commitment to the challenge and waits for the client
+// to commit to solving it, before the challenge is revealed. It reports
+// whether the client committed; a keep-alive client may end the session instead.
+func (h *H) awaitCommit(log *logrus.Entry, stats *connStats, conn *transport.BufferedConn, reader *bufio.Scanner, challenge string, round int) (bool, error) {
+	if err := h.sendMessage(conn, protocol.PrefixCommitment+protocol.CommitChallenge(challenge)); err != nil {
+		return false, fmt.Errorf("failed to send commitment: %w", err)
+	}
+	if err := flushMessages(conn); err != nil {
+		// The quote of the previous round is flushed together with the commitment
+		if round > 0 {
+			err = quoteDeliveryError(err)
+		}
+		return false, fmt.Errorf("failed to send commitment: %w", err)
+	}
+	stats.flushed()
+
+	line, err := readClientResponse(reader)
+	if sessionEnded(round, line, err) {
+		log.Debug("Client ended the session")
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read commitment: %w", err)
+	}
+	if line != protocol.PrefixCommit {
+		log.Debugf("Client did not commit to the challenge, got %q", line)
+		return false, errors.Join(ErrCommitmentRequired, h.sendError(conn, CommitmentRequiredMsg))
+	}
+	return true, nil
+}
+
+// sessionEnded reports whether a keep-alive client closed the connection or
+// acknowledged its quotes instead of answering the challenge of a later
+// round, ending the session cleanly rather than failing the challenge
+func sessionEnded(round int, line string, err error) bool {
+	if round == 0 {
+		return false
+	}
+	return errors.Is(err, io.EOF) || (err == nil && line == protocol.PrefixAck)
+}
+
+// generateChallenge issues the challenge of the given zero-based round. A
+// difficulty chosen per connection, with WithProgressiveDifficulty or a
+// WithInvalidPenalty penalty, is announced to the client first, unless
+// WithChallengeCommitment keeps it secret. Legacy clients always get a
+// compatibility challenge, see WithLegacyClients.
+func (h *H) generateChallenge(conn *transport.BufferedConn, round int, legacy bool) (string, error) {
+	if p, ok := h.powChallenge.(compatPowChallenge); legacy && ok {
+		return p.GenerateCompatChallengeAt(h.compatDifficultyFor(remoteIP(conn), round, p.CompatDifficulty())), nil
+	}
+
+	p, ok := h.powChallenge.(leveledPowChallenge)
+	if !ok {
+		return h.powChallenge.GenerateChallenge(), nil
+	}
+
+	ip := remoteIP(conn)
+	if h.progression == nil && h.penaltyFor(ip) == 0 {
+		return h.powChallenge.GenerateChallenge(), nil
+	}
+
+	difficulty := h.difficultyFor(ip, round)
+	if h.commitment {
+		return p.GenerateChallengeAt(difficulty), nil
+	}
+	if err := h.sendMessage(conn, protocol.PrefixDifficulty+strconv.Itoa(difficulty)); err != nil {
+		return "", fmt.Errorf("failed to send difficulty: %w", err)
+	}
+	return p.GenerateChallengeAt(difficulty), nil
+}
+
+// difficultyFor returns the difficulty of the given zero-based round for the
+// client IP, progressing from the start of WithProgressiveDifficulty or the
+// PoW difficulty, plus the penalty of the IP
+func (h *H) difficultyFor(ip string, round int) int {
+	difficulty := h.Difficulty()
+	if h.progression != nil {
+		difficulty = h.progression.at(round)
+	}
+	return difficulty + h.penaltyFor(ip)
+}
+
+// compatDifficultyFor returns the difficulty of the given zero-based round for
+// a legacy client IP: the compat difficulty plus the progression since the
+// first round and the penalty of the IP, so staying silent dodges neither
+func (h *H) compatDifficultyFor(ip string, round, compat int) int {
+	difficulty := compat
+	if h.progression != nil {
+		difficulty += h.progression.at(round) - h.progression.start
+	}
+	return difficulty + h.penaltyFor(ip)
+}
+
+// penaltyFor returns the difficulty added for the failures of the client IP
+func (h *H) penaltyFor(ip string) int {
+	if h.penalty == nil {
+		return 0
+	}
+	return h.penalty.extra(h.failures.count(ip))
 }
 
-// readClientResponse reads the client’s PoW solution from the connection
-func readClientResponse(conn Conn) (string, error) {
-	const maxReadSize = 1024
+// validate checks the solution, within the deadline of ctx when the PoW supports it
+func (h *H) validate(ctx context.Context, challenge, solution string) (bool, error) {
+	if p, ok := h.powChallenge.(contextPowChallenge); ok {
+		return p.ValidateChallengeContext(ctx, challenge, solution)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return h.powChallenge.ValidateChallenge(challenge, solution), nil
+}
 
-	limitedReader := io.LimitedReader{R: conn, N: maxReadSize}
+// remoteIP returns the client IP without the port, falling back to the full address
+func remoteIP(conn Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
 
-	reader := bufio.NewReader(&limitedReader)
-	solution, err := reader.ReadString('\n')
+// sendError tells the client why the exchange ends
+func (h *H) sendError(conn *transport.BufferedConn, reason string) error {
+	if err := h.sendMessage(conn, protocol.PrefixError+reason); err != nil {
+		return fmt.Errorf("failed to send error: %w", err)
+	}
+	if err := flushMessages(conn); err != nil {
+		return fmt.Errorf("failed to send error: %w", err)
+	}
+
+	return nil
+}
+
+// maxResponseSize limits every client message on its own
+const maxResponseSize = 1024
+
+// readClientResponse reads the client’s PoW solution from the connection.
+// The line may arrive in several segments; reading continues until the
+// newline, the connection deadline or the size limit. The size limit applies
+// to every message on its own, bytes of the next pipelined message stay buffered.
+func readClientResponse(reader *bufio.Scanner) (string, error) {
+	msg, err := protocol.DecodeScanner(reader)
+	if errors.Is(err, protocol.ErrMessageTooLarge) {
+		return "", fmt.Errorf("%w: %w", ErrResponseTooLong, err)
+	}
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(solution), nil
+	return strings.TrimSpace(msg.String()), nil
 }
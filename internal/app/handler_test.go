@@ -1,17 +1,41 @@
 package app_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"io"
+	"net"
+	"os"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 	"word-of-wisdom/internal/app"
 	"word-of-wisdom/internal/app/mocks"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
 )
 
+// writeAll reports the whole buffer as written, as a real connection does
+func writeAll(p []byte) (int, error) {
+	return len(p), nil
+}
+
 func TestHandleConnection_ValidPoW(t *testing.T) {
-	quote := "The only limit to our realization of tomorrow is our doubts of today."
+	quote := protocol.QuoteMessage{Text: "The only limit to our realization of tomorrow is our doubts of today."}
 
 	// Prepare mocks
 	mockQuoteProvider := mocks.NewQuoteProvider(t)
@@ -36,14 +60,14 @@ func TestHandleConnection_ValidPoW(t *testing.T) {
 
 	mockConn.EXPECT().
 		Write(mock.Anything).
-		Return(0, nil)
+		RunAndReturn(writeAll)
 
 	mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
 		copy(p, "solution-1234\n")
 		return len("solution-1234\n")
 	}, nil)
 
-	err := handler.HandleConnection(mockConn)
+	err := handler.HandleConnection(context.Background(), mockConn)
 	assert.NoError(t, err)
 
 	// Verify PoW validation was called
@@ -72,14 +96,14 @@ func TestHandleConnection_InvalidPoW(t *testing.T) {
 
 	mockConn.EXPECT().
 		Write(mock.Anything).
-		Return(0, nil)
+		RunAndReturn(writeAll)
 
 	mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
 		copy(p, "invalid-solution\n")
 		return len("invalid-solution\n")
 	}, nil)
 
-	err := handler.HandleConnection(mockConn)
+	err := handler.HandleConnection(context.Background(), mockConn)
 	assert.NoError(t, err)
 
 	// Verify PoW validation was called
@@ -108,11 +132,40 @@ func TestHandleConnection_SendMessageError(t *testing.T) {
 		Return(0, fmt.Errorf("write error"))
 
 	// Test send message error
-	err := handler.HandleConnection(mockConn)
+	err := handler.HandleConnection(context.Background(), mockConn)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to send message")
 }
 
+// Test writes to a connection closed by the client are classified as disconnects
+func TestHandleConnection_ClientDisconnected(t *testing.T) {
+	tests := []struct {
+		name         string
+		writeErr     error
+		disconnected bool
+	}{
+		{name: "closed", writeErr: net.ErrClosed, disconnected: true},
+		{name: "broken pipe", writeErr: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, disconnected: true},
+		{name: "reset", writeErr: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.ECONNRESET)}, disconnected: true},
+		{name: "other", writeErr: fmt.Errorf("write error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPoW := mocks.NewPowChallenge(t)
+			mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234")
+			handler := app.NewHandler(mocks.NewQuoteProvider(t), mockPoW)
+
+			mockConn := mocks.NewConn(t)
+			mockConn.EXPECT().Write(mock.Anything).Return(0, tt.writeErr)
+
+			err := handler.HandleConnection(context.Background(), mockConn)
+			assert.ErrorIs(t, err, tt.writeErr)
+			assert.Equal(t, tt.disconnected, errors.Is(err, app.ErrClientDisconnected))
+		})
+	}
+}
+
 // Test empty client response (edge case)
 func TestHandleConnection_EmptyResponse(t *testing.T) {
 	mockQuoteProvider := mocks.NewQuoteProvider(t)
@@ -131,14 +184,14 @@ func TestHandleConnection_EmptyResponse(t *testing.T) {
 
 	mockConn.EXPECT().
 		Write(mock.Anything).
-		Return(0, nil)
+		RunAndReturn(writeAll)
 
 	mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
 		copy(p, "\n")
 		return len("\n")
 	}, nil)
 
-	err := handler.HandleConnection(mockConn)
+	err := handler.HandleConnection(context.Background(), mockConn)
 	assert.NoError(t, err)
 
 	mockConn.AssertExpectations(t)
@@ -161,13 +214,13 @@ func TestHandleConnection_ReadError(t *testing.T) {
 
 	mockConn.EXPECT().
 		Write(mock.Anything).
-		Return(0, nil)
+		RunAndReturn(writeAll)
 
 	mockConn.EXPECT().
 		Read(mock.Anything).
 		Return(0, fmt.Errorf("read error"))
 
-	err := handler.HandleConnection(mockConn)
+	err := handler.HandleConnection(context.Background(), mockConn)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to read client response")
 
@@ -177,7 +230,7 @@ func TestHandleConnection_ReadError(t *testing.T) {
 
 // Test concurrent clients
 func TestHandleConnection_ConcurrentClients(t *testing.T) {
-	quote := "The only limit to our realization of tomorrow is our doubts of today."
+	quote := protocol.QuoteMessage{Text: "The only limit to our realization of tomorrow is our doubts of today."}
 
 	// Prepare mocks
 	mockQuoteProvider := mocks.NewQuoteProvider(t)
@@ -207,14 +260,14 @@ func TestHandleConnection_ConcurrentClients(t *testing.T) {
 			mockConn := mocks.NewConn(t)
 			mockConn.EXPECT().
 				Write(mock.Anything).
-				Return(0, nil)
+				RunAndReturn(writeAll)
 
 			mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
 				copy(p, "solution-1234\n")
 				return len("solution-1234\n")
 			}, nil)
 
-			err := handler.HandleConnection(mockConn)
+			err := handler.HandleConnection(context.Background(), mockConn)
 			assert.NoError(t, err)
 		}()
 	}
@@ -223,3 +276,1085 @@ func TestHandleConnection_ConcurrentClients(t *testing.T) {
 	mockPoW.AssertExpectations(t)
 	mockQuoteProvider.AssertExpectations(t)
 }
+
+// newJSONLogEntry returns an entry writing JSON lines into buf with a fixed correlation id
+func newJSONLogEntry(buf *bytes.Buffer) *logrus.Entry {
+	log := logrus.New()
+	log.SetOutput(buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.DebugLevel)
+
+	return log.WithField("session_id", "session-1")
+}
+
+// collectEvents parses JSON log lines and returns the values of the "event" field in order
+func collectEvents(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("Invalid JSON log line %q: %v", line, err)
+		}
+		assert.Equal(t, "session-1", fields["session_id"], "Every event should carry the correlation id")
+		if event, ok := fields["event"].(string); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Test lifecycle events emitted over a full successful handshake
+func TestHandleConnection_LifecycleEvents(t *testing.T) {
+	mockQuoteProvider := mocks.NewQuoteProvider(t)
+	mockQuoteProvider.EXPECT().
+		GetQuote().
+		Return(protocol.QuoteMessage{Text: "quote"})
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234")
+	mockPoW.EXPECT().
+		ValidateChallenge("challenge-1234", "solution-1234").
+		Return(true)
+
+	handler := app.NewHandler(mockQuoteProvider, mockPoW)
+
+	mockConn := mocks.NewConn(t)
+	mockConn.EXPECT().
+		Write(mock.Anything).
+		RunAndReturn(writeAll)
+	mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
+		copy(p, "solution-1234\n")
+		return len("solution-1234\n")
+	}, nil)
+
+	var buf bytes.Buffer
+	ctx := logger.NewContext(context.Background(), newJSONLogEntry(&buf))
+
+	err := handler.HandleConnection(ctx, mockConn)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{app.EventPowIssued, app.EventPowAccepted, app.EventQuoteServed}, collectEvents(t, &buf))
+}
+
+// Test lifecycle events emitted when the solution is rejected
+func TestHandleConnection_LifecycleEventsRejected(t *testing.T) {
+	mockQuoteProvider := mocks.NewQuoteProvider(t)
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234")
+	mockPoW.EXPECT().
+		ValidateChallenge("challenge-1234", "invalid-solution").
+		Return(false)
+
+	handler := app.NewHandler(mockQuoteProvider, mockPoW)
+
+	mockConn := mocks.NewConn(t)
+	mockConn.EXPECT().
+		Write(mock.Anything).
+		RunAndReturn(writeAll)
+	mockConn.On("Read", mock.Anything).Return(func(p []byte) int {
+		copy(p, "invalid-solution\n")
+		return len("invalid-solution\n")
+	}, nil)
+
+	var buf bytes.Buffer
+	ctx := logger.NewContext(context.Background(), newJSONLogEntry(&buf))
+
+	err := handler.HandleConnection(ctx, mockConn)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{app.EventPowIssued, app.EventPowRejected}, collectEvents(t, &buf))
+}
+
+// Test that a saturated handler rejects new connections with ErrHandlerBusy
+func TestHandleConnection_HandlerBusy(t *testing.T) {
+	mockQuoteProvider := mocks.NewQuoteProvider(t)
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234").
+		Once()
+
+	handler := app.NewHandler(mockQuoteProvider, mockPoW,
+		app.WithHandlerConcurrency(1),
+		app.WithHandlerAcquireTimeout(50*time.Millisecond),
+	)
+
+	// Occupy the only slot: the challenge write blocks until the client reads
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	// No calls are expected on the connection of a rejected client
+	err := handler.HandleConnection(context.Background(), mocks.NewConn(t))
+	assert.ErrorIs(t, err, app.ErrHandlerBusy)
+
+	clientConn.Close()
+	assert.Error(t, <-done)
+}
+
+// pipeExchange runs the handler on one end of a pipe and plays a client sending
+// the given hello line on the other. It returns the final server message.
+func pipeExchange(t *testing.T, handler app.Handler, hello string) string {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+
+	_, err := fmt.Fprintln(clientConn, hello)
+	assert.NoError(t, err)
+	readVersion(t, reader)
+
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+
+	_, err = fmt.Fprintln(clientConn, "solution-1234")
+	assert.NoError(t, err)
+
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+
+	return strings.TrimSpace(response)
+}
+
+// readVersion consumes the version announcement starting every connection
+func readVersion(t *testing.T, reader *bufio.Reader) {
+	t.Helper()
+
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, protocol.PrefixVersion), "Expected the version announcement, got %q", line)
+}
+
+// newAcceptingPoW returns a PoW mock accepting the fixed solution
+func newAcceptingPoW(t *testing.T) *mocks.PowChallenge {
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234")
+	mockPoW.EXPECT().
+		ValidateChallenge("challenge-1234", "solution-1234").
+		Return(true)
+	return mockPoW
+}
+
+// Test quote collections selected by the client hello
+func TestHandleConnection_QuoteCollections(t *testing.T) {
+	primary := quotes.NewRandomQuoteProvider([]string{"primary"})
+	registry := quotes.NewRegistry(primary)
+	registry.Register("stoicism", quotes.NewRandomQuoteProvider([]string{"stoic"}))
+
+	cases := []struct {
+		name     string
+		hello    string
+		expected string
+	}{
+		{"valid collection", protocol.PrefixCollection + "stoicism", "stoic"},
+		{"unknown collection", protocol.PrefixCollection + "unknown", "primary"},
+		{"no request", "", "primary"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := app.NewHandler(primary, newAcceptingPoW(t), app.WithQuoteCollections(registry))
+
+			response := pipeExchange(t, handler, c.hello)
+			assert.Equal(t, protocol.PrefixQuote+c.expected, response)
+		})
+	}
+}
+
+// Test that clients sending no hello get a quote of the primary collection after the hello wait
+func TestHandleConnection_QuoteCollectionsWithoutHello(t *testing.T) {
+	primary := quotes.NewRandomQuoteProvider([]string{"primary"})
+	registry := quotes.NewRegistry(primary)
+	registry.Register("stoicism", quotes.NewRandomQuoteProvider([]string{"stoic"}))
+	handler := app.NewHandler(primary, newAcceptingPoW(t), app.WithQuoteCollections(registry))
+
+	serverConn, clientConn := net.Pipe()
+	assert.NoError(t, clientConn.SetDeadline(time.Now().Add(2*time.Second)))
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err, "The challenge should follow the hello wait")
+	assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+
+	_, err = fmt.Fprintln(clientConn, "solution-1234")
+	assert.NoError(t, err)
+
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixQuote+"primary", strings.TrimSpace(response))
+
+	clientConn.Close()
+	assert.NoError(t, <-done)
+}
+
+// Test that clients sending the same seed get the same quote
+func TestHandleConnection_SeededQuotes(t *testing.T) {
+	seeded := make([]protocol.QuoteMessage, 0, 10)
+	for i := 0; i < 10; i++ {
+		seeded = append(seeded, protocol.QuoteMessage{Text: fmt.Sprintf("seeded-%d", i)})
+	}
+	newSeededHandler := func() app.Handler {
+		return app.NewHandler(
+			quotes.NewRandomQuoteProvider([]string{"random"}),
+			newAcceptingPoW(t),
+			app.WithSeededQuotes(quotes.NewDeterministicProvider(seeded)),
+		)
+	}
+
+	first := pipeExchange(t, newSeededHandler(), protocol.PrefixSeed+"game-42")
+	assert.True(t, strings.HasPrefix(first, protocol.PrefixQuote+"seeded-"), "Expected a seeded quote, got %q", first)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, pipeExchange(t, newSeededHandler(), protocol.PrefixSeed+"game-42"), "Same seed should select the same quote")
+	}
+
+	distinct := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		distinct[pipeExchange(t, newSeededHandler(), fmt.Sprintf("%sgame-%d", protocol.PrefixSeed, i))] = true
+	}
+	assert.Greater(t, len(distinct), 1, "Different seeds should be able to select different quotes")
+
+	// Without a seed the default source is used
+	assert.Equal(t, protocol.PrefixQuote+"random", pipeExchange(t, newSeededHandler(), ""))
+	assert.Equal(t, protocol.PrefixQuote+"random", pipeExchange(t, newSeededHandler(), protocol.PrefixCollection+"stoicism"))
+}
+
+// Test that seeds failing validation are rejected before a challenge is issued
+func TestHandleConnection_InvalidSeed(t *testing.T) {
+	for _, seed := range []string{"", "with space", strings.Repeat("x", protocol.MaxSeedLength+1)} {
+		handler := app.NewHandler(
+			quotes.NewRandomQuoteProvider([]string{"random"}),
+			mocks.NewPowChallenge(t),
+			app.WithSeededQuotes(quotes.NewDeterministicProvider([]protocol.QuoteMessage{{Text: "seeded"}})),
+		)
+
+		serverConn, clientConn := net.Pipe()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		_, err := fmt.Fprintln(clientConn, protocol.PrefixSeed+seed)
+		assert.NoError(t, err)
+
+		reader := bufio.NewReader(clientConn)
+		readVersion(t, reader)
+		reply, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixError+app.InvalidSeedMsg+"\n", reply, seed)
+		assert.ErrorIs(t, <-done, app.ErrInvalidSeed)
+		clientConn.Close()
+	}
+}
+
+// Test that the handler waits for the quote acknowledgement and only warns when it is missing
+func TestHandleConnection_RequireAck(t *testing.T) {
+	cases := []struct {
+		name    string
+		ack     string
+		warning string
+	}{
+		{name: "acknowledged", ack: protocol.PrefixAck},
+		{name: "timeout", warning: "Quote not acknowledged"},
+		{name: "unexpected line", ack: "OK", warning: `Quote not acknowledged, got "OK"`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := logger.NewContext(context.Background(), newJSONLogEntry(&buf))
+			handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), newAcceptingPoW(t), app.WithRequireAck(50*time.Millisecond))
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				defer serverConn.Close()
+				done <- handler.HandleConnection(ctx, serverConn)
+			}()
+
+			reader := bufio.NewReader(clientConn)
+			readVersion(t, reader)
+			_, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			_, err = fmt.Fprintln(clientConn, "solution-1234")
+			assert.NoError(t, err)
+
+			quote, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixQuote+"quote\n", quote)
+
+			start := time.Now()
+			if tc.ack != "" {
+				_, err = fmt.Fprintln(clientConn, tc.ack)
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, <-done, "A missing acknowledgement is not an error")
+			if tc.warning == "" {
+				assert.Less(t, time.Since(start), 50*time.Millisecond, "The handler should return once acknowledged")
+				assert.NotContains(t, buf.String(), "not acknowledged")
+				assert.Contains(t, buf.String(), "Quote acknowledged")
+			} else {
+				assert.Contains(t, buf.String(), `"level":"warning"`)
+				assert.Contains(t, buf.String(), strings.ReplaceAll(tc.warning, `"`, `\"`))
+			}
+		})
+	}
+}
+
+// Test the commit/reveal exchange and the rejection of clients skipping the commitment
+func TestHandleConnection_ChallengeCommitment(t *testing.T) {
+	t.Run("committed", func(t *testing.T) {
+		handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), newAcceptingPoW(t), app.WithChallengeCommitment())
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		reader := bufio.NewReader(clientConn)
+		readVersion(t, reader)
+		commitment, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixCommitment+protocol.CommitChallenge("challenge-1234")+"\n", commitment)
+
+		_, err = fmt.Fprintln(clientConn, protocol.PrefixCommit)
+		assert.NoError(t, err)
+
+		challenge, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+
+		_, err = fmt.Fprintln(clientConn, "solution-1234")
+		assert.NoError(t, err)
+
+		quote, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixQuote+"quote\n", quote)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		mockPoW := mocks.NewPowChallenge(t)
+		mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234")
+		handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), mockPoW, app.WithChallengeCommitment())
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		reader := bufio.NewReader(clientConn)
+		readVersion(t, reader)
+		_, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+
+		// The client tries to answer without committing
+		_, err = fmt.Fprintln(clientConn, "solution-1234")
+		assert.NoError(t, err)
+
+		response, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixError+app.CommitmentRequiredMsg+"\n", response)
+		assert.ErrorIs(t, <-done, app.ErrCommitmentRequired)
+	})
+}
+
+// Test LF and CRLF clients completing the exchange with either server line ending
+func TestHandleConnection_LineEnding(t *testing.T) {
+	for _, serverEnding := range []string{protocol.LF, protocol.CRLF} {
+		for _, clientEnding := range []string{protocol.LF, protocol.CRLF} {
+			t.Run(fmt.Sprintf("server %q client %q", serverEnding, clientEnding), func(t *testing.T) {
+				handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), newAcceptingPoW(t),
+					app.WithFraming(protocol.LineFraming{Ending: serverEnding}))
+
+				serverConn, clientConn := net.Pipe()
+				defer clientConn.Close()
+
+				done := make(chan error, 1)
+				go func() {
+					defer serverConn.Close()
+					done <- handler.HandleConnection(context.Background(), serverConn)
+				}()
+
+				reader := bufio.NewReader(clientConn)
+				readVersion(t, reader)
+				challenge, err := reader.ReadString('\n')
+				assert.NoError(t, err)
+				assert.Equal(t, protocol.PrefixChallenge+"challenge-1234"+serverEnding, challenge)
+
+				_, err = io.WriteString(clientConn, "solution-1234"+clientEnding)
+				assert.NoError(t, err)
+
+				quote, err := reader.ReadString('\n')
+				assert.NoError(t, err)
+				assert.Equal(t, protocol.PrefixQuote+"quote"+serverEnding, quote)
+				assert.NoError(t, <-done)
+			})
+		}
+	}
+}
+
+// Test legacy clients getting a compat challenge and clients sending a hello the advertised difficulty
+func TestHandleConnection_LegacyClients(t *testing.T) {
+	cases := []struct {
+		name       string
+		hello      string
+		difficulty int
+		advertised bool
+	}{
+		{name: "legacy client", difficulty: 1},
+		{name: "new client", hello: protocol.FormatVersionLine(protocol.Version, ""), difficulty: 3, advertised: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}),
+				pow.NewSHA256PoW(3, pow.WithCompatDifficulty(1)), app.WithLegacyClients(50*time.Millisecond))
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				defer serverConn.Close()
+				done <- handler.HandleConnection(context.Background(), serverConn)
+			}()
+
+			if tc.hello != "" {
+				_, err := fmt.Fprintln(clientConn, tc.hello)
+				assert.NoError(t, err)
+			}
+
+			// Legacy clients would take the announcement for the challenge
+			reader := bufio.NewReader(clientConn)
+			if tc.advertised {
+				readVersion(t, reader)
+			}
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			challenge := strings.TrimSpace(strings.TrimPrefix(line, protocol.PrefixChallenge))
+
+			difficulty, err := protocol.ChallengeDifficulty(challenge)
+			if tc.advertised {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.difficulty, difficulty)
+			} else {
+				assert.Error(t, err, "Legacy clients should not get the difficulty in the challenge")
+			}
+
+			solution, err := wowclient.Solve(context.Background(), challenge, tc.difficulty)
+			assert.NoError(t, err)
+			_, err = fmt.Fprintln(clientConn, solution)
+			assert.NoError(t, err)
+
+			quote, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixQuote+"quote\n", quote)
+			assert.NoError(t, <-done)
+		})
+	}
+}
+
+// Test legacy clients keep getting compat challenges, with a penalty on top of the compat difficulty
+func TestHandleConnection_LegacyClientsDifficulty(t *testing.T) {
+	p := pow.NewSHA256PoW(1, pow.WithCompatDifficulty(1))
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), p,
+		app.WithLegacyClients(20*time.Millisecond), app.WithInvalidPenalty(1, 3))
+
+	// firstLines serves a silent client, answering its challenge with the
+	// given solver, and returns the lines received before the challenge
+	firstLines := func(solve func(challenge string) string) []string {
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		var lines []string
+		reader := bufio.NewReader(clientConn)
+		for {
+			line, err := reader.ReadString('\n')
+			if !assert.NoError(t, err) {
+				return lines
+			}
+			lines = append(lines, strings.TrimSpace(line))
+			if strings.HasPrefix(line, protocol.PrefixChallenge) {
+				break
+			}
+		}
+
+		_, err := fmt.Fprintln(clientConn, solve(strings.TrimPrefix(lines[len(lines)-1], protocol.PrefixChallenge)))
+		assert.NoError(t, err)
+		_, _ = reader.ReadString('\n')
+		<-done
+		return lines
+	}
+	invalid := func(challenge string) string {
+		solution := "invalid"
+		for i := 0; p.ValidateChallenge(challenge, solution); i++ {
+			solution = fmt.Sprintf("invalid-%d", i)
+		}
+		return solution
+	}
+
+	lines := firstLines(invalid)
+	_, err := protocol.ChallengeDifficulty(strings.TrimPrefix(lines[0], protocol.PrefixChallenge))
+	assert.Error(t, err, "A legacy client should first get a compat challenge")
+
+	lines = firstLines(func(challenge string) string {
+		weak, err := wowclient.Solve(context.Background(), challenge, 1)
+		assert.NoError(t, err)
+		hash := sha256.Sum256(protocol.SolutionHashInput(challenge, weak, false))
+		assert.Equal(t, strings.HasPrefix(hex.EncodeToString(hash[:]), "00"), p.ValidateChallenge(challenge, weak),
+			"A penalized legacy client should be validated at the compat difficulty plus the penalty")
+
+		solution, err := wowclient.Solve(context.Background(), challenge, 2)
+		assert.NoError(t, err)
+		return solution
+	})
+	assert.Len(t, lines, 1, "A penalized legacy client should not get the difficulty announced")
+	_, err = protocol.ChallengeDifficulty(strings.TrimPrefix(lines[0], protocol.PrefixChallenge))
+	assert.Error(t, err, "A penalized legacy client should get a compat challenge")
+
+	p.SetDifficulty(3)
+	lines = firstLines(func(challenge string) string {
+		solution, err := wowclient.Solve(context.Background(), challenge, 1)
+		assert.NoError(t, err)
+		assert.True(t, p.ValidateChallenge(challenge, solution), "A raised difficulty should not apply to legacy clients")
+		return solution
+	})
+	_, err = protocol.ChallengeDifficulty(strings.TrimPrefix(lines[0], protocol.PrefixChallenge))
+	assert.Error(t, err, "A legacy client should get a compat challenge above the compat difficulty")
+}
+
+// Test a full exchange with a real PoW over net.Pipe, without binding a port
+func TestHandlerWithNetPipe(t *testing.T) {
+	difficulty := 2
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"in-process"}), pow.NewSHA256PoW(difficulty))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	challenge, ok := strings.CutPrefix(strings.TrimSpace(line), protocol.PrefixChallenge)
+	assert.True(t, ok, "Expected a challenge, got %q", line)
+
+	solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+	assert.NoError(t, err)
+	_, err = fmt.Fprintln(clientConn, solution)
+	assert.NoError(t, err)
+
+	quote, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixQuote+"in-process\n", quote)
+	assert.NoError(t, <-done)
+}
+
+// Test that every solved round on a keep-alive connection raises the difficulty
+func TestHandleConnection_ProgressiveDifficulty(t *testing.T) {
+	handler := app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{"progressive"}),
+		pow.NewSHA256PoW(1),
+		app.WithMaxRequestsPerConnection(3),
+		app.WithProgressiveDifficulty(1, 3, 1),
+	)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	for round, difficulty := range []int{1, 2, 3} {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s%d\n", protocol.PrefixDifficulty, difficulty), line, "Round %d", round+1)
+
+		line, err = reader.ReadString('\n')
+		assert.NoError(t, err)
+		challenge, ok := strings.CutPrefix(strings.TrimSpace(line), protocol.PrefixChallenge)
+		assert.True(t, ok, "Expected a challenge, got %q", line)
+
+		embedded, err := protocol.ChallengeDifficulty(challenge)
+		assert.NoError(t, err)
+		assert.Equal(t, difficulty, embedded, "Challenge should be issued at the announced difficulty")
+
+		solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+		assert.NoError(t, err)
+		_, err = fmt.Fprintln(clientConn, solution)
+		assert.NoError(t, err)
+
+		quote, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixQuote+"progressive\n", quote)
+	}
+
+	last, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixDone+"\n", last)
+	assert.NoError(t, <-done)
+}
+
+// Test that the version announcement precedes the first challenge
+func TestHandleConnection_VersionAnnouncement(t *testing.T) {
+	cases := []struct {
+		name       string
+		instanceID string
+		expected   string
+	}{
+		{"with instance", "wow-2", "VERSION 1 instance=wow-2\n"},
+		{"without instance", "", "VERSION 1\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), newAcceptingPoW(t), app.WithInstanceID(tc.instanceID))
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				defer serverConn.Close()
+				done <- handler.HandleConnection(context.Background(), serverConn)
+			}()
+
+			reader := bufio.NewReader(clientConn)
+			version, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, version)
+
+			_, instanceID, err := protocol.ParseVersionLine(version)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.instanceID, instanceID)
+
+			challenge, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+
+			_, err = fmt.Fprintln(clientConn, "solution-1234")
+			assert.NoError(t, err)
+			quote, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixQuote+"quote\n", quote)
+			assert.NoError(t, <-done)
+		})
+	}
+}
+
+// answerChallenge answers the challenge over net.Pipe with the given response and returns the server reply
+func answerChallenge(t *testing.T, handler app.Handler, response string) string {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+
+	_, err = fmt.Fprintln(clientConn, response)
+	assert.NoError(t, err)
+
+	reply, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+
+	return strings.TrimSpace(reply)
+}
+
+// Test solutions bound to their challenge with WithChallengeEcho
+func TestHandleConnection_ChallengeEcho(t *testing.T) {
+	provider := quotes.NewRandomQuoteProvider([]string{"echoed"})
+
+	t.Run("correct echo", func(t *testing.T) {
+		handler := app.NewHandler(provider, newAcceptingPoW(t), app.WithChallengeEcho())
+
+		reply := answerChallenge(t, handler, "challenge-1234:solution-1234")
+		assert.Equal(t, protocol.PrefixQuote+"echoed", reply)
+	})
+
+	cases := []struct {
+		name     string
+		response string
+	}{
+		{"mismatched echo", "challenge-9999:solution-1234"},
+		{"missing echo", "solution-1234"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockPoW := mocks.NewPowChallenge(t)
+			mockPoW.EXPECT().
+				GenerateChallenge().
+				Return("challenge-1234")
+
+			handler := app.NewHandler(provider, mockPoW, app.WithChallengeEcho())
+
+			reply := answerChallenge(t, handler, c.response)
+			assert.Equal(t, protocol.PrefixError+app.ChallengeMismatchMsg, reply)
+			mockPoW.AssertNotCalled(t, "ValidateChallenge", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Test a solution arriving in several small segments
+func TestHandleConnection_SegmentedResponse(t *testing.T) {
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"segmented"}), newAcceptingPoW(t))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	_, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+
+	for _, segment := range []string{"solu", "tion", "-12", "34", "\n"} {
+		_, err := clientConn.Write([]byte(segment))
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reply, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixQuote+"segmented\n", reply)
+	assert.NoError(t, <-done)
+}
+
+// Test a response exceeding the read limit is an error rather than a truncated solution
+func TestHandleConnection_ResponseTooLong(t *testing.T) {
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234")
+
+	handler := app.NewHandler(mocks.NewQuoteProvider(t), mockPoW)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	_, err := bufio.NewReader(clientConn).ReadString('\n')
+	assert.NoError(t, err)
+
+	// The server stops reading at the limit, so the rest of the write fails
+	go func() { _, _ = clientConn.Write(bytes.Repeat([]byte("a"), 2048)) }()
+
+	assert.ErrorIs(t, <-done, app.ErrResponseTooLong)
+	mockPoW.AssertNotCalled(t, "ValidateChallenge", mock.Anything, mock.Anything)
+}
+
+// Test the stub of an empty provider is served or rejected depending on WithRejectStub
+func TestHandleConnection_RejectStub(t *testing.T) {
+	empty := quotes.NewRandomQuoteProvider(nil)
+
+	t.Run("served", func(t *testing.T) {
+		handler := app.NewHandler(empty, newAcceptingPoW(t), app.WithRejectStub(false))
+
+		reply := answerChallenge(t, handler, "solution-1234")
+		assert.Equal(t, protocol.PrefixQuote+quotes.Stub, reply)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		handler := app.NewHandler(empty, newAcceptingPoW(t), app.WithRejectStub(true))
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		reader := bufio.NewReader(clientConn)
+		readVersion(t, reader)
+		_, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+
+		_, err = fmt.Fprintln(clientConn, "solution-1234")
+		assert.NoError(t, err)
+
+		reply, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixError+app.QuotesUnavailableMsg+"\n", reply)
+		assert.ErrorIs(t, <-done, app.ErrQuotesUnavailable)
+	})
+}
+
+// Test the line protocol terminates messages with a newline while framed messages carry none
+func TestHandleConnection_Framing(t *testing.T) {
+	t.Run("line", func(t *testing.T) {
+		handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"framed"}), newAcceptingPoW(t))
+
+		assert.Equal(t, protocol.PrefixQuote+"framed", answerChallenge(t, handler, "solution-1234"))
+	})
+
+	t.Run("length prefixed", func(t *testing.T) {
+		handler := app.NewHandler(
+			quotes.NewRandomQuoteProvider([]string{"framed"}),
+			newAcceptingPoW(t),
+			app.WithFraming(protocol.LengthPrefixedFraming{}),
+		)
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			defer serverConn.Close()
+			done <- handler.HandleConnection(context.Background(), serverConn)
+		}()
+
+		version, err := protocol.ReadFrame(clientConn, protocol.MaxMessageSize)
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.FormatVersionLine(protocol.Version, ""), version)
+
+		challenge, err := protocol.ReadFrame(clientConn, protocol.MaxMessageSize)
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixChallenge+"challenge-1234", challenge)
+
+		_, err = fmt.Fprintln(clientConn, "solution-1234")
+		assert.NoError(t, err)
+
+		quote, err := protocol.ReadFrame(clientConn, protocol.MaxMessageSize)
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixQuote+"framed", quote)
+		assert.NoError(t, <-done)
+	})
+}
+
+// Test invalid solutions are answered after an escalating delay that a valid solution resets
+func TestHandleConnection_InvalidDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234")
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "wrong").Return(false)
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "solution-1234").Return(true)
+
+	handler := app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{"quote"}),
+		mockPoW,
+		app.WithInvalidDelay(delay, 3*delay),
+	)
+
+	timed := func(response string) (string, time.Duration) {
+		start := time.Now()
+		reply := answerChallenge(t, handler, response)
+		return reply, time.Since(start)
+	}
+
+	// Every net.Pipe connection has the same remote address, so they count as one client
+	for _, want := range []time.Duration{delay, 2 * delay, 3 * delay} {
+		reply, elapsed := timed("wrong")
+		assert.Equal(t, protocol.PrefixError+app.InvalidMsg, reply)
+		assert.GreaterOrEqual(t, elapsed, want)
+		assert.Less(t, elapsed, want+delay, "Delay should double up to the maximum")
+	}
+
+	reply, elapsed := timed("solution-1234")
+	assert.Equal(t, protocol.PrefixQuote+"quote", reply)
+	assert.Less(t, elapsed, delay, "Valid solutions should not be delayed")
+
+	_, elapsed = timed("wrong")
+	assert.GreaterOrEqual(t, elapsed, delay)
+	assert.Less(t, elapsed, 2*delay, "A valid solution should reset the escalation")
+}
+
+// Test two solutions pipelined in a single write are both read in keep-alive mode
+func TestHandleConnection_PipelinedSolutions(t *testing.T) {
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().
+		GenerateChallenge().
+		Return("challenge-1234").
+		Times(2)
+	mockPoW.EXPECT().
+		ValidateChallenge("challenge-1234", "solution-1").
+		Return(true).
+		Once()
+	mockPoW.EXPECT().
+		ValidateChallenge("challenge-1234", "solution-2").
+		Return(true).
+		Once()
+
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"pipelined"}), mockPoW, app.WithMaxRequestsPerConnection(2))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(context.Background(), serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	_, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+
+	// Both answers in one segment, before the second challenge arrives
+	_, err = clientConn.Write([]byte("solution-1\nsolution-2\n"))
+	assert.NoError(t, err)
+
+	var replies []string
+	for i := 0; i < 4; i++ {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		replies = append(replies, strings.TrimSpace(line))
+	}
+
+	assert.Equal(t, []string{
+		protocol.PrefixQuote + "pipelined",
+		protocol.PrefixChallenge + "challenge-1234",
+		protocol.PrefixQuote + "pipelined",
+		protocol.PrefixDone,
+	}, replies)
+	assert.NoError(t, <-done)
+}
+
+// slowQuoteProvider is a context-aware provider answering only after delay
+type slowQuoteProvider struct {
+	delay time.Duration
+}
+
+func (p slowQuoteProvider) GetQuote() protocol.QuoteMessage {
+	time.Sleep(p.delay)
+	return protocol.QuoteMessage{Text: "too late"}
+}
+
+func (p slowQuoteProvider) Quote(ctx context.Context) (protocol.QuoteMessage, error) {
+	select {
+	case <-time.After(p.delay):
+		return protocol.QuoteMessage{Text: "too late"}, nil
+	case <-ctx.Done():
+		return protocol.QuoteMessage{}, ctx.Err()
+	}
+}
+
+// Test that a slow context-aware provider cannot exceed the request deadline
+func TestHandleConnection_RequestDeadline(t *testing.T) {
+	handler := app.NewHandler(slowQuoteProvider{delay: 5 * time.Second}, newAcceptingPoW(t))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer serverConn.Close()
+		done <- handler.HandleConnection(ctx, serverConn)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	readVersion(t, reader)
+	_, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, err = fmt.Fprintln(clientConn, "solution-1234")
+	assert.NoError(t, err)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second, "Handler should stop at the request deadline")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Handler did not respect the request deadline")
+	}
+}
+
+// Test that the same solved challenge always yields the same quote with WithSolutionQuotes
+func TestHandleConnection_SolutionQuotes(t *testing.T) {
+	stored := []protocol.QuoteMessage{{Text: "A"}, {Text: "B"}, {Text: "C"}}
+	provider := quotes.NewDeterministicProvider(stored)
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"random"}), newAcceptingPoW(t), app.WithSolutionQuotes(provider))
+
+	expected := protocol.PrefixQuote + provider.GetQuoteFor("challenge-1234:solution-1234").Text
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, expected, answerChallenge(t, handler, "solution-1234"))
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"word-of-wisdom/internal/ratelimit"
+	"word-of-wisdom/pkg/logger"
+)
+
+// Headers of the HTTP front-end
+const (
+	HeaderChallenge = "X-PoW-Challenge"
+	HeaderSolution  = "X-PoW-Solution"
+	// HeaderRequestID correlates the log lines of a request, e.g. set by a proxy
+	HeaderRequestID = "X-Request-ID"
+)
+
+// DefaultHTTPChallengeTTL is how long an issued challenge may be answered over HTTP
+const DefaultHTTPChallengeTTL = time.Minute
+
+// MaxHTTPChallenges bounds the unanswered challenges remembered over HTTP.
+// Once full, the oldest challenge is forgotten and can no longer be redeemed.
+const MaxHTTPChallenges = 10_000
+
+// HTTPHandler serves quotes over HTTP for clients that cannot speak the TCP
+// protocol. A GET without a solution is answered with 402 Payment Required and
+// a challenge in the X-PoW-Challenge header. The client retries with the
+// challenge and its solution in the X-PoW-Challenge and X-PoW-Solution headers
+// and gets the quote in the body.
+//
+// HTTP is stateless, so issued challenges are remembered until they are
+// answered or expire, at most MaxHTTPChallenges of them. Every challenge is
+// accepted once.
+type HTTPHandler struct {
+	quoteProvider quoteProvider
+	powChallenge  powChallenge
+	ttl           time.Duration
+	now           func() time.Time
+
+	// mu makes looking up and forgetting a challenge atomic
+	mu        sync.Mutex
+	issued    *ratelimit.LRU[time.Time]
+	lastSweep time.Time
+}
+
+// NewHTTPHandler creates an HTTP front-end issuing challenges valid for ttl
+func NewHTTPHandler(quoteProvider quoteProvider, powChallenge powChallenge, ttl time.Duration) *HTTPHandler {
+	return &HTTPHandler{
+		quoteProvider: quoteProvider,
+		powChallenge:  powChallenge,
+		ttl:           ttl,
+		now:           time.Now,
+		issued:        ratelimit.NewLRU[time.Time](MaxHTTPChallenges),
+	}
+}
+
+// ServeHTTP issues a challenge or serves a quote for a valid solution
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	challenge := r.Header.Get(HeaderChallenge)
+	solution := r.Header.Get(HeaderSolution)
+	if challenge == "" || solution == "" {
+		w.Header().Set(HeaderChallenge, h.issue())
+		http.Error(w, fmt.Sprintf("Solve the challenge and retry with the %s and %s headers", HeaderChallenge, HeaderSolution), http.StatusPaymentRequired)
+		return
+	}
+
+	log := logger.WithRequestID(r.Header.Get(HeaderRequestID))
+	if !h.redeem(challenge) || !h.powChallenge.ValidateChallenge(challenge, solution) {
+		log.WithFields(logrus.Fields{"event": EventPowRejected, "challenge": challenge}).Debug("PoW solution rejected")
+		http.Error(w, InvalidMsg, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := fmt.Fprintln(w, h.quoteProvider.GetQuote().String()); err != nil {
+		log.WithError(err).Debug("Failed to send quote")
+		return
+	}
+	log.WithField("event", EventQuoteServed).Debug("Quote served")
+}
+
+// issue generates a challenge and remembers it until it expires. Expired
+// challenges are dropped at most once per TTL.
+func (h *HTTPHandler) issue() string {
+	challenge := h.powChallenge.GenerateChallenge()
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(h.lastSweep) >= h.ttl {
+		h.lastSweep = now
+		h.issued.RemoveIf(func(expiry time.Time) bool { return now.After(expiry) })
+	}
+	h.issued.GetOrAdd(challenge, func() time.Time { return now.Add(h.ttl) })
+	return challenge
+}
+
+// redeem reports whether the challenge was issued and has not expired, forgetting it
+func (h *HTTPHandler) redeem(challenge string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	expiry, ok := h.issued.Peek(challenge)
+	if !ok {
+		return false
+	}
+	h.issued.Remove(challenge)
+	return !h.now().After(expiry)
+}
+
+// IssuedChallenges returns the number of challenges awaiting an answer
+func (h *HTTPHandler) IssuedChallenges() int {
+	return h.issued.Len()
+}
+
+// RateLimitHTTP applies the per-IP and per-subnet rate limits of the TCP
+// server to next, e.g. the HTTP or WebSocket front-end. Only requests for a
+// challenge take a token, including WebSocket handshakes, so a quote costs one
+// token over every protocol. Limited clients get 429 Too Many Requests.
+func (s *Server) RateLimitHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderChallenge) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		log := s.loggerFor(host).WithField("client_ip", host)
+		if !s.allow(log, net.ParseIP(host)) {
+			s.warnSampled(log, "rate_limited", "Rate limit exceeded. Rejecting HTTP client.")
+			// The limiter regains a token well within a second
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, s.config.ConnectionRejectionMessages.RateLimit, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
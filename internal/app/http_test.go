@@ -0,0 +1,148 @@
+package app_test
+
+import (
+	"context"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/app"
+	"word-of-wisdom/internal/config"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
+)
+
+// getQuote sends a GET with the optional challenge and solution headers
+func getQuote(t *testing.T, url, challenge, solution string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if challenge != "" {
+		req.Header.Set(app.HeaderChallenge, challenge)
+		req.Header.Set(app.HeaderSolution, solution)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, strings.TrimSpace(string(body))
+}
+
+// TestHTTPHandler covers the challenge, a rejected solution and a served quote
+func TestHTTPHandler(t *testing.T) {
+	difficulty := 2
+	quote := protocol.QuoteMessage{Text: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"}
+	handler := app.NewHTTPHandler(
+		quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{quote}),
+		pow.NewSHA256PoW(difficulty),
+		time.Minute,
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// A plain GET is answered with a challenge
+	resp, _ := getQuote(t, server.URL, "", "")
+	assert.Equal(t, http.StatusPaymentRequired, resp.StatusCode)
+	challenge := resp.Header.Get(app.HeaderChallenge)
+	require.NotEmpty(t, challenge)
+
+	// An invalid solution is rejected and uses up the challenge
+	resp, body := getQuote(t, server.URL, challenge, "invalid")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, app.InvalidMsg, body)
+
+	resp, _ = getQuote(t, server.URL, "", "")
+	challenge = resp.Header.Get(app.HeaderChallenge)
+	solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+	require.NoError(t, err)
+
+	// A valid solution returns the quote
+	resp, body = getQuote(t, server.URL, challenge, solution)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, quote.String(), body)
+
+	// The same solution cannot be replayed
+	resp, _ = getQuote(t, server.URL, challenge, solution)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+// TestHTTPHandlerForgedChallenge ensures challenges not issued by the server are rejected
+func TestHTTPHandlerForgedChallenge(t *testing.T) {
+	handler := app.NewHTTPHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(4), time.Minute)
+
+	// Difficulty 0 is solved by anything, but the server never issued it
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(app.HeaderChallenge, protocol.FormatChallenge(0, "deadbeef"))
+	req.Header.Set(app.HeaderSolution, "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+// TestHTTPHandlerBoundsChallenges ensures unanswered challenges cannot grow without bound
+func TestHTTPHandlerBoundsChallenges(t *testing.T) {
+	difficulty := 1
+	handler := app.NewHTTPHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(difficulty), time.Minute)
+
+	issue := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header().Get(app.HeaderChallenge)
+	}
+
+	oldest := issue()
+	for range app.MaxHTTPChallenges {
+		issue()
+	}
+	assert.Equal(t, app.MaxHTTPChallenges, handler.IssuedChallenges())
+
+	// The oldest challenge was forgotten to make room
+	solution, err := wowclient.Solve(context.Background(), oldest, difficulty)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(app.HeaderChallenge, oldest)
+	req.Header.Set(app.HeaderSolution, solution)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+// TestRateLimitHTTP ensures HTTP clients asking for challenges share the per-IP rate limit
+func TestRateLimitHTTP(t *testing.T) {
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(config.Config{MaxConnections: 1, RateLimitEvery100MS: 2}, log, &MockHandler{})
+	handler := server.RateLimitHTTP(app.NewHTTPHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1), time.Minute))
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusPaymentRequired, get("192.0.2.1:1000").Code)
+	assert.Equal(t, http.StatusPaymentRequired, get("192.0.2.1:1001").Code)
+	rec := get("192.0.2.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "The burst should be used up")
+	assert.Equal(t, app.DefaultManyReqText, strings.TrimSpace(rec.Body.String()))
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Other IPs keep their own limit
+	assert.Equal(t, http.StatusPaymentRequired, get("192.0.2.2:1000").Code)
+}
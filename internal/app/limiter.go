@@ -0,0 +1,68 @@
+package app
+
+import (
+	"golang.org/x/time/rate"
+	"sync/atomic"
+	"time"
+	"word-of-wisdom/internal/ratelimit"
+)
+
+// limiterEntry is a rate limiter remembering when it was last used
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64
+}
+
+func newLimiterEntry(burst int) *limiterEntry {
+	return &limiterEntry{limiter: rate.NewLimiter(rate.Every(rateLimitInterval), burst)}
+}
+
+// allow takes a token at now and marks the entry as used
+func (e *limiterEntry) allow(now time.Time) bool {
+	e.lastSeen.Store(now.UnixNano())
+	return e.limiter.AllowN(now, 1)
+}
+
+// cleanupLimitersLoop drops idle limiters every half LimiterIdleTTL until shutdown
+func (s *Server) cleanupLimitersLoop() {
+	if s.config.LimiterIdleTTL <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.LimiterIdleTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if removed := s.CleanupLimiters(); removed > 0 {
+				s.logger.Debugf("Removed %d idle rate limiters", removed)
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// CleanupLimiters drops the rate limiters idle for longer than LimiterIdleTTL
+// and returns how many were removed. A returning client starts with a full burst.
+func (s *Server) CleanupLimiters() int {
+	if s.config.LimiterIdleTTL <= 0 {
+		return 0
+	}
+
+	cutoff := s.now().Add(-s.config.LimiterIdleTTL).UnixNano()
+	return removeIdle(s.limiterMap, cutoff) + removeIdle(s.subnetMap, cutoff)
+}
+
+// LimiterCount returns the number of per-IP rate limiters, at most MaxTrackedIPs when set
+func (s *Server) LimiterCount() int {
+	return s.limiterMap.Len()
+}
+
+// removeIdle deletes the entries last used before cutoff
+func removeIdle(limiters *ratelimit.LRU[*limiterEntry], cutoff int64) int {
+	return limiters.RemoveIf(func(entry *limiterEntry) bool {
+		return entry.lastSeen.Load() < cutoff
+	})
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Close reasons reported in the disconnect summary under the "close_reason" field
+const (
+	CloseReasonNormal      = "normal"
+	CloseReasonTimeout     = "timeout"
+	CloseReasonError       = "error"
+	CloseReasonPanic       = "panic"
+	CloseReasonRateLimited = "rate_limited"
+	CloseReasonClientGone  = "client_gone"
+	CloseReasonSlowloris   = "slowloris"
+)
+
+// metricsConn counts the bytes read from and written to a connection
+type metricsConn struct {
+	net.Conn
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+func newMetricsConn(conn net.Conn) *metricsConn {
+	return &metricsConn{Conn: conn}
+}
+
+func (c *metricsConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.bytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *metricsConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.bytesWritten.Add(int64(n))
+	return n, err
+}
+
+// connStats collects the outcome of a connection for the disconnect summary.
+// The handler updates it through the context; all methods are safe on nil.
+type connStats struct {
+	start          time.Time
+	powSolved      bool
+	powRejected    bool
+	quotePending   bool
+	quoteDelivered bool
+	closeReason    string
+	// probe marks a connection that failed without sending a single byte,
+	// e.g. a port scanner or a TCP health check
+	probe bool
+	// session exposes the connection state, see Server.Connections
+	session *session
+}
+
+type statsKey struct{}
+
+// withStats returns a copy of ctx carrying the connection stats
+func withStats(ctx context.Context, stats *connStats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// statsFromContext returns the connection stats stored in ctx, if any
+func statsFromContext(ctx context.Context) *connStats {
+	stats, _ := ctx.Value(statsKey{}).(*connStats)
+	return stats
+}
+
+// solved records an accepted PoW solution
+func (s *connStats) solved() {
+	if s != nil {
+		s.powSolved = true
+	}
+}
+
+// rejected records a rejected PoW solution
+func (s *connStats) rejected() {
+	if s != nil {
+		s.powRejected = true
+	}
+}
+
+// quoteBuffered records a quote waiting for the next flush
+func (s *connStats) quoteBuffered() {
+	if s != nil {
+		s.quotePending = true
+	}
+}
+
+// flushed records that buffered messages, including any pending quote, reached the client
+func (s *connStats) flushed() {
+	if s != nil && s.quotePending {
+		s.quoteDelivered = true
+		s.quotePending = false
+		if s.session != nil {
+			s.session.delivered.Add(1)
+		}
+	}
+}
+
+// closeReasonFor classifies the error returned by the handler
+func closeReasonFor(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return CloseReasonNormal
+	case errors.Is(err, ErrSlowloris):
+		return CloseReasonSlowloris
+	case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return CloseReasonTimeout
+	case errors.Is(err, ErrClientDisconnected):
+		return CloseReasonClientGone
+	default:
+		return CloseReasonError
+	}
+}
@@ -2,7 +2,10 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	mock "github.com/stretchr/testify/mock"
+	protocol "word-of-wisdom/pkg/protocol"
+)
 
 // QuoteProvider is an autogenerated mock type for the quoteProvider type
 type QuoteProvider struct {
@@ -18,18 +21,18 @@ func (_m *QuoteProvider) EXPECT() *QuoteProvider_Expecter {
 }
 
 // GetQuote provides a mock function with no fields
-func (_m *QuoteProvider) GetQuote() string {
+func (_m *QuoteProvider) GetQuote() protocol.QuoteMessage {
 	ret := _m.Called()
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetQuote")
 	}
 
-	var r0 string
-	if rf, ok := ret.Get(0).(func() string); ok {
+	var r0 protocol.QuoteMessage
+	if rf, ok := ret.Get(0).(func() protocol.QuoteMessage); ok {
 		r0 = rf()
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(protocol.QuoteMessage)
 	}
 
 	return r0
@@ -52,12 +55,12 @@ func (_c *QuoteProvider_GetQuote_Call) Run(run func()) *QuoteProvider_GetQuote_C
 	return _c
 }
 
-func (_c *QuoteProvider_GetQuote_Call) Return(_a0 string) *QuoteProvider_GetQuote_Call {
+func (_c *QuoteProvider_GetQuote_Call) Return(_a0 protocol.QuoteMessage) *QuoteProvider_GetQuote_Call {
 	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *QuoteProvider_GetQuote_Call) RunAndReturn(run func() string) *QuoteProvider_GetQuote_Call {
+func (_c *QuoteProvider_GetQuote_Call) RunAndReturn(run func() protocol.QuoteMessage) *QuoteProvider_GetQuote_Call {
 	_c.Call.Return(run)
 	return _c
 }
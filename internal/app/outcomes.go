@@ -0,0 +1,74 @@
+package app
+
+import "sync/atomic"
+
+// Connection outcomes counted by the server. The set is fixed, so the
+// cardinality of an "outcome" metric label stays bounded.
+const (
+	OutcomeQuoteSent          = "quote_sent"
+	OutcomeInvalidPoW         = "invalid_pow"
+	OutcomeRateLimited        = "rate_limited"
+	OutcomeCapacityRejected   = "capacity_rejected"
+	OutcomePaused             = "paused"
+	OutcomeMaintenance        = "maintenance"
+	OutcomeProxyHeaderInvalid = "proxy_header_invalid"
+	OutcomeError              = "error"
+	OutcomePanic              = "panic"
+)
+
+// Outcomes lists every connection outcome
+var Outcomes = []string{
+	OutcomeQuoteSent,
+	OutcomeInvalidPoW,
+	OutcomeRateLimited,
+	OutcomeCapacityRejected,
+	OutcomePaused,
+	OutcomeMaintenance,
+	OutcomeProxyHeaderInvalid,
+	OutcomeError,
+	OutcomePanic,
+}
+
+// outcomeCounters counts connections per outcome. The map is filled once with
+// the fixed outcome set and never modified, so it is safe for concurrent use.
+type outcomeCounters map[string]*atomic.Uint64
+
+func newOutcomeCounters() outcomeCounters {
+	counters := make(outcomeCounters, len(Outcomes))
+	for _, outcome := range Outcomes {
+		counters[outcome] = new(atomic.Uint64)
+	}
+	return counters
+}
+
+// inc counts a connection with the given outcome, ignoring unknown outcomes
+func (c outcomeCounters) inc(outcome string) {
+	if counter, ok := c[outcome]; ok {
+		counter.Add(1)
+	}
+}
+
+// snapshot returns the current count of every outcome
+func (c outcomeCounters) snapshot() map[string]uint64 {
+	counts := make(map[string]uint64, len(c))
+	for outcome, counter := range c {
+		counts[outcome] = counter.Load()
+	}
+	return counts
+}
+
+// outcome classifies a finished connection
+func (s *connStats) outcome() string {
+	switch {
+	case s.closeReason == CloseReasonPanic:
+		return OutcomePanic
+	case s.closeReason == CloseReasonRateLimited:
+		return OutcomeRateLimited
+	case s.closeReason == CloseReasonTimeout || s.closeReason == CloseReasonError || s.closeReason == CloseReasonClientGone:
+		return OutcomeError
+	case s.powRejected:
+		return OutcomeInvalidPoW
+	default:
+		return OutcomeQuoteSent
+	}
+}
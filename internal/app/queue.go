@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"net"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// enqueue lets a client wait for a connection slot when the queue has room.
+// It reports whether the client was queued.
+func (s *Server) enqueue(conn net.Conn, seq uint64) bool {
+	if s.config.MaxQueueDepth <= 0 || s.queued.Add(1) > int64(s.config.MaxQueueDepth) {
+		s.queued.Add(-1)
+		return false
+	}
+
+	s.queueWg.Add(1)
+	go s.waitInQueue(conn, seq)
+	return true
+}
+
+// waitInQueue waits for a free slot, rejecting the client once QueueWaitTimeout
+// elapses or the server shuts down
+func (s *Server) waitInQueue(conn net.Conn, seq uint64) {
+	defer s.queueWg.Done()
+	defer s.queued.Add(-1)
+
+	ctx := s.queueCtx
+	if s.config.QueueWaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.QueueWaitTimeout)
+		defer cancel()
+	}
+
+	if err := s.semaphore.Acquire(ctx); err == nil {
+		s.admit(conn, seq)
+		return
+	}
+
+	if s.queueCtx.Err() == nil {
+		s.outcomes.inc(OutcomeCapacityRejected)
+		s.warnSampled(s.logger.WithField("conn_seq", seq), "queue_timeout", "Queue wait timed out. Rejecting client.")
+	}
+	s.reject(conn, s.rejectionMessage(protocol.RejectionCapacity))
+}
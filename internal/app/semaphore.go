@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"golang.org/x/sync/semaphore"
+)
+
+// Semaphore limits the number of connections handled at once
+type Semaphore interface {
+	// Acquire blocks until a slot is free or ctx is done
+	Acquire(ctx context.Context) error
+	// TryAcquire takes a slot without blocking and reports whether it succeeded
+	TryAcquire() bool
+	// Release frees a slot taken by Acquire or TryAcquire
+	Release()
+}
+
+// ChannelSemaphore is a Semaphore backed by a buffered channel, one slot per connection
+type ChannelSemaphore chan struct{}
+
+// NewChannelSemaphore creates a semaphore admitting size connections
+func NewChannelSemaphore(size int) ChannelSemaphore {
+	return make(ChannelSemaphore, size)
+}
+
+// Acquire blocks until a slot is free or ctx is done
+func (s ChannelSemaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes a slot without blocking
+func (s ChannelSemaphore) TryAcquire() bool {
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot
+func (s ChannelSemaphore) Release() {
+	<-s
+}
+
+// WeightedSemaphore is a Semaphore where every connection takes weight units
+// of the capacity, e.g. to compare backpressure strategies with the same budget
+type WeightedSemaphore struct {
+	sem    *semaphore.Weighted
+	weight int64
+}
+
+// NewWeightedSemaphore creates a semaphore of the given capacity admitting
+// capacity/weight connections at once
+func NewWeightedSemaphore(capacity, weight int64) *WeightedSemaphore {
+	return &WeightedSemaphore{
+		sem:    semaphore.NewWeighted(capacity),
+		weight: weight,
+	}
+}
+
+// Acquire blocks until weight units are free or ctx is done
+func (s *WeightedSemaphore) Acquire(ctx context.Context) error {
+	return s.sem.Acquire(ctx, s.weight)
+}
+
+// TryAcquire takes weight units without blocking
+func (s *WeightedSemaphore) TryAcquire() bool {
+	return s.sem.TryAcquire(s.weight)
+}
+
+// Release frees weight units
+func (s *WeightedSemaphore) Release() {
+	s.sem.Release(s.weight)
+}
@@ -0,0 +1,35 @@
+package app_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/app"
+)
+
+// TestSemaphores ensures both implementations admit up to their capacity and honor cancellation
+func TestSemaphores(t *testing.T) {
+	tests := []struct {
+		name string
+		sem  app.Semaphore
+	}{
+		{name: "channel", sem: app.NewChannelSemaphore(2)},
+		{name: "weighted", sem: app.NewWeightedSemaphore(5, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.sem.TryAcquire())
+			assert.NoError(t, tt.sem.Acquire(context.Background()))
+			assert.False(t, tt.sem.TryAcquire(), "Semaphore should be full")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			assert.ErrorIs(t, tt.sem.Acquire(ctx), context.DeadlineExceeded)
+
+			tt.sem.Release()
+			assert.True(t, tt.sem.TryAcquire(), "Released slot should be available")
+		})
+	}
+}
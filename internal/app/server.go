@@ -1,23 +1,45 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/time/rate"
 	"net"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"word-of-wisdom/internal/config"
+	"word-of-wisdom/internal/proxyproto"
+	"word-of-wisdom/internal/ratelimit"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// rejectWriteTimeout bounds the rejection message write in the accept loop
+const rejectWriteTimeout = 100 * time.Millisecond
+
+// rateLimitInterval is the time for a rate limiter to regain a token
+const rateLimitInterval = 100 * time.Millisecond
+
+// Default texts of config.ConnectionRejectionMessages
+const (
+	DefaultManyReqText     = "Too many requests. Please try again later."
+	DefaultErrInternalText = "Internal server error. Please try again later."
+	DefaultPausedText      = "Server is paused for maintenance. Please try again later."
 )
 
 const (
-	MsgOnManyReq     = "Too many requests. Please try again later.\n"
-	MsgOnErrInternal = "Internal server error. Please try again later.\n"
+	MsgOnManyReq     = protocol.PrefixError + DefaultManyReqText + "\n"
+	MsgOnErrInternal = protocol.PrefixError + DefaultErrInternalText + "\n"
+	MsgOnPaused      = protocol.PrefixError + DefaultPausedText + "\n"
 )
 
 // Server encapsulates the TCP server's behavior
@@ -26,39 +48,158 @@ type Server struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	wg           sync.WaitGroup
-	semaphore    chan struct{}
+	semaphore    Semaphore
+	active       atomic.Int64
 	shutdownOnce sync.Once
 	config       config.Config
-	handler      Handler
+	handler      atomic.Pointer[Handler]
 	logger       *logrus.Logger
-	limiterMap   sync.Map
+	limiterMap   *ratelimit.LRU[*limiterEntry]
+	subnetMap    *ratelimit.LRU[*limiterEntry]
+	healthy      atomic.Bool
+	paused       atomic.Bool
+	stopping     atomic.Bool
+	acceptDone   chan struct{}
+	jobs         chan job
+	workersWg    sync.WaitGroup
+	busyWorkers  sync.Map
+	sampler      *logger.Sampler
+	connSequence atomic.Uint64
+	quotesServed atomic.Uint64
+	outcomes     outcomeCounters
+	queued       atomic.Int64
+	queueWg      sync.WaitGroup
+	queueCtx     context.Context
+	stopQueue    context.CancelFunc
+	now          func() time.Time
+	silentIPs    map[string]bool
+	silentLogger *logrus.Logger
+	recorder     *Recorder
+	sessions     sync.Map
+	events       []chan<- ConnectionEvent
 }
 
-// NewServer initializes a new server instance
-func NewServer(c config.Config, logger *logrus.Logger, handler Handler) *Server {
+// ServerOption configures optional server behavior
+type ServerOption func(*Server)
+
+// WithSemaphore replaces the channel semaphore sized by MaxConnections, e.g.
+// to try other backpressure strategies. With a worker pool, connections the
+// semaphore admits beyond MaxConnections are rejected as over capacity, since
+// the queue of the pool holds MaxConnections connections.
+func WithSemaphore(sem Semaphore) ServerOption {
+	return func(s *Server) {
+		s.semaphore = sem
+	}
+}
+
+// WithClock replaces time.Now for rate limiting and limiter cleanup, e.g. to
+// control time in tests
+func WithClock(now func() time.Time) ServerOption {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithRecorder captures the bytes exchanged with every client, see Recorder
+func WithRecorder(recorder *Recorder) ServerOption {
+	return func(s *Server) {
+		s.recorder = recorder
+	}
+}
+
+// WithConnectionEvents reports every connection state change on events.
+// Events are dropped rather than slowing down connections while events is
+// full. It may be used several times, e.g. together with WithStatsDClient.
+func WithConnectionEvents(events chan<- ConnectionEvent) ServerOption {
+	return func(s *Server) {
+		s.events = append(s.events, events)
+	}
+}
+
+// NewServer initializes a new server instance that shuts down on SIGINT or SIGTERM
+func NewServer(c config.Config, log *logrus.Logger, handler Handler, opts ...ServerOption) *Server {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	return &Server{
-		ctx:       ctx,
-		cancel:    cancel,
-		semaphore: make(chan struct{}, c.MaxConnections),
-		handler:   handler,
-		config:    c,
-		logger:    logger,
+	return newServer(ctx, cancel, c, log, handler, opts)
+}
+
+// NewServerWithContext initializes a new server instance without installing
+// signal handlers, for embedders handling signals themselves. The server shuts
+// down once ctx is done.
+func NewServerWithContext(ctx context.Context, c config.Config, log *logrus.Logger, handler Handler, opts ...ServerOption) *Server {
+	ctx, cancel := context.WithCancel(ctx)
+	return newServer(ctx, cancel, c, log, handler, opts)
+}
+
+// newServer initializes a server shut down by ctx or cancel
+func newServer(ctx context.Context, cancel context.CancelFunc, c config.Config, log *logrus.Logger, handler Handler, opts []ServerOption) *Server {
+	queueCtx, stopQueue := context.WithCancel(context.Background())
+	s := &Server{
+		ctx:        ctx,
+		cancel:     cancel,
+		semaphore:  NewChannelSemaphore(c.MaxConnections),
+		config:     c,
+		logger:     log,
+		acceptDone: make(chan struct{}),
+		outcomes:   newOutcomeCounters(),
+		queueCtx:   queueCtx,
+		stopQueue:  stopQueue,
+		now:        time.Now,
+		limiterMap: ratelimit.NewLRU[*limiterEntry](c.MaxTrackedIPs),
+		subnetMap:  ratelimit.NewLRU[*limiterEntry](0),
+	}
+	s.handler.Store(&handler)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if len(c.SilentIPs) > 0 {
+		s.silentIPs = make(map[string]bool, len(c.SilentIPs))
+		for _, ip := range c.SilentIPs {
+			s.silentIPs[ip] = true
+		}
+		s.silentLogger = newSilentLogger(log)
+	}
+
+	messages := &s.config.ConnectionRejectionMessages
+	messages.RateLimit = cmp.Or(messages.RateLimit, DefaultManyReqText)
+	messages.Capacity = cmp.Or(messages.Capacity, DefaultManyReqText)
+	messages.Internal = cmp.Or(messages.Internal, DefaultErrInternalText)
+	messages.Paused = cmp.Or(messages.Paused, DefaultPausedText)
+	if c.LogSampleLimit > 0 && c.LogSampleWindow > 0 {
+		s.sampler = logger.NewSampler(c.LogSampleLimit, c.LogSampleWindow)
 	}
+	if c.WorkerPoolSize > 0 {
+		// Every connection in the queue holds a semaphore slot, so with the
+		// semaphore sized by MaxConnections dispatch never blocks
+		s.jobs = make(chan job, c.MaxConnections)
+	}
+	return s
 }
 
 // Start initializes the listener, starts accepting connections, and waits for shutdown
 func (s *Server) Start() {
-	var err error
-	s.listener, err = net.Listen("tcp", s.config.Port)
+	listener, err := net.Listen("tcp", s.config.Port)
 	if err != nil {
 		s.logger.Fatalf("Failed to start server: %v", err)
 		return
 	}
 
-	s.logger.Infof("Server started on port %s", s.config.Port)
+	s.Serve(listener)
+}
+
+// Serve accepts connections on an existing listener and waits for shutdown.
+// It allows the listener to be inherited from a parent process during upgrades.
+func (s *Server) Serve(listener net.Listener) {
+	s.listener = listener
 
+	s.logger.Infof("Server started on %s", listener.Addr())
+
+	s.startWorkers()
 	go s.acceptConnections()
+	s.healthy.Store(true)
+	go s.cleanupLimitersLoop()
+	go s.goroutineGuardLoop()
+	go s.sampleSummaryLoop()
 
 	// Wait for shutdown signal
 	<-s.ctx.Done()
@@ -67,6 +208,8 @@ func (s *Server) Start() {
 
 // acceptConnections listens for incoming connections and limits concurrency
 func (s *Server) acceptConnections() {
+	defer close(s.acceptDone)
+
 	for {
 		conn, err := s.listener.Accept()
 		if err != nil {
@@ -82,72 +225,415 @@ func (s *Server) acceptConnections() {
 			continue
 		}
 
+		// The header is read by the connection goroutine, see proxyproto.Conn
+		if s.config.ProxyProtocol {
+			conn = proxyproto.NewConn(conn, s.config.ConnectionTimeout)
+		}
+
+		// Sequence numbers order connections in audit logs regardless of timestamp resolution
+		seq := s.connSequence.Add(1)
+
+		if s.config.MaintenanceMessage != "" && (s.ctx.Err() != nil || s.stopping.Load()) {
+			s.outcomes.inc(OutcomeMaintenance)
+			s.logger.WithField("conn_seq", seq).Debug("Server is shutting down. Sending the maintenance message.")
+			s.reject(conn, s.errorLine(s.config.MaintenanceMessage))
+			continue
+		}
+
+		if s.paused.Load() {
+			s.outcomes.inc(OutcomePaused)
+			s.logger.WithField("conn_seq", seq).Debug("Server is paused. Rejecting client.")
+			s.reject(conn, s.rejectionMessage(protocol.RejectionPaused))
+			continue
+		}
+
+		if s.semaphore.TryAcquire() {
+			s.admit(conn, seq)
+			continue
+		}
+		if s.enqueue(conn, seq) {
+			continue
+		}
+		s.outcomes.inc(OutcomeCapacityRejected)
+		s.warnSampled(s.logger.WithField("conn_seq", seq), "connection_rejected", "Too many connections. Rejecting client.")
+		s.reject(conn, s.rejectionMessage(protocol.RejectionCapacity))
+	}
+}
+
+// admit dispatches a connection holding a semaphore slot, rejecting it when
+// the queue of the worker pool is full
+func (s *Server) admit(conn net.Conn, seq uint64) {
+	s.active.Add(1)
+	s.wg.Add(1)
+	if s.dispatch(conn, seq) {
+		return
+	}
+
+	s.wg.Done()
+	s.release()
+	s.outcomes.inc(OutcomeCapacityRejected)
+	s.warnSampled(s.logger.WithField("conn_seq", seq), "connection_rejected", "Worker pool queue is full. Rejecting client.")
+	s.reject(conn, s.rejectionMessage(protocol.RejectionCapacity))
+}
+
+// release frees the semaphore slot of a finished connection
+func (s *Server) release() {
+	s.active.Add(-1)
+	s.semaphore.Release()
+}
+
+// reject tells the client why it is turned away and closes the connection.
+// The write is bounded so a slow client cannot stall the accept loop.
+func (s *Server) reject(conn net.Conn, message string) {
+	_ = conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	_, _ = conn.Write([]byte(message))
+	_ = conn.Close()
+}
+
+// rejectionMessage returns the configured rejection message of the reason, or
+// a structured hint when StructuredRejections is set. Rate-limited clients may retry once the limiter
+// regains a token; a connection slot frees up at the latest when a client
+// reaches the connection timeout.
+func (s *Server) rejectionMessage(reason string) string {
+	if !s.config.StructuredRejections {
+		switch reason {
+		case protocol.RejectionCapacity:
+			return s.errorLine(s.config.ConnectionRejectionMessages.Capacity)
+		case protocol.RejectionPaused:
+			return s.errorLine(s.config.ConnectionRejectionMessages.Paused)
+		}
+		return s.errorLine(s.config.ConnectionRejectionMessages.RateLimit)
+	}
+
+	retryAfter := rateLimitInterval
+	if reason == protocol.RejectionCapacity || reason == protocol.RejectionPaused {
+		retryAfter = s.config.ConnectionTimeout
+	}
+
+	var difficulty int
+	if h, ok := s.Handler().(interface{ Difficulty() int }); ok {
+		difficulty = h.Difficulty()
+	}
+
+	return protocol.FormatRejection(protocol.Rejection{
+		Reason:       reason,
+		Difficulty:   difficulty,
+		RetryAfterMS: retryAfter.Milliseconds(),
+	}) + s.config.LineTerminator()
+}
+
+// errorLine formats an ERROR message line with the configured line ending
+func (s *Server) errorLine(text string) string {
+	return protocol.PrefixError + text + s.config.LineTerminator()
+}
+
+// warnSampled logs a high-frequency event, through the sampler when sampling is enabled
+func (s *Server) warnSampled(log *logrus.Entry, event, message string) {
+	if s.sampler == nil {
+		log.Warn(message)
+		return
+	}
+
+	allowed, suppressed := s.sampler.Sample(event)
+	if suppressed > 0 {
+		s.logSuppressed(event, suppressed)
+	}
+	if allowed {
+		log.Warn(message)
+	}
+}
+
+// logSuppressed logs the summary of the events suppressed by the sampler
+func (s *Server) logSuppressed(event string, suppressed int) {
+	s.logger.WithField("event", event).Warnf("%d similar messages suppressed in the last %s", suppressed, s.sampler.Window())
+}
+
+// sampleSummaryLoop reports the events suppressed in passed sampling windows
+// that no later event of the same kind has reported
+func (s *Server) sampleSummaryLoop() {
+	if s.sampler == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.sampler.Window())
+	defer ticker.Stop()
+
+	for {
 		select {
-		case s.semaphore <- struct{}{}:
-			s.wg.Add(1)
-			go s.handleClient(conn)
-		default:
-			s.logger.Warn("Too many connections. Rejecting client.")
-			_ = conn.Close()
+		case <-ticker.C:
+			for event, suppressed := range s.sampler.Flush() {
+				s.logSuppressed(event, suppressed)
+			}
+		case <-s.ctx.Done():
+			return
 		}
 	}
 }
 
 // getLimiterForIP returns a rate limiter per IP
-func (s *Server) getLimiterForIP(ip string) *rate.Limiter {
-	limiter, loaded := s.limiterMap.LoadOrStore(ip, rate.NewLimiter(rate.Every(100*time.Millisecond), s.config.RateLimitEvery100MS))
-	if !loaded {
-		s.logger.Infof("Created new rate limiter for IP: %s", ip)
+func (s *Server) getLimiterForIP(log *logrus.Entry, ip string) *limiterEntry {
+	entry, added := s.limiterMap.GetOrAdd(ip, func() *limiterEntry {
+		return newLimiterEntry(s.config.RateLimitEvery100MS)
+	})
+	if added {
+		log.Infof("Created new rate limiter for IP: %s", ip)
+	}
+	return entry
+}
+
+// getLimiterForSubnet returns a rate limiter shared by every IP of the client's subnet
+func (s *Server) getLimiterForSubnet(log *logrus.Entry, ip net.IP) *limiterEntry {
+	subnet := ratelimit.SubnetKey(ip, s.config.SubnetMask)
+	entry, added := s.subnetMap.GetOrAdd(subnet, func() *limiterEntry {
+		return newLimiterEntry(s.config.SubnetRateLimit)
+	})
+	if added {
+		log.Infof("Created new rate limiter for subnet: %s/%d", subnet, s.config.SubnetMask)
+	}
+	return entry
+}
+
+// allow checks the per-IP limit and, when enabled, the per-subnet limit
+func (s *Server) allow(log *logrus.Entry, ip net.IP) bool {
+	now := s.now()
+	if !s.getLimiterForIP(log, ip.String()).allow(now) {
+		return false
+	}
+
+	if s.config.SubnetMask > 0 && s.config.SubnetRateLimit > 0 {
+		return s.getLimiterForSubnet(log, ip).allow(now)
 	}
-	return limiter.(*rate.Limiter)
+
+	return true
 }
 
 // handleClient processes a single client connection
-func (s *Server) handleClient(conn net.Conn) {
+func (s *Server) handleClient(rawConn net.Conn, seq uint64, start time.Time) {
 	defer s.wg.Done()
-	defer conn.Close()
-	defer func() { <-s.semaphore }() // Release slot
-	defer s.recoverPanic("handleClient", conn)
+	defer rawConn.Close()
+	defer s.release()
+
+	// The connection keeps this handler even if it is swapped meanwhile
+	handler := s.Handler()
+
+	if proxyConn, ok := rawConn.(*proxyproto.Conn); ok {
+		if err := proxyConn.Header(); err != nil {
+			s.outcomes.inc(OutcomeProxyHeaderInvalid)
+			log := s.logger.WithFields(logrus.Fields{"conn_seq": seq, "remote_addr": proxyConn.Conn.RemoteAddr().String()})
+			s.warnSampled(log.WithError(err), "proxy_header_invalid", "Invalid PROXY header. Rejecting client.")
+			return
+		}
+	}
+
+	conn := newMetricsConn(rawConn)
+	stats := &connStats{start: start, closeReason: CloseReasonNormal}
 
-	ip := conn.RemoteAddr().(*net.TCPAddr).IP.String()
-	limiter := s.getLimiterForIP(ip)
+	remoteIP := conn.RemoteAddr().(*net.TCPAddr).IP
+	ip := remoteIP.String()
+	sessionID := newSessionID()
+	log := s.loggerFor(ip).WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"conn_seq":   seq,
+		"client_ip":  ip,
+	})
+
+	stats.session = s.trackSession(sessionID, ip, stats.start)
+	defer s.untrackSession(stats.session)
+
+	defer s.logDisconnect(log, conn, stats)
+	defer s.recoverPanic("handleClient", conn, stats)
 
-	if err := conn.SetDeadline(time.Now().Add(s.config.ConnectionTimeout)); err != nil {
-		s.logger.Errorf("Failed to set deadline for client %s: %v", ip, err)
+	// A single deadline bounds the connection I/O and everything the handler does
+	deadline := stats.start.Add(s.config.ConnectionTimeout)
+	if err := conn.SetDeadline(deadline); err != nil {
+		log.Errorf("Failed to set deadline for client %s: %v", ip, err)
 	}
 
-	if !limiter.Allow() {
-		_, _ = conn.Write([]byte(MsgOnManyReq))
+	if !s.allow(log, remoteIP) {
+		stats.closeReason = CloseReasonRateLimited
+		s.warnSampled(log, "rate_limited", "Rate limit exceeded. Rejecting client.")
+		_, _ = conn.Write([]byte(s.rejectionMessage(protocol.RejectionRateLimited)))
+		return
+	}
+
+	// The queue stops as soon as shutdown begins, so waits like the tarpit end early
+	ctx := withShutdown(withStats(logger.NewContext(s.ctx, log), stats), s.queueCtx.Done())
+	ctx = WithConnMeta(ctx, ConnMeta{IP: ip, SessionID: sessionID, ConnSeq: seq, StartTime: stats.start})
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	var handlerConn Conn = conn
+	if s.config.SlowReadMaxBytes > 0 && s.config.SlowReadStrikes > 0 {
+		handlerConn = newSlowReadConn(handlerConn, s.config.SlowReadMaxBytes, s.config.SlowReadStrikes, deadline)
+	}
+	if s.config.MaxBytesPerConnection > 0 {
+		handlerConn = newByteLimitedConn(handlerConn, s.config.MaxBytesPerConnection)
+	}
+	if s.recorder != nil {
+		handlerConn = s.recorder.Wrap(handlerConn, ip)
+	}
+	err := handler.HandleConnection(ctx, handlerConn)
+	stats.closeReason = closeReasonFor(err)
+	if err == nil {
 		return
 	}
 
-	if err := s.handler.HandleConnection(conn); err != nil {
-		s.logger.Errorf("Error handling client %s: %v", ip, err)
+	stats.probe = conn.bytesRead.Load() == 0
+	switch {
+	case stats.probe && s.config.ProbeLogging == config.ProbeLogSilent:
+	case stats.probe && s.config.ProbeLogging == config.ProbeLogDebug:
+		log.Debugf("Probe from %s sent no data: %v", ip, err)
+	case errors.Is(err, ErrClientDisconnected):
+		log.Debugf("Client %s disconnected early: %v", ip, err)
+	case errors.Is(err, ErrSlowloris):
+		log.Warnf("Closed slow client %s: %v", ip, err)
+	default:
+		log.Errorf("Error handling client %s: %v", ip, err)
 	}
 }
 
+// logDisconnect counts the connection outcome and logs a summary once the client is done
+func (s *Server) logDisconnect(log *logrus.Entry, conn *metricsConn, stats *connStats) {
+	outcome := stats.outcome()
+	s.outcomes.inc(outcome)
+	if stats.session != nil {
+		s.quotesServed.Add(uint64(stats.session.delivered.Load()))
+	}
+
+	level := logrus.InfoLevel
+	if stats.probe {
+		switch s.config.ProbeLogging {
+		case config.ProbeLogDebug:
+			level = logrus.DebugLevel
+		case config.ProbeLogSilent:
+			return
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"outcome":         outcome,
+		"duration_ms":     time.Since(stats.start).Milliseconds(),
+		"pow_solved":      stats.powSolved,
+		"quote_delivered": stats.quoteDelivered,
+		"bytes_read":      conn.bytesRead.Load(),
+		"bytes_written":   conn.bytesWritten.Load(),
+		"close_reason":    stats.closeReason,
+	}).Log(level, "Client disconnected")
+}
+
+// newSessionID returns a random correlation id for a single connection
+func newSessionID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // recoverPanic handles panics and logs stack traces
-func (s *Server) recoverPanic(funcName string, conn net.Conn) {
+func (s *Server) recoverPanic(funcName string, conn net.Conn, stats *connStats) {
 	if r := recover(); r != nil {
+		stats.closeReason = CloseReasonPanic
 		s.logger.Errorf("Panic recovered in %s: %v\nStack trace:\n%s", funcName, r, string(debug.Stack()))
 		if conn != nil {
-			_, _ = conn.Write([]byte(MsgOnErrInternal))
+			_, _ = conn.Write([]byte(s.errorLine(s.config.ConnectionRejectionMessages.Internal)))
 		}
 	}
 }
 
+// Handler returns the handler serving new connections
+func (s *Server) Handler() Handler {
+	return *s.handler.Load()
+}
+
+// SetHandler replaces the handler for new connections, e.g. to switch quote
+// sources or PoW schemes at runtime. Connections in flight finish with the
+// handler they started with.
+func (s *Server) SetHandler(handler Handler) {
+	s.handler.Store(&handler)
+}
+
+// ActiveConnections returns the number of connections currently holding a semaphore slot
+func (s *Server) ActiveConnections() int {
+	return int(s.active.Load())
+}
+
+// ConnectionCount returns the sequence number of the last accepted connection,
+// i.e. the number of connections accepted so far
+func (s *Server) ConnectionCount() uint64 {
+	return s.connSequence.Load()
+}
+
+// QuotesServed returns the number of quotes delivered without write error by
+// the connections finished so far
+func (s *Server) QuotesServed() uint64 {
+	return s.quotesServed.Load()
+}
+
+// OutcomeCounts returns the number of connections per outcome, keyed by the Outcome constants
+func (s *Server) OutcomeCounts() map[string]uint64 {
+	return s.outcomes.snapshot()
+}
+
+// Ready reports whether the server accepts new clients: it is started, not
+// paused and not shutting down. See AdminHandler for the readiness probe.
+func (s *Server) Ready() bool {
+	return s.healthy.Load() && !s.paused.Load()
+}
+
+// Healthy reports whether the server is accepting connections and not shutting down.
+//
+// Deprecated: use Ready, liveness is served by AdminHandler.
+func (s *Server) Healthy() bool {
+	return s.Ready()
+}
+
+// Pause turns new clients away with the paused rejection message, e.g. for
+// maintenance or manual load shedding. In-flight connections are served to
+// the end and the listener stays open.
+func (s *Server) Pause() {
+	if !s.paused.Swap(true) {
+		s.logger.Info("Server paused, rejecting new connections")
+	}
+}
+
+// Resume accepts new connections again after Pause
+func (s *Server) Resume() {
+	if s.paused.Swap(false) {
+		s.logger.Info("Server resumed, accepting new connections")
+	}
+}
+
+// Paused reports whether new connections are turned away by Pause
+func (s *Server) Paused() bool {
+	return s.paused.Load()
+}
+
 // Shutdown gracefully stops the server
 func (s *Server) Shutdown() {
 	s.shutdownOnce.Do(func() {
 		s.logger.Info("Shutting down server...")
+		s.stopping.Store(true)
+		s.healthy.Store(false)
+
+		// Give load balancers time to stop routing new clients here
+		if s.config.PreStopDelay > 0 {
+			s.logger.Infof("Waiting %s before closing the listener...", s.config.PreStopDelay)
+			time.Sleep(s.config.PreStopDelay)
+		}
 
 		if err := s.listener.Close(); err != nil {
 			s.logger.Errorf("Error closing listener: %v", err)
 		}
 
+		// No new clients may be added to the wait group once the accept loop
+		// and the queue are done
+		<-s.acceptDone
+		s.stopQueue()
+		s.queueWg.Wait()
+		s.stopWorkers()
+
 		done := make(chan struct{})
 		go func() {
 			s.wg.Wait()
+			s.workersWg.Wait()
 			close(done)
 		}()
 
@@ -156,6 +642,7 @@ func (s *Server) Shutdown() {
 			s.logger.Info("All connections closed. Server stopped.")
 		case <-time.After(s.config.ShutdownTimeout):
 			s.logger.Warn("Shutdown timeout reached. Forcing termination.")
+			s.logStuckWorkers()
 		}
 
 		s.cancel()
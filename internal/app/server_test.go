@@ -2,21 +2,42 @@ package app_test
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 	"word-of-wisdom/internal/app"
+	"word-of-wisdom/internal/app/mocks"
 	"word-of-wisdom/internal/config"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/internal/quotes"
 	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
 )
 
 // MockHandler simulates request handling.
 type MockHandler struct{}
 
-func (m *MockHandler) HandleConnection(_ app.Conn) error {
+func (m *MockHandler) HandleConnection(_ context.Context, _ app.Conn) error {
 	// Simulate processing delay
 	time.Sleep(100 * time.Millisecond)
 	return nil
@@ -25,14 +46,14 @@ func (m *MockHandler) HandleConnection(_ app.Conn) error {
 // MockHandlerWithError simulates a failing handler
 type MockHandlerWithError struct{}
 
-func (m *MockHandlerWithError) HandleConnection(_ app.Conn) error {
+func (m *MockHandlerWithError) HandleConnection(_ context.Context, _ app.Conn) error {
 	return errors.New("mock handler error")
 }
 
 // MockHandler simulates request handling.
 type MockHandlerWithPanic struct{}
 
-func (m *MockHandlerWithPanic) HandleConnection(_ app.Conn) error {
+func (m *MockHandlerWithPanic) HandleConnection(_ context.Context, _ app.Conn) error {
 	// Simulate processing delay
 	panic("hello panic")
 
@@ -136,12 +157,14 @@ func TestConnectionLimit(t *testing.T) {
 	// The last connection should be rejected due to maxConnections limit
 	conn, _ := net.Dial("tcp", port)
 
-	// Read response from the server (this is to check if the server rejected the connection)
-	buf := make([]byte, 1024)
-	_, err := conn.Read(buf)
-	if err == nil {
-		t.Fatal("Expected connection to be rejected due to maxConnections limit")
-	}
+	// The server explains the rejection and then closes the connection
+	reader := bufio.NewReader(conn)
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, app.MsgOnManyReq, response, "Expected connection to be rejected due to maxConnections limit")
+
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "Expected the server to close the rejected connection")
 
 	conn.Close()
 
@@ -319,6 +342,69 @@ func TestPanicRecovery(t *testing.T) {
 	assert.Equal(t, app.MsgOnErrInternal, response, "Server should handle panics gracefully")
 }
 
+// TestPanicRecoveryInGenerateChallenge ensures a panicking PoW is recovered and later connections are served
+func TestPanicRecoveryInGenerateChallenge(t *testing.T) {
+	port := "localhost:8110"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 5,
+	}
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().GenerateChallenge().Panic("challenge generation failed").Once()
+	mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234").Once()
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "solution-1234").Return(true)
+
+	log, hook := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"recovered"}), mockPoW)
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	assert.NoError(t, err, "Client should be able to connect")
+	defer conn.Close()
+
+	response, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err, "Should receive response from server")
+	assert.Equal(t, app.MsgOnErrInternal, response, "Server should handle panics gracefully")
+
+	var panicLog *logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if strings.HasPrefix(entry.Message, "Panic recovered") {
+			panicLog = entry
+		}
+	}
+	if panicLog == nil {
+		t.Fatal("Panic was not logged")
+	}
+	assert.Equal(t, logrus.ErrorLevel, panicLog.Level)
+	assert.Contains(t, panicLog.Message, "challenge generation failed")
+	assert.Contains(t, panicLog.Message, "GenerateChallenge", "Stack trace should include the panicking call")
+
+	next, err := net.Dial("tcp", port)
+	assert.NoError(t, err, "Client should be able to connect after a panic")
+	defer next.Close()
+
+	reader := bufio.NewReader(next)
+	readVersion(t, reader)
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixChallenge+"challenge-1234\n", challenge)
+	_, err = next.Write([]byte("solution-1234\n"))
+	assert.NoError(t, err)
+	quote, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixQuote+"recovered\n", quote)
+}
+
 // TestRateLimiting ensures that rate limiting works as expected
 func TestRateLimiting(t *testing.T) {
 	port := "localhost:8089"
@@ -352,11 +438,2363 @@ func TestRateLimiting(t *testing.T) {
 	res2, _ := buf2.ReadString('\n')
 	res3, _ := buf3.ReadString('\n')
 
+	// Connections are handled concurrently, so any one of them may hit the limit
+	assert.ElementsMatch(t, []string{"", "", app.MsgOnManyReq}, []string{res1, res2, res3})
+
+	conn1.Close()
+	conn2.Close()
+	conn3.Close()
+}
+
+// dialFrom connects to the server using the given loopback source address
+func dialFrom(t *testing.T, localIP, addr string) net.Conn {
+	t.Helper()
+
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(localIP)}}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect from %s: %v", localIP, err)
+	}
+	return conn
+}
+
+// TestSubnetRateLimiting ensures clients of the same subnet share a limit while other subnets are unaffected
+func TestSubnetRateLimiting(t *testing.T) {
+	port := "127.0.0.1:8090"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 5,
+		SubnetMask:          24,
+		SubnetRateLimit:     2,
+	}
+
+	server := app.NewServer(cfg, logger.GetLogger(), &MockHandler{})
+
+	go server.Start()
+	defer server.Shutdown()
+
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	// Three different IPs from the same /24 and one from another /24
+	conn1 := dialFrom(t, "127.0.0.1", port)
+	conn2 := dialFrom(t, "127.0.0.2", port)
+	conn3 := dialFrom(t, "127.0.0.3", port)
+	conn4 := dialFrom(t, "127.0.1.1", port)
+
+	res1, _ := bufio.NewReader(conn1).ReadString('\n')
+	res2, _ := bufio.NewReader(conn2).ReadString('\n')
+	res3, _ := bufio.NewReader(conn3).ReadString('\n')
+	res4, _ := bufio.NewReader(conn4).ReadString('\n')
+
 	assert.Equal(t, "", res1)
 	assert.Equal(t, "", res2)
-	assert.Equal(t, app.MsgOnManyReq, res3)
+	assert.Equal(t, app.MsgOnManyReq, res3, "Third IP from the same subnet should be limited")
+	assert.Equal(t, "", res4, "IP from another subnet should not be limited")
 
 	conn1.Close()
 	conn2.Close()
 	conn3.Close()
+	conn4.Close()
+}
+
+// TestPreStopDelay ensures the listener stays open for the pre-stop delay after shutdown is triggered
+func TestPreStopDelay(t *testing.T) {
+	port := "localhost:8091"
+	preStopDelay := 300 * time.Millisecond
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 5,
+		PreStopDelay:        preStopDelay,
+	}
+
+	server := app.NewServer(cfg, logger.GetLogger(), &MockHandler{})
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+	assert.True(t, server.Healthy())
+
+	go server.Shutdown()
+	time.Sleep(50 * time.Millisecond)
+
+	// Health is flipped immediately but the listener is still open
+	assert.False(t, server.Healthy())
+	conn, err := net.Dial("tcp", port)
+	assert.NoError(t, err, "Listener should stay open during the pre-stop delay")
+	if conn != nil {
+		conn.Close()
+	}
+
+	time.Sleep(preStopDelay)
+
+	_, err = net.Dial("tcp", port)
+	assert.Error(t, err, "Listener should be closed after the pre-stop delay")
+}
+
+// TestCleanupOnHandlerError ensures connection cleanup runs even when the handler fails
+func TestCleanupOnHandlerError(t *testing.T) {
+	port := "localhost:8092"
+	clientCount := 10
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: clientCount,
+	}
+
+	server := app.NewServer(cfg, logger.GetLogger(), &MockHandlerWithError{})
+
+	go server.Start()
+	defer server.Shutdown()
+
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+	baseline := runtime.NumGoroutine()
+
+	var wg sync.WaitGroup
+	for i := 0; i < clientCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", port)
+			if err != nil {
+				t.Errorf("Failed to connect to server: %v", err)
+				return
+			}
+			defer conn.Close()
+
+			// The server closes the connection once the handler returns
+			_, _ = io.ReadAll(conn)
+		}()
+	}
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		return server.ActiveConnections() == 0
+	}, time.Second, 10*time.Millisecond, "All semaphore slots should be released")
+
+	// Poll without helpers: assert.Eventually runs the condition in its own goroutine
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "Connection goroutines should not leak")
+}
+
+// MockHandlerStuck blocks until released, ignoring shutdown
+type MockHandlerStuck struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *MockHandlerStuck) HandleConnection(_ context.Context, _ app.Conn) error {
+	m.started <- struct{}{}
+	<-m.release
+	return nil
+}
+
+// TestWorkerPool ensures connections are served by a fixed pool of workers
+func TestWorkerPool(t *testing.T) {
+	port := "localhost:8093"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 10,
+		WorkerPoolSize:      2,
+	}
+
+	server := app.NewServer(cfg, logger.GetLogger(), &MockHandler{})
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", port)
+			if err != nil {
+				t.Errorf("Failed to connect to server: %v", err)
+				return
+			}
+			defer conn.Close()
+
+			// The connection is closed once a worker has handled it
+			_, _ = io.ReadAll(conn)
+		}()
+	}
+	wg.Wait()
+
+	server.Shutdown()
+	assert.Equal(t, 0, server.ActiveConnections())
+}
+
+// TestWorkerPoolStuckShutdown ensures shutdown returns within the timeout and reports stuck workers
+func TestWorkerPoolStuckShutdown(t *testing.T) {
+	port := "localhost:8094"
+	shutdownTimeout := 200 * time.Millisecond
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     shutdownTimeout,
+		RateLimitEvery100MS: 5,
+		WorkerPoolSize:      1,
+	}
+
+	log, hook := logtest.NewNullLogger()
+	handler := &MockHandlerStuck{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(handler.release)
+
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	<-handler.started
+
+	start := time.Now()
+	server.Shutdown()
+	assert.Less(t, time.Since(start), shutdownTimeout+500*time.Millisecond, "Shutdown should not wait for a stuck worker")
+
+	var reported bool
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "Worker 0 did not finish") {
+			reported = true
+		}
+	}
+	assert.True(t, reported, "Stuck worker should be logged")
+}
+
+// deadlineHandler reports the deadline of every connection, then blocks until released
+type deadlineHandler struct {
+	deadlines chan time.Time
+	release   chan struct{}
+}
+
+func (h *deadlineHandler) HandleConnection(ctx context.Context, _ app.Conn) error {
+	deadline, _ := ctx.Deadline()
+	h.deadlines <- deadline
+	<-h.release
+	return nil
+}
+
+// TestWorkerPoolQueuedDeadline ensures the connection timeout includes the wait for a free worker
+func TestWorkerPoolQueuedDeadline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      2,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 5,
+		WorkerPoolSize:      1,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := &deadlineHandler{deadlines: make(chan time.Time, 2), release: make(chan struct{})}
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	busy, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer busy.Close()
+	<-handler.deadlines
+
+	// The second connection waits for the only worker
+	dialed := time.Now()
+	queued, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer queued.Close()
+
+	time.Sleep(500 * time.Millisecond)
+	close(handler.release)
+
+	deadline := <-handler.deadlines
+	assert.WithinRange(t, deadline, dialed, dialed.Add(cfg.ConnectionTimeout+200*time.Millisecond), "The deadline should run from admission, not from the worker picking the connection up")
+}
+
+// TestWorkerPoolLargerSemaphore ensures a semaphore admitting more than
+// MaxConnections cannot block the accept loop on a full worker queue
+func TestWorkerPoolLargerSemaphore(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      1,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     200 * time.Millisecond,
+		RateLimitEvery100MS: 10,
+		WorkerPoolSize:      1,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := &MockHandlerStuck{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(handler.release)
+
+	server := app.NewServer(cfg, log, handler, app.WithSemaphore(app.NewWeightedSemaphore(10, 1)))
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	// The first connection occupies the only worker, the second fills the queue
+	dial()
+	<-handler.started
+	dial()
+
+	for i := 0; i < 2; i++ {
+		conn := dial()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		response, err := io.ReadAll(conn)
+		assert.NoError(t, err, "Connection beyond the queue should be rejected rather than stall the accept loop")
+		assert.Equal(t, protocol.PrefixError+app.DefaultManyReqText+"\n", string(response))
+	}
+	assert.Equal(t, uint64(2), server.OutcomeCounts()[app.OutcomeCapacityRejected])
+}
+
+// TestRejectionLogSampling ensures a flood of rejections emits a bounded number
+// of log lines followed by a summary of the suppressed ones
+func TestRejectionLogSampling(t *testing.T) {
+	port := "localhost:8095"
+	sampleWindow := 300 * time.Millisecond
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      1,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     200 * time.Millisecond,
+		RateLimitEvery100MS: 5,
+		LogSampleLimit:      3,
+		LogSampleWindow:     sampleWindow,
+	}
+
+	log, hook := logtest.NewNullLogger()
+	handler := &MockHandlerStuck{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(handler.release)
+
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	<-handler.started
+
+	reject := func() {
+		c, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		_, _ = io.ReadAll(c)
+		_ = c.Close()
+	}
+
+	const flood = 30
+	for i := 0; i < flood; i++ {
+		reject()
+	}
+
+	// The summary is logged once the window has passed, without a further rejection
+	count := func() (rejections, summaries int) {
+		for _, entry := range hook.AllEntries() {
+			switch {
+			case strings.Contains(entry.Message, "Too many connections"):
+				rejections++
+			case strings.Contains(entry.Message, "similar messages suppressed"):
+				summaries++
+				assert.Contains(t, entry.Message, "27 similar messages suppressed")
+				assert.Equal(t, "connection_rejected", entry.Data["event"])
+			}
+		}
+		return rejections, summaries
+	}
+	assert.Eventually(t, func() bool {
+		_, summaries := count()
+		return summaries > 0
+	}, 3*sampleWindow, 10*time.Millisecond, "Suppressed rejections should be summarized")
+
+	time.Sleep(sampleWindow)
+	rejections, summaries := count()
+	assert.Equal(t, cfg.LogSampleLimit, rejections, "Rejections should be sampled")
+	assert.Equal(t, 1, summaries, "Suppressed rejections should be summarized once")
+}
+
+// MockHandlerSequence records the conn_seq log field of every connection
+type MockHandlerSequence struct {
+	mu   sync.Mutex
+	seqs map[uint64]bool
+}
+
+func (m *MockHandlerSequence) HandleConnection(ctx context.Context, _ app.Conn) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seqs[logger.FromContext(ctx).Data["conn_seq"].(uint64)] = true
+	return nil
+}
+
+// TestConnectionSequence ensures connections are numbered 1 through N without gaps
+func TestConnectionSequence(t *testing.T) {
+	port := "localhost:8096"
+	const connections = 100
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      connections,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 2 * connections,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	handler := &MockHandlerSequence{seqs: make(map[uint64]bool)}
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	for i := 0; i < connections; i++ {
+		conn, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		_, _ = io.ReadAll(conn) // Wait until the server is done with the client
+		_ = conn.Close()
+	}
+
+	server.Shutdown()
+
+	assert.Equal(t, uint64(connections), server.ConnectionCount())
+	for seq := uint64(1); seq <= connections; seq++ {
+		assert.True(t, handler.seqs[seq], "Missing sequence number %d", seq)
+	}
+	assert.Len(t, handler.seqs, connections)
+}
+
+// TestDisconnectSummary ensures a summary with the connection metrics is logged on disconnect
+func TestDisconnectSummary(t *testing.T) {
+	port := "localhost:8097"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 5,
+	}
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234")
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "solution-1234").Return(true)
+
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"summary"}), mockPoW)
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+
+	version, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("solution-1234\n"))
+	assert.NoError(t, err)
+	quote, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_ = conn.Close()
+
+	server.Shutdown()
+
+	var summary map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("Invalid JSON log line %q: %v", line, err)
+		}
+		if fields["msg"] == "Client disconnected" {
+			summary = fields
+		}
+	}
+
+	if summary == nil {
+		t.Fatal("Disconnect summary was not logged")
+	}
+	assert.Equal(t, "info", summary["level"])
+	assert.NotEmpty(t, summary["session_id"])
+	assert.Equal(t, "127.0.0.1", summary["client_ip"])
+	assert.Contains(t, summary, "duration_ms")
+	assert.Equal(t, true, summary["pow_solved"])
+	assert.Equal(t, true, summary["quote_delivered"])
+	assert.Equal(t, float64(len("solution-1234\n")), summary["bytes_read"])
+	assert.Equal(t, float64(len(version)+len(challenge)+len(quote)), summary["bytes_written"])
+	assert.Equal(t, app.CloseReasonNormal, summary["close_reason"])
+}
+
+// TestOutcomeCounts ensures every connection is counted under its outcome
+func TestOutcomeCounts(t *testing.T) {
+	port := "localhost:8098"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      1,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 3,
+	}
+
+	log, _ := logtest.NewNullLogger()
+
+	mockPoW := mocks.NewPowChallenge(t)
+	mockPoW.EXPECT().GenerateChallenge().Return("challenge-1234")
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "good").Return(true)
+	mockPoW.EXPECT().ValidateChallenge("challenge-1234", "bad").Return(false)
+
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"outcome"}), mockPoW)
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+
+	// waitIdle waits until the server released the connection slot
+	waitIdle := func() {
+		for deadline := time.Now().Add(2 * time.Second); server.ActiveConnections() > 0; {
+			if time.Now().After(deadline) {
+				t.Fatal("Connection slot was not released")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// answer solves the challenge with the given solution and drains the reply
+	answer := func(solution string) {
+		conn, reader := dial()
+		defer conn.Close()
+		_, _ = reader.ReadString('\n')
+		_, _ = fmt.Fprintln(conn, solution)
+		_, _ = io.ReadAll(reader)
+	}
+
+	// A client holding the only slot, then leaving without an answer
+	holder, reader := dial()
+	_, _ = reader.ReadString('\n')
+
+	rejected, _ := dial()
+	_, _ = io.ReadAll(rejected)
+	_ = rejected.Close()
+
+	_ = holder.Close()
+	waitIdle()
+
+	answer("good")
+	waitIdle()
+	answer("bad")
+	waitIdle()
+
+	// The burst of 3 is used up by now
+	limited, _ := dial()
+	_, _ = io.ReadAll(limited)
+	_ = limited.Close()
+	waitIdle()
+
+	assert.Equal(t, map[string]uint64{
+		app.OutcomeQuoteSent:          1,
+		app.OutcomeInvalidPoW:         1,
+		app.OutcomeRateLimited:        1,
+		app.OutcomeCapacityRejected:   1,
+		app.OutcomePaused:             0,
+		app.OutcomeMaintenance:        0,
+		app.OutcomeProxyHeaderInvalid: 0,
+		app.OutcomeError:              1,
+		app.OutcomePanic:              0,
+	}, server.OutcomeCounts())
+}
+
+// TestQueueWaitTimeout ensures queued clients wait for a free slot at most QueueWaitTimeout
+func TestQueueWaitTimeout(t *testing.T) {
+	port := "localhost:8099"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      1,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 5,
+		MaxQueueDepth:       2,
+		QueueWaitTimeout:    100 * time.Millisecond,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	handler := &MockHandlerStuck{started: make(chan struct{}, 3), release: make(chan struct{})}
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	dial()
+	<-handler.started
+
+	// Both wait in the queue behind the first client
+	queued := []net.Conn{dial(), dial()}
+	time.Sleep(30 * time.Millisecond)
+
+	// Free the slot for one of them, the other one keeps waiting and times out
+	handler.release <- struct{}{}
+	<-handler.started
+
+	time.Sleep(150 * time.Millisecond)
+
+	var timedOut int
+	for _, conn := range queued {
+		_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		response, _ := bufio.NewReader(conn).ReadString('\n')
+		if response == app.MsgOnManyReq {
+			timedOut++
+		}
+	}
+	assert.Equal(t, 1, timedOut, "Exactly one queued client should time out")
+
+	close(handler.release)
+}
+
+// TestServerWithContext ensures a server without signal handling shuts down when its context is cancelled
+func TestServerWithContext(t *testing.T) {
+	port := "localhost:8100"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      1,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 5,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	server := app.NewServerWithContext(ctx, cfg, log, &MockHandler{})
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Start()
+		close(stopped)
+	}()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+	assert.True(t, server.Healthy(), "Server should be healthy after start")
+
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server did not stop after the context was cancelled")
+	}
+	assert.False(t, server.Healthy(), "Server should not be healthy after shutdown")
+
+	_, err := net.Dial("tcp", port)
+	assert.Error(t, err, "Server should not accept connections after shutdown")
+}
+
+// spoofedConn reports a fixed remote address
+type spoofedConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *spoofedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// spoofingListener assigns accepted connections the remote IPs sent on ips, in accept order
+type spoofingListener struct {
+	net.Listener
+	ips chan string
+}
+
+func (l *spoofingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &spoofedConn{Conn: conn, remoteAddr: &net.TCPAddr{IP: net.ParseIP(<-l.ips), Port: 40000}}, nil
+}
+
+// TestRateLimitingPerIP ensures every client IP gets its own limiter and one IP's limit does not affect another's
+func TestRateLimitingPerIP(t *testing.T) {
+	cfg := config.Config{
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 2,
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := &spoofingListener{Listener: inner, ips: make(chan string, 10)}
+
+	log, hook := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{})
+
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	dialAs := func(ip string) *bufio.Reader {
+		listener.ips <- ip
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect as %s: %v", ip, err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return bufio.NewReader(conn)
+	}
+
+	// The first IP exhausts its burst of 2, the second IP still has its own full burst
+	readers := []*bufio.Reader{dialAs("10.0.0.1"), dialAs("10.0.0.1"), dialAs("10.0.0.1"), dialAs("10.0.0.2"), dialAs("10.0.0.2")}
+
+	var responses []string
+	for _, reader := range readers {
+		res, _ := reader.ReadString('\n')
+		responses = append(responses, res)
+	}
+	assert.ElementsMatch(t, []string{"", "", app.MsgOnManyReq}, responses[:3])
+	assert.Equal(t, []string{"", ""}, responses[3:], "Second IP should not be limited by the first one")
+
+	var created []string
+	for _, entry := range hook.AllEntries() {
+		if ip, ok := strings.CutPrefix(entry.Message, "Created new rate limiter for IP: "); ok {
+			created = append(created, ip)
+		}
+	}
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2"}, created, "Each IP should get exactly one limiter")
+}
+
+// MockHandlerWithDifficulty reports the difficulty of its challenges
+type MockHandlerWithDifficulty struct {
+	MockHandlerStuck
+	difficulty int
+}
+
+func (m *MockHandlerWithDifficulty) Difficulty() int {
+	return m.difficulty
+}
+
+// TestStructuredRejections ensures rate-limit and capacity rejections carry a structured hint
+func TestStructuredRejections(t *testing.T) {
+	tests := []struct {
+		name           string
+		port           string
+		maxConnections int
+		rateLimit      int
+		want           protocol.Rejection
+	}{
+		{
+			name:           "rate limit",
+			port:           "localhost:8101",
+			maxConnections: 10,
+			rateLimit:      1,
+			want:           protocol.Rejection{Reason: protocol.RejectionRateLimited, Difficulty: 4, RetryAfterMS: 100},
+		},
+		{
+			name:           "capacity",
+			port:           "localhost:8102",
+			maxConnections: 1,
+			rateLimit:      10,
+			want:           protocol.Rejection{Reason: protocol.RejectionCapacity, Difficulty: 4, RetryAfterMS: 2000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Port:                 tt.port,
+				MaxConnections:       tt.maxConnections,
+				ConnectionTimeout:    2 * time.Second,
+				ShutdownTimeout:      time.Second,
+				RateLimitEvery100MS:  tt.rateLimit,
+				StructuredRejections: true,
+			}
+
+			log, _ := logtest.NewNullLogger()
+			handler := &MockHandlerWithDifficulty{
+				MockHandlerStuck: MockHandlerStuck{started: make(chan struct{}, 1), release: make(chan struct{})},
+				difficulty:       4,
+			}
+			server := app.NewServer(cfg, log, handler)
+
+			go server.Start()
+			defer server.Shutdown()
+			defer close(handler.release)
+			time.Sleep(100 * time.Millisecond) // Give server time to start
+
+			first, err := net.Dial("tcp", tt.port)
+			if err != nil {
+				t.Fatalf("Failed to connect to server: %v", err)
+			}
+			defer first.Close()
+			<-handler.started
+
+			second, err := net.Dial("tcp", tt.port)
+			if err != nil {
+				t.Fatalf("Failed to connect to server: %v", err)
+			}
+			defer second.Close()
+
+			line, _ := bufio.NewReader(second).ReadString('\n')
+			msg := protocol.ParseMessage(line)
+			assert.Equal(t, protocol.PrefixError, msg.Prefix)
+
+			rejection, ok := protocol.ParseRejection(msg.Payload)
+			assert.True(t, ok, "Expected a structured rejection, got %q", line)
+			assert.Equal(t, tt.want, rejection)
+		})
+	}
+}
+
+// TestWithSemaphore ensures the server admits connections through the configured semaphore
+func TestWithSemaphore(t *testing.T) {
+	port := "localhost:8103"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	handler := &MockHandlerStuck{started: make(chan struct{}, 2), release: make(chan struct{})}
+	// Every connection weighs 2 units out of 4, so only two are admitted
+	server := app.NewServer(cfg, log, handler, app.WithSemaphore(app.NewWeightedSemaphore(4, 2)))
+
+	go server.Start()
+	defer server.Shutdown()
+	defer close(handler.release)
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		defer conn.Close()
+		<-handler.started
+	}
+	assert.Equal(t, 2, server.ActiveConnections())
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	response, _ := bufio.NewReader(conn).ReadString('\n')
+	assert.Equal(t, app.MsgOnManyReq, response)
+}
+
+// TestLimiterCleanup ensures idle rate limiters are dropped and a limited IP starts over afterwards
+func TestLimiterCleanup(t *testing.T) {
+	idleTTL := time.Minute
+	cfg := config.Config{
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 1,
+		LimiterIdleTTL:      idleTTL,
+	}
+
+	var clock atomic.Int64
+	clock.Store(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC).UnixNano())
+	now := func() time.Time { return time.Unix(0, clock.Load()) }
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := &spoofingListener{Listener: inner, ips: make(chan string, 20)}
+
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{}, app.WithClock(now))
+
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	connectAs := func(ip string) string {
+		listener.ips <- ip
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect as %s: %v", ip, err)
+		}
+		defer conn.Close()
+		res, _ := bufio.NewReader(conn).ReadString('\n')
+		return res
+	}
+
+	for i := 1; i <= 10; i++ {
+		assert.Empty(t, connectAs(fmt.Sprintf("10.0.0.%d", i)))
+	}
+	assert.Equal(t, 10, server.LimiterCount())
+
+	// The frozen clock never refills the burst of 1
+	assert.Equal(t, app.MsgOnManyReq, connectAs("10.0.0.1"))
+
+	assert.Zero(t, server.CleanupLimiters(), "Limiters should not be removed before the TTL")
+
+	clock.Add(int64(idleTTL + time.Second))
+	assert.Equal(t, 10, server.CleanupLimiters())
+	assert.Equal(t, 0, server.LimiterCount())
+
+	assert.Empty(t, connectAs("10.0.0.1"), "Limited IP should start over after cleanup")
+	assert.Equal(t, 1, server.LimiterCount())
+}
+
+// TestMaxTrackedIPs ensures the number of rate limiters stays capped and the least recently used IP is evicted
+func TestMaxTrackedIPs(t *testing.T) {
+	cfg := config.Config{
+		MaxConnections:      100,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 1,
+		MaxTrackedIPs:       3,
+	}
+
+	// A frozen clock never refills the burst of 1
+	frozen := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return frozen }
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := &spoofingListener{Listener: inner, ips: make(chan string, 20)}
+
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{}, app.WithClock(now))
+
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	connectAs := func(ip string) string {
+		listener.ips <- ip
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect as %s: %v", ip, err)
+		}
+		defer conn.Close()
+		res, _ := bufio.NewReader(conn).ReadString('\n')
+		return res
+	}
+
+	for i := 1; i <= 3; i++ {
+		assert.Empty(t, connectAs(fmt.Sprintf("10.0.0.%d", i)))
+	}
+	assert.Equal(t, 3, server.LimiterCount())
+
+	// Using 10.0.0.1 again leaves 10.0.0.2 as the least recently used
+	assert.Equal(t, app.MsgOnManyReq, connectAs("10.0.0.1"))
+
+	assert.Empty(t, connectAs("10.0.0.4"))
+	assert.Equal(t, 3, server.LimiterCount())
+	assert.Equal(t, app.MsgOnManyReq, connectAs("10.0.0.1"), "Recently used IP should keep its limiter")
+	assert.Empty(t, connectAs("10.0.0.2"), "Evicted IP should start over with a full burst")
+
+	for i := 5; i <= 20; i++ {
+		assert.Empty(t, connectAs(fmt.Sprintf("10.0.0.%d", i)))
+		assert.LessOrEqual(t, server.LimiterCount(), 3)
+	}
+}
+
+// TestConnectionRejectionMessages ensures clients receive the configured rejection messages
+func TestConnectionRejectionMessages(t *testing.T) {
+	messages := config.ConnectionRejectionMessages{
+		RateLimit: "Slow down, seeker of wisdom.",
+		Capacity:  "The oracle is busy.",
+		Internal:  "The oracle stumbled.",
+	}
+
+	tests := []struct {
+		name           string
+		port           string
+		maxConnections int
+		rateLimit      int
+		panics         bool
+		want           string
+	}{
+		{name: "rate limit", port: "localhost:8104", maxConnections: 10, rateLimit: 1, want: messages.RateLimit},
+		{name: "capacity", port: "localhost:8105", maxConnections: 1, rateLimit: 10, want: messages.Capacity},
+		{name: "internal", port: "localhost:8106", maxConnections: 10, rateLimit: 10, panics: true, want: messages.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Port:                        tt.port,
+				MaxConnections:              tt.maxConnections,
+				ConnectionTimeout:           2 * time.Second,
+				ShutdownTimeout:             time.Second,
+				RateLimitEvery100MS:         tt.rateLimit,
+				ConnectionRejectionMessages: messages,
+			}
+
+			log, _ := logtest.NewNullLogger()
+			var handler app.Handler = &MockHandlerWithPanic{}
+			stuck := &MockHandlerStuck{started: make(chan struct{}, 1), release: make(chan struct{})}
+			if !tt.panics {
+				handler = stuck
+			}
+			server := app.NewServer(cfg, log, handler)
+
+			go server.Start()
+			defer server.Shutdown()
+			defer close(stuck.release)
+			time.Sleep(100 * time.Millisecond) // Give server time to start
+
+			if !tt.panics {
+				first, err := net.Dial("tcp", tt.port)
+				if err != nil {
+					t.Fatalf("Failed to connect to server: %v", err)
+				}
+				defer first.Close()
+				<-stuck.started
+			}
+
+			conn, err := net.Dial("tcp", tt.port)
+			if err != nil {
+				t.Fatalf("Failed to connect to server: %v", err)
+			}
+			defer conn.Close()
+
+			response, _ := bufio.NewReader(conn).ReadString('\n')
+			assert.Equal(t, protocol.PrefixError+tt.want+"\n", response)
+		})
+	}
+}
+
+// TestProbeLogging ensures connect-and-drop probes are logged at debug while real client errors stay errors
+func TestProbeLogging(t *testing.T) {
+	port := "localhost:8107"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+		ProbeLogging:        config.ProbeLogDebug,
+	}
+
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(2))
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	levelsOf := func(message string) []logrus.Level {
+		var levels []logrus.Level
+		for _, entry := range hook.AllEntries() {
+			if strings.Contains(entry.Message, message) {
+				levels = append(levels, entry.Level)
+			}
+		}
+		return levels
+	}
+
+	probe, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	_ = probe.Close()
+
+	assert.Eventually(t, func() bool { return len(levelsOf("Client disconnected")) == 1 }, time.Second, 10*time.Millisecond)
+	for _, entry := range hook.AllEntries() {
+		assert.NotEqual(t, logrus.ErrorLevel, entry.Level, "Probe should not be logged as an error: %s", entry.Message)
+	}
+	assert.Equal(t, []logrus.Level{logrus.DebugLevel}, levelsOf("sent no data"))
+	assert.Equal(t, []logrus.Level{logrus.DebugLevel}, levelsOf("Client disconnected"))
+
+	// A client that engaged the protocol and then dropped is still an error
+	client, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	_, _ = bufio.NewReader(client).ReadString('\n')
+	_, _ = client.Write([]byte("partial"))
+	_ = client.Close()
+
+	assert.Eventually(t, func() bool { return len(levelsOf("Error handling client")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []logrus.Level{logrus.ErrorLevel}, levelsOf("Error handling client"))
+}
+
+// TestSilentIPs ensures connections from silent IPs emit no info logs while other clients still do
+func TestSilentIPs(t *testing.T) {
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+		SilentIPs:           []string{"10.0.0.100"},
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener := &spoofingListener{Listener: inner, ips: make(chan string, 2)}
+
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+	server := app.NewServer(cfg, log, &MockHandlerWithError{})
+
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	entriesOf := func(ip string) []*logrus.Entry {
+		var entries []*logrus.Entry
+		for _, entry := range hook.AllEntries() {
+			if entry.Data["client_ip"] == ip {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	for _, ip := range []string{"10.0.0.100", "10.0.0.1"} {
+		listener.ips <- ip
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect as %s: %v", ip, err)
+		}
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+		_ = conn.Close()
+	}
+
+	// Both clients fail in the handler, which is logged as an error either way
+	assert.Eventually(t, func() bool {
+		return len(entriesOf("10.0.0.100")) > 0 && len(entriesOf("10.0.0.1")) > 2
+	}, time.Second, 10*time.Millisecond)
+
+	for _, entry := range entriesOf("10.0.0.100") {
+		assert.LessOrEqual(t, entry.Level, logrus.WarnLevel, "Silent IP logged at %s: %s", entry.Level, entry.Message)
+	}
+
+	var infoLines int
+	for _, entry := range entriesOf("10.0.0.1") {
+		if entry.Level == logrus.InfoLevel {
+			infoLines++
+		}
+	}
+	assert.Positive(t, infoLines, "Other clients should still be logged at info level")
+}
+
+// MockHandlerNamed writes its name once released
+type MockHandlerNamed struct {
+	name    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *MockHandlerNamed) HandleConnection(_ context.Context, conn app.Conn) error {
+	m.started <- struct{}{}
+	<-m.release
+	_, err := fmt.Fprintln(conn, m.name)
+	return err
+}
+
+// TestSetHandler ensures new connections use a swapped handler while in-flight ones finish with the old one
+func TestSetHandler(t *testing.T) {
+	port := "localhost:8108"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+
+	oldHandler := &MockHandlerNamed{name: "old", started: make(chan struct{}, 1), release: make(chan struct{})}
+	newHandler := &MockHandlerNamed{name: "new", started: make(chan struct{}, 1), release: make(chan struct{})}
+	close(newHandler.release)
+
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, oldHandler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	inFlight, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer inFlight.Close()
+	<-oldHandler.started
+
+	server.SetHandler(newHandler)
+	assert.Same(t, newHandler, server.Handler())
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	response, _ := bufio.NewReader(conn).ReadString('\n')
+	assert.Equal(t, "new\n", response, "New connections should use the new handler")
+
+	close(oldHandler.release)
+	response, _ = bufio.NewReader(inFlight).ReadString('\n')
+	assert.Equal(t, "old\n", response, "In-flight connection should finish with the old handler")
+}
+
+// TestMaxBytesPerConnection ensures clients sending more than the limit are disconnected
+func TestMaxBytesPerConnection(t *testing.T) {
+	port := "localhost:8109"
+
+	cfg := config.Config{
+		Port:                  port,
+		MaxConnections:        10,
+		ConnectionTimeout:     2 * time.Second,
+		ShutdownTimeout:       time.Second,
+		RateLimitEvery100MS:   10,
+		MaxBytesPerConnection: 512,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(2))
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	readVersion(t, reader)
+	challenge, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(challenge, protocol.PrefixChallenge))
+
+	// Stay below the per-message limit while exceeding the per-connection one
+	chunk := strings.Repeat("a", 100)
+	for i := 0; i < 6; i++ {
+		_, err := conn.Write([]byte(chunk))
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	response, _ := reader.ReadString('\n')
+	assert.Equal(t, protocol.PrefixError+app.DataLimitMsg+"\n", response)
+}
+
+// TestInvalidDelayShutdown ensures shutdown cuts the delay of an invalid PoW response short
+func TestInvalidDelayShutdown(t *testing.T) {
+	port := "localhost:8111"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   10 * time.Second,
+		ShutdownTimeout:     5 * time.Second,
+		RateLimitEvery100MS: 10,
+	}
+
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{"quote"}),
+		pow.NewSHA256PoW(4),
+		app.WithInvalidDelay(5*time.Second, 5*time.Second),
+	)
+	server := app.NewServer(cfg, log, handler)
+
+	go server.Start()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	readVersion(t, reader)
+	_, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("invalid\n"))
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond) // Let the handler start delaying
+
+	start := time.Now()
+	server.Shutdown()
+	assert.Less(t, time.Since(start), time.Second, "Shutdown should not wait for the delay")
+
+	response, _ := reader.ReadString('\n')
+	assert.Equal(t, protocol.PrefixError+app.InvalidMsg+"\n", response)
+}
+
+// TestConnectionStates ensures connections report every state and are listed by the admin API while active
+func TestConnectionStates(t *testing.T) {
+	port := "localhost:8112"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+
+	difficulty := 1
+	events := make(chan app.ConnectionEvent, 16)
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(difficulty))
+	server := app.NewServer(cfg, log, handler, app.WithConnectionEvents(events))
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	conn, err := net.Dial("tcp", port)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	readVersion(t, reader)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	challenge := strings.TrimPrefix(strings.TrimSpace(line), protocol.PrefixChallenge)
+
+	// The slow client has not answered yet
+	time.Sleep(50 * time.Millisecond)
+	recorder := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/connections", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var listed []map[string]any
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listed))
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, "solving", listed[0]["state"])
+		assert.Equal(t, "127.0.0.1", listed[0]["client_ip"])
+		assert.NotEmpty(t, listed[0]["session_id"])
+	}
+
+	solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+	assert.NoError(t, err)
+	_, err = fmt.Fprintln(conn, solution)
+	assert.NoError(t, err)
+	_, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+
+	var states []app.ConnState
+	timeout := time.After(time.Second)
+	for len(states) == 0 || states[len(states)-1] != app.StateDone {
+		select {
+		case event := <-events:
+			assert.Equal(t, listed[0]["session_id"], event.SessionID)
+			states = append(states, event.State)
+		case <-timeout:
+			t.Fatalf("Connection did not finish, states so far: %v", states)
+		}
+	}
+
+	assert.Equal(t, []app.ConnState{app.StateNew, app.StateChallenging, app.StateSolving, app.StateDelivering, app.StateDone}, states)
+	assert.Empty(t, server.Connections())
+}
+
+// TestAdminStats ensures the admin API shows the effective configuration without secrets
+func TestAdminStats(t *testing.T) {
+	cfg := config.Config{MaxConnections: 10, RateLimitEvery100MS: 10, ChallengeSalt: "super-secret-salt"}
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{})
+
+	recorder := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.NotContains(t, recorder.Body.String(), "super-secret-salt", "The salt must not be exposed")
+
+	var effective config.Config
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &effective))
+	assert.Equal(t, 10, effective.MaxConnections)
+	assert.Equal(t, app.DefaultManyReqText, effective.ConnectionRejectionMessages.RateLimit, "Defaults should be filled in")
+}
+
+// TestAdminQuotesCSV ensures the admin API exports the quotes of the handler as CSV
+func TestAdminQuotesCSV(t *testing.T) {
+	cfg := config.Config{MaxConnections: 10, RateLimitEvery100MS: 10}
+	log, _ := logtest.NewNullLogger()
+
+	provider := quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{{Text: "Veni, vidi, vici", Author: "Julius Caesar"}})
+	server := app.NewServer(cfg, log, app.NewHandler(provider, pow.NewSHA256PoW(1)))
+
+	recorder := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/quotes.csv", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "text,author,category\r\n\"Veni, vidi, vici\",Julius Caesar,\r\n", recorder.Body.String())
+
+	// Handlers without a quote provider cannot be exported
+	server.SetHandler(&MockHandler{})
+	recorder = httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/quotes.csv", nil))
+	assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+}
+
+// TestPauseResume ensures a paused server turns new clients away while in-flight ones finish, until resumed
+func TestPauseResume(t *testing.T) {
+	port := "localhost:8113"
+
+	cfg := config.Config{
+		Port:                port,
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+
+	inFlight := &MockHandlerNamed{name: "in-flight", started: make(chan struct{}, 1), release: make(chan struct{})}
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, inFlight)
+
+	go server.Start()
+	defer server.Shutdown()
+	time.Sleep(100 * time.Millisecond) // Give server time to start
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", port)
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+
+	busy, busyReader := dial()
+	defer busy.Close()
+	<-inFlight.started
+
+	server.Pause()
+	assert.True(t, server.Paused())
+	assert.False(t, server.Healthy(), "Paused server should not report healthy")
+
+	rejected, reader := dial()
+	response, _ := reader.ReadString('\n')
+	rejected.Close()
+	assert.Equal(t, app.MsgOnPaused, response)
+	assert.Equal(t, uint64(1), server.OutcomeCounts()[app.OutcomePaused])
+	assert.Zero(t, server.OutcomeCounts()[app.OutcomeCapacityRejected], "Paused rejections are not capacity rejections")
+
+	close(inFlight.release)
+	response, _ = busyReader.ReadString('\n')
+	assert.Equal(t, "in-flight\n", response, "In-flight connection should finish while paused")
+
+	server.Resume()
+	assert.False(t, server.Paused())
+	assert.True(t, server.Healthy())
+
+	served, reader := dial()
+	defer served.Close()
+	<-inFlight.started
+	response, _ = reader.ReadString('\n')
+	assert.Equal(t, "in-flight\n", response, "Resumed server should serve new clients")
+}
+
+// Test that the goroutine guard warns once the goroutine count exceeds the limit
+func TestGoroutineGuard(t *testing.T) {
+	leaked := 50
+	cfg := config.Config{
+		MaxConnections:         10,
+		ConnectionTimeout:      time.Second,
+		ShutdownTimeout:        time.Second,
+		RateLimitEvery100MS:    10,
+		MaxGoroutines:          runtime.NumGoroutine() + leaked/2,
+		GoroutineCheckInterval: 10 * time.Millisecond,
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	log, hook := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{})
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	warned := func() bool {
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "Goroutine count exceeds") {
+				return true
+			}
+		}
+		return false
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, warned(), "No warning expected below the limit")
+
+	// Leak goroutines deliberately until the end of the test
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < leaked; i++ {
+		go func() { <-stop }()
+	}
+
+	assert.Eventually(t, warned, time.Second, 10*time.Millisecond, "Expected a goroutine leak warning")
+	assert.Greater(t, server.CheckGoroutines(), cfg.MaxGoroutines)
+}
+
+// TestStatsDClient ensures a served connection is reported to a StatsD endpoint
+func TestStatsDClient(t *testing.T) {
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen for StatsD: %v", err)
+	}
+	defer statsd.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1))
+	server := app.NewServer(cfg, log, handler, app.WithStatsDClient(statsd.LocalAddr().String(), "wow"))
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	quote, err := wowclient.Fetch(context.Background(), listener.Addr().String())
+	assert.NoError(t, err)
+	assert.Equal(t, "quote", quote)
+
+	// The counters are flushed every second
+	expected := []string{
+		"wow.connections.total:1|c",
+		"wow.quotes.served:1|c",
+		"wow.connections.active:0|g",
+	}
+	var received []string
+	solveTimed := false
+	reported := func() bool {
+		for _, metric := range expected {
+			if !slices.Contains(received, metric) {
+				return false
+			}
+		}
+		return solveTimed
+	}
+
+	buf := make([]byte, 512)
+	_ = statsd.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for !reported() {
+		n, _, err := statsd.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Expected metrics %v, got %v: %v", expected, received, err)
+		}
+		metric := string(buf[:n])
+		if strings.HasPrefix(metric, "wow.pow.solve_time:") && strings.HasSuffix(metric, "|ms") {
+			solveTimed = true
+		}
+		received = append(received, metric)
+	}
+
+	// Later flushes report no new connections or quotes
+	received = received[:0]
+	_ = statsd.SetReadDeadline(time.Now().Add(1500 * time.Millisecond))
+	for !slices.Contains(received, "wow.connections.active:0|g") {
+		n, _, err := statsd.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Expected another flush, got %v: %v", received, err)
+		}
+		received = append(received, string(buf[:n]))
+	}
+	assert.Equal(t, []string{"wow.connections.active:0|g"}, received)
+}
+
+// TestIPState ensures a penalized IP can be inspected and reset through the admin API
+func TestIPState(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{"quote"}),
+		pow.NewSHA256PoW(1),
+		app.WithInvalidPenalty(1, 3),
+	)
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	validator := pow.NewSHA256PoW(1)
+
+	// firstLines connects, answers the challenge with an invalid solution and
+	// returns the lines received before the error
+	firstLines := func() []string {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		defer conn.Close()
+
+		var lines []string
+		reader := bufio.NewReader(conn)
+		readVersion(t, reader)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Failed to read challenge: %v", err)
+			}
+			lines = append(lines, strings.TrimSpace(line))
+			if strings.HasPrefix(line, protocol.PrefixChallenge) {
+				break
+			}
+		}
+
+		// At low difficulties a fixed answer is a valid solution now and then
+		challenge := protocol.ParseMessage(lines[len(lines)-1]).Payload
+		solution := "invalid"
+		for i := 0; validator.ValidateChallenge(challenge, solution); i++ {
+			solution = fmt.Sprintf("invalid-%d", i)
+		}
+
+		_, err = fmt.Fprintln(conn, solution)
+		assert.NoError(t, err)
+		reply, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, protocol.PrefixError+app.InvalidMsg+"\n", reply)
+		return lines
+	}
+
+	baseline := firstLines()
+	assert.Len(t, baseline, 1, "The baseline challenge is not announced")
+	assert.True(t, strings.HasPrefix(baseline[0], protocol.PrefixChallenge+"1:"), "Expected a baseline challenge, got %q", baseline[0])
+
+	state := server.GetIPState("127.0.0.1")
+	assert.Equal(t, 1, state.Failures)
+	assert.Equal(t, 2, state.Difficulty)
+	assert.True(t, state.RateLimiterTracked)
+	assert.Less(t, state.Tokens, float64(cfg.RateLimitEvery100MS))
+
+	penalized := firstLines()
+	assert.Equal(t, protocol.PrefixDifficulty+"2", penalized[0])
+	assert.True(t, strings.HasPrefix(penalized[1], protocol.PrefixChallenge+"2:"), "Expected a penalized challenge, got %q", penalized[1])
+
+	recorder := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/ips/127.0.0.1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var listed app.IPState
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listed))
+	assert.Equal(t, 2, listed.Failures)
+	assert.Equal(t, 3, listed.Difficulty)
+
+	recorder = httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/ips/127.0.0.1", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	assert.Equal(t, app.IPState{IP: "127.0.0.1", Tokens: float64(cfg.RateLimitEvery100MS), Difficulty: 1}, server.GetIPState("127.0.0.1"))
+
+	reset := firstLines()
+	assert.Len(t, reset, 1, "The challenge after a reset is not announced")
+	assert.True(t, strings.HasPrefix(reset[0], protocol.PrefixChallenge+"1:"), "Expected a baseline challenge after the reset, got %q", reset[0])
+
+	recorder = httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/ips/not-an-ip", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+// TestCommitmentAbandoned ensures clients leaving a revealed challenge unsolved are penalized
+func TestCommitmentAbandoned(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{"quote"}),
+		pow.NewSHA256PoW(1),
+		app.WithChallengeCommitment(),
+		app.WithInvalidPenalty(1, 3),
+	)
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	// peek commits to the challenge if asked to and leaves once it is revealed
+	peek := func(commit bool) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		readVersion(t, reader)
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(line, protocol.PrefixCommitment), "Expected a commitment, got %q", line)
+		if !commit {
+			return
+		}
+
+		_, err = fmt.Fprintln(conn, protocol.PrefixCommit)
+		assert.NoError(t, err)
+		line, err = reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(line, protocol.PrefixChallenge), "Expected the challenge, got %q", line)
+	}
+
+	// Leaving before committing reveals nothing and costs nothing
+	peek(false)
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, server.GetIPState("127.0.0.1").Failures)
+
+	peek(true)
+	assert.Eventually(t, func() bool {
+		return server.GetIPState("127.0.0.1").Failures == 1
+	}, time.Second, 10*time.Millisecond, "An abandoned challenge should count as a failure")
+	assert.Equal(t, 2, server.GetIPState("127.0.0.1").Difficulty)
+}
+
+// truncatingConn fails the write carrying a quote after sending half of it
+type truncatingConn struct {
+	net.Conn
+	writeErr error
+}
+
+func (c *truncatingConn) Write(p []byte) (int, error) {
+	if !bytes.Contains(p, []byte(protocol.PrefixQuote)) {
+		return c.Conn.Write(p)
+	}
+	n, _ := c.Conn.Write(p[:len(p)/2])
+	return n, c.writeErr
+}
+
+// truncatingListener wraps accepted connections into truncatingConn
+type truncatingListener struct {
+	net.Listener
+	writeErr error
+}
+
+func (l *truncatingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &truncatingConn{Conn: conn, writeErr: l.writeErr}, nil
+}
+
+// recordingHandler reports the errors returned by the wrapped handler
+type recordingHandler struct {
+	app.Handler
+	errs chan error
+}
+
+func (h *recordingHandler) HandleConnection(ctx context.Context, conn app.Conn) error {
+	err := h.Handler.HandleConnection(ctx, conn)
+	h.errs <- err
+	return err
+}
+
+// TestQuoteDeliveryFailed ensures a quote cut short by a write error is reported
+// as a failed delivery, classified by its cause, and never counted as sent
+func TestQuoteDeliveryFailed(t *testing.T) {
+	tests := []struct {
+		name         string
+		writeErr     error
+		disconnected bool
+	}{
+		{name: "client gone", writeErr: &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}, disconnected: true},
+		{name: "server error", writeErr: errors.New("write error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			listener := &truncatingListener{Listener: inner, writeErr: tt.writeErr}
+
+			cfg := config.Config{
+				MaxConnections:      10,
+				ConnectionTimeout:   2 * time.Second,
+				ShutdownTimeout:     time.Second,
+				RateLimitEvery100MS: 10,
+			}
+			log, _ := logtest.NewNullLogger()
+			handler := &recordingHandler{
+				Handler: app.NewHandler(quotes.NewRandomQuoteProvider([]string{"a quote long enough to be cut in half"}), pow.NewSHA256PoW(1)),
+				errs:    make(chan error, 1),
+			}
+			server := app.NewServer(cfg, log, handler)
+			go server.Serve(listener)
+			defer server.Shutdown()
+
+			conn, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			reader := bufio.NewReader(conn)
+			readVersion(t, reader)
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			challenge := strings.TrimSpace(strings.TrimPrefix(line, protocol.PrefixChallenge))
+			solution, err := wowclient.Solve(context.Background(), challenge, 1)
+			assert.NoError(t, err)
+			_, err = fmt.Fprintln(conn, solution)
+			assert.NoError(t, err)
+
+			err = <-handler.errs
+			assert.ErrorIs(t, err, app.ErrQuoteDeliveryFailed)
+			assert.ErrorIs(t, err, tt.writeErr)
+			assert.Equal(t, tt.disconnected, errors.Is(err, app.ErrClientDisconnected))
+
+			assert.Eventually(t, func() bool {
+				return server.OutcomeCounts()[app.OutcomeError] == 1
+			}, time.Second, 10*time.Millisecond)
+			assert.Zero(t, server.OutcomeCounts()[app.OutcomeQuoteSent], "A truncated quote should not count as sent")
+		})
+	}
+}
+
+// MockHandlerAddr reports the remote address and the first line of every connection
+type MockHandlerAddr struct {
+	conns chan string
+}
+
+func (m *MockHandlerAddr) HandleConnection(_ context.Context, conn app.Conn) error {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	m.conns <- conn.RemoteAddr().String() + " " + strings.TrimSpace(line)
+	return nil
+}
+
+// TestProxyProtocol ensures the client address of the PROXY header is used for
+// rate limiting and handed to the handler, and connections without it are
+// closed, counted and logged through the sampler
+func TestProxyProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+		ProxyProtocol:       true,
+		LogSampleLimit:      1,
+		LogSampleWindow:     time.Minute,
+	}
+	log, hook := logtest.NewNullLogger()
+	handler := &MockHandlerAddr{conns: make(chan string, 1)}
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	send := func(data string) net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		_, err = io.WriteString(conn, data)
+		assert.NoError(t, err)
+		return conn
+	}
+
+	send("PROXY TCP4 203.0.113.7 10.0.0.1 40000 9000\r\nsolution\n")
+	assert.Equal(t, "203.0.113.7:40000 solution", <-handler.conns)
+	assert.True(t, server.GetIPState("203.0.113.7").RateLimiterTracked)
+
+	send("PROXY UNKNOWN\r\nsolution\n")
+	select {
+	case conn := <-handler.conns:
+		assert.True(t, strings.HasPrefix(conn, "127.0.0.1:"), "UNKNOWN should keep the connection address, got %s", conn)
+	case <-time.After(time.Second):
+		t.Fatal("Connection with an UNKNOWN header was not handled")
+	}
+
+	for i := 0; i < 3; i++ {
+		conn := send("solution\n")
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF, "A connection without a header should be closed")
+	}
+	assert.Empty(t, handler.conns)
+	assert.Eventually(t, func() bool {
+		return server.OutcomeCounts()[app.OutcomeProxyHeaderInvalid] == 3
+	}, time.Second, 10*time.Millisecond, "Connections without a header should be counted")
+
+	var warnings int
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "Invalid PROXY header") {
+			warnings++
+		}
+	}
+	assert.Equal(t, cfg.LogSampleLimit, warnings, "Invalid headers should be logged through the sampler")
+}
+
+// TestReadinessAndLiveness ensures readiness follows start, pause and shutdown while liveness stays OK
+func TestReadinessAndLiveness(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{})
+	admin := server.AdminHandler()
+
+	probe := func(path string) int {
+		recorder := httptest.NewRecorder()
+		admin.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code
+	}
+	assertProbes := func(ready bool, msg string) {
+		t.Helper()
+		assert.Equal(t, ready, server.Ready(), msg)
+		assert.Equal(t, map[bool]int{true: http.StatusOK, false: http.StatusServiceUnavailable}[ready], probe("/admin/readyz"), msg)
+		assert.Equal(t, http.StatusOK, probe("/admin/livez"), msg)
+	}
+
+	assertProbes(false, "Server should not be ready before it starts")
+
+	go server.Serve(listener)
+	assert.Eventually(t, server.Ready, time.Second, 10*time.Millisecond)
+	assertProbes(true, "Server should be ready once started")
+
+	server.Pause()
+	assertProbes(false, "Paused server should not be ready")
+	server.Resume()
+	assertProbes(true, "Resumed server should be ready")
+
+	server.Shutdown()
+	assertProbes(false, "Server should not be ready after shutdown")
+}
+
+// TestMaintenanceMessage ensures clients connecting once shutdown has started get the maintenance message
+func TestMaintenanceMessage(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+		PreStopDelay:        300 * time.Millisecond,
+		MaintenanceMessage:  "Down for maintenance, back in a minute.",
+	}
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(cfg, log, &MockHandler{})
+	go server.Serve(listener)
+	assert.Eventually(t, server.Ready, time.Second, 10*time.Millisecond)
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		server.Shutdown()
+	}()
+	assert.Eventually(t, func() bool { return !server.Ready() }, time.Second, time.Millisecond)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect during the pre-stop delay: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixError+cfg.MaintenanceMessage+"\n", line)
+
+	<-shutdownDone
+	assert.Equal(t, uint64(1), server.OutcomeCounts()[app.OutcomeMaintenance])
+	assert.Zero(t, server.OutcomeCounts()[app.OutcomeCapacityRejected], "Maintenance rejections are not capacity rejections")
+}
+
+// connMetaHandler reports the connection metadata found in the handler context
+type connMetaHandler struct {
+	metas chan app.ConnMeta
+}
+
+func (h *connMetaHandler) HandleConnection(ctx context.Context, _ app.Conn) error {
+	meta, ok := app.ConnMetaFromCtx(ctx)
+	if !ok {
+		return errors.New("no connection metadata")
+	}
+	h.metas <- meta
+	return nil
+}
+
+// TestConnMeta ensures handlers find the metadata of their connection in the context
+func TestConnMeta(t *testing.T) {
+	_, ok := app.ConnMetaFromCtx(context.Background())
+	assert.False(t, ok, "A context without metadata should report none")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := &connMetaHandler{metas: make(chan app.ConnMeta, 2)}
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	start := time.Now()
+	var metas []app.ConnMeta
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		select {
+		case meta := <-handler.metas:
+			metas = append(metas, meta)
+		case <-time.After(time.Second):
+			t.Fatal("Handler was not called")
+		}
+		conn.Close()
+	}
+
+	for i, meta := range metas {
+		assert.Equal(t, "127.0.0.1", meta.IP)
+		assert.NotEmpty(t, meta.SessionID)
+		assert.Equal(t, uint64(i+1), meta.ConnSeq)
+		assert.WithinDuration(t, start, meta.StartTime, time.Second)
+	}
+	assert.NotEqual(t, metas[0].SessionID, metas[1].SessionID)
+}
+
+// connListener hands out the given connections, then blocks until closed
+type connListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newConnListener(conns ...net.Conn) *connListener {
+	l := &connListener{conns: make(chan net.Conn, len(conns)), closed: make(chan struct{})}
+	for _, conn := range conns {
+		l.conns <- conn
+	}
+	return l
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+// newTricklingConn mocks a client sending n bytes of a solution one at a
+// time, never finishing the line, and then stalling until the read deadline.
+// The returned channel is closed once the server closes the connection.
+func newTricklingConn(t *testing.T, n int) (*mocks.Conn, <-chan struct{}) {
+	closed := make(chan struct{})
+	var closeOnce sync.Once
+
+	var mu sync.Mutex
+	var readDeadline time.Time
+	setReadDeadline := func(deadline time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		readDeadline = deadline
+		return nil
+	}
+
+	mockConn := mocks.NewConn(t)
+	mockConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}).Maybe()
+	mockConn.EXPECT().LocalAddr().Return(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}).Maybe()
+	mockConn.EXPECT().SetDeadline(mock.Anything).RunAndReturn(setReadDeadline).Maybe()
+	mockConn.EXPECT().SetReadDeadline(mock.Anything).RunAndReturn(setReadDeadline).Maybe()
+	mockConn.EXPECT().SetWriteDeadline(mock.Anything).Return(nil).Maybe()
+	mockConn.EXPECT().Write(mock.Anything).RunAndReturn(writeAll).Maybe()
+	mockConn.EXPECT().Close().RunAndReturn(func() error {
+		closeOnce.Do(func() { close(closed) })
+		return nil
+	}).Maybe()
+
+	sent := 0
+	mockConn.EXPECT().Read(mock.Anything).RunAndReturn(func(p []byte) (int, error) {
+		wait := 20 * time.Millisecond
+		if sent == n {
+			mu.Lock()
+			wait = time.Until(readDeadline)
+			mu.Unlock()
+		}
+
+		select {
+		case <-closed:
+			return 0, net.ErrClosed
+		case <-time.After(wait):
+		}
+		if sent == n {
+			return 0, os.ErrDeadlineExceeded
+		}
+		sent++
+		p[0] = '1'
+		return 1, nil
+	})
+
+	return mockConn, closed
+}
+
+// TestSlowloris ensures clients trickling bytes are closed long before the
+// connection timeout, either after too many slow reads or once the read
+// deadline shortened by the slow reads expires
+func TestSlowloris(t *testing.T) {
+	tests := []struct {
+		name     string
+		strikes  int
+		bytes    int
+		maxBytes int64
+	}{
+		{name: "trickle", strikes: 5, bytes: 100},
+		{name: "stall", strikes: 10, bytes: 2},
+		{name: "trickle with data limit", strikes: 5, bytes: 100, maxBytes: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				MaxConnections:        10,
+				ConnectionTimeout:     2 * time.Second,
+				ShutdownTimeout:       time.Second,
+				RateLimitEvery100MS:   10,
+				SlowReadMaxBytes:      1,
+				SlowReadStrikes:       tt.strikes,
+				MaxBytesPerConnection: tt.maxBytes,
+			}
+			log, hook := logtest.NewNullLogger()
+			handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1))
+			server := app.NewServer(cfg, log, handler)
+
+			conn, closed := newTricklingConn(t, tt.bytes)
+			start := time.Now()
+			go server.Serve(newConnListener(conn))
+			defer server.Shutdown()
+
+			select {
+			case <-closed:
+			case <-time.After(cfg.ConnectionTimeout):
+				t.Fatal("Slow client was not closed")
+			}
+			assert.Less(t, time.Since(start), cfg.ConnectionTimeout*3/4, "Slow client should be closed before the connection timeout")
+
+			assert.Eventually(t, func() bool {
+				for _, entry := range hook.AllEntries() {
+					if entry.Message == "Client disconnected" {
+						return entry.Data["close_reason"] == app.CloseReasonSlowloris
+					}
+				}
+				return false
+			}, time.Second, 10*time.Millisecond, "Disconnect should be logged with the slowloris close reason")
+
+			var warned bool
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, app.ErrSlowloris.Error()) {
+					warned = true
+				}
+			}
+			assert.True(t, warned, "Slow client should be logged as a warning")
+		})
+	}
+}
+
+// TestSlowlorisHonestClient ensures short but complete lines of a keep-alive
+// client are not mistaken for slow reads
+func TestSlowlorisHonestClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+		SlowReadMaxBytes:    64,
+		SlowReadStrikes:     2,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1),
+		app.WithMaxRequestsPerConnection(5), app.WithChallengeCommitment(), app.WithRequireAck(time.Second))
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	var received int
+	err = wowclient.Stream(context.Background(), listener.Addr().String(), func(string) { received++ })
+	assert.NoError(t, err)
+	assert.Equal(t, 5, received)
+
+	assert.Eventually(t, func() bool { return server.OutcomeCounts()[app.OutcomeQuoteSent] == 1 }, time.Second, 10*time.Millisecond)
+}
+
+// TestFetchKeepAliveNoPenalty ensures a client fetching a single quote from a
+// keep-alive server ends the session without being penalized for it
+func TestFetchKeepAliveNoPenalty(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   2 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: 10,
+	}
+	log, _ := logtest.NewNullLogger()
+	handler := app.NewHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1),
+		app.WithMaxRequestsPerConnection(2), app.WithInvalidPenalty(1, 3))
+	server := app.NewServer(cfg, log, handler)
+	go server.Serve(listener)
+	defer server.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		quote, err := wowclient.Fetch(context.Background(), listener.Addr().String())
+		assert.NoError(t, err)
+		assert.Equal(t, "quote", quote)
+	}
+
+	assert.Eventually(t, func() bool { return server.OutcomeCounts()[app.OutcomeQuoteSent] == 2 }, time.Second, 10*time.Millisecond)
+	state := server.GetIPState("127.0.0.1")
+	assert.Zero(t, state.Failures)
+	assert.Equal(t, 1, state.Difficulty)
 }
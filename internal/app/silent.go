@@ -0,0 +1,25 @@
+package app
+
+import "github.com/sirupsen/logrus"
+
+// newSilentLogger returns a logger writing like base, but only at warning
+// level and above
+func newSilentLogger(base *logrus.Logger) *logrus.Logger {
+	return &logrus.Logger{
+		Out:          base.Out,
+		Hooks:        base.Hooks,
+		Formatter:    base.Formatter,
+		ReportCaller: base.ReportCaller,
+		Level:        min(base.GetLevel(), logrus.WarnLevel),
+		ExitFunc:     base.ExitFunc,
+	}
+}
+
+// loggerFor returns the logger for connections of the client IP, silencing
+// info and debug output of SilentIPs such as health checkers
+func (s *Server) loggerFor(ip string) *logrus.Logger {
+	if s.silentIPs[ip] {
+		return s.silentLogger
+	}
+	return s.logger
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnState is the stage of the protocol a connection is in
+type ConnState uint8
+
+const (
+	// StateNew is a connection accepted but not served yet
+	StateNew ConnState = iota
+	// StateChallenging is a connection being sent its challenge
+	StateChallenging
+	// StateSolving is a connection waiting for the client's solution
+	StateSolving
+	// StateDelivering is a connection being sent its quote
+	StateDelivering
+	// StateDone is a closed connection
+	StateDone
+)
+
+var connStateNames = [...]string{
+	StateNew:         "new",
+	StateChallenging: "challenging",
+	StateSolving:     "solving",
+	StateDelivering:  "delivering",
+	StateDone:        "done",
+}
+
+func (s ConnState) String() string {
+	if int(s) < len(connStateNames) {
+		return connStateNames[s]
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the state by name, e.g. in the admin API
+func (s ConnState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ConnectionEvent reports a state change of a connection, see WithConnectionEvents
+type ConnectionEvent struct {
+	SessionID string
+	ClientIP  string
+	State     ConnState
+	Time      time.Time
+	// QuotesDelivered is the number of quotes that reached the client so far
+	QuotesDelivered int
+}
+
+// ConnectionInfo describes an active connection, see Server.Connections
+type ConnectionInfo struct {
+	SessionID   string    `json:"session_id"`
+	ClientIP    string    `json:"client_ip"`
+	ConnectedAt time.Time `json:"connected_at"`
+	State       ConnState `json:"state"`
+}
+
+// session tracks the state of an active connection
+type session struct {
+	id     string
+	ip     string
+	start  time.Time
+	state  atomic.Uint32
+	events []chan<- ConnectionEvent
+	// delivered counts the quotes flushed to the client without error
+	delivered atomic.Int32
+}
+
+// setState stores the state and reports it without blocking; events are dropped while a channel is full
+func (s *session) setState(state ConnState) {
+	s.state.Store(uint32(state))
+	if len(s.events) == 0 {
+		return
+	}
+
+	event := ConnectionEvent{
+		SessionID:       s.id,
+		ClientIP:        s.ip,
+		State:           state,
+		Time:            time.Now(),
+		QuotesDelivered: int(s.delivered.Load()),
+	}
+	for _, events := range s.events {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+func (s *session) info() ConnectionInfo {
+	return ConnectionInfo{
+		SessionID:   s.id,
+		ClientIP:    s.ip,
+		ConnectedAt: s.start,
+		State:       ConnState(s.state.Load()),
+	}
+}
+
+// setState moves the connection to the given state
+func (s *connStats) setState(state ConnState) {
+	if s != nil && s.session != nil {
+		s.session.setState(state)
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Metrics sent by WithStatsDClient, after the configured prefix
+const (
+	StatsDActiveConnections = "connections.active" // gauge
+	StatsDTotalConnections  = "connections.total"  // counter of accepted connections
+	StatsDSolveTime         = "pow.solve_time"     // timing from challenge to accepted solution
+	StatsDQuotesServed      = "quotes.served"      // counter of quotes delivered without write error
+)
+
+const (
+	// statsDEventBuffer is the number of connection events waiting to be
+	// reported to StatsD before new ones are dropped
+	statsDEventBuffer = 1024
+
+	// statsDFlushInterval is how often the connection and quote counters are reported
+	statsDFlushInterval = time.Second
+)
+
+// statsDClient sends metrics to a StatsD endpoint, one metric per UDP datagram
+type statsDClient struct {
+	conn   net.Conn
+	prefix string
+}
+
+// newStatsDClient connects to the StatsD endpoint at addr. Metric names are
+// prefixed with prefix and a dot unless prefix is empty.
+func newStatsDClient(addr, prefix string) (*statsDClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd: %w", err)
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &statsDClient{conn: conn, prefix: prefix}, nil
+}
+
+// send writes a single metric, losing it if the endpoint is unreachable as UDP does
+func (c *statsDClient) send(name string, value int64, kind string) {
+	_, _ = c.conn.Write([]byte(c.prefix + name + ":" + strconv.FormatInt(value, 10) + "|" + kind))
+}
+
+func (c *statsDClient) count(name string, n int64) {
+	c.send(name, n, "c")
+}
+
+func (c *statsDClient) gauge(name string, value int64) {
+	c.send(name, value, "g")
+}
+
+func (c *statsDClient) timing(name string, d time.Duration) {
+	c.send(name, d.Milliseconds(), "ms")
+}
+
+// WithStatsDClient reports connection metrics to the StatsD endpoint at addr,
+// see the StatsD constants for the metric names, until the server shuts down.
+// The connection and quote counters of the server are reported every
+// statsDFlushInterval, so they stay exact under load. Solve times are derived
+// from the connection events, see WithConnectionEvents, which may be dropped.
+// If addr cannot be resolved, a warning is logged and no metrics are sent.
+func WithStatsDClient(addr, prefix string) ServerOption {
+	return func(s *Server) {
+		client, err := newStatsDClient(addr, prefix)
+		if err != nil {
+			s.logger.Warnf("StatsD metrics disabled: %v", err)
+			return
+		}
+
+		events := make(chan ConnectionEvent, statsDEventBuffer)
+		s.events = append(s.events, events)
+		go s.reportStatsD(client, events)
+	}
+}
+
+// reportStatsD reports the server counters every statsDFlushInterval and turns
+// connection events into solve times until the server context is done, then
+// reports the events still buffered and the counters a last time
+func (s *Server) reportStatsD(client *statsDClient, events <-chan ConnectionEvent) {
+	defer client.conn.Close()
+
+	ticker := time.NewTicker(statsDFlushInterval)
+	defer ticker.Stop()
+
+	// Counters report the increase since the previous flush
+	var connections, quotes uint64
+	flush := func() {
+		if total := s.ConnectionCount(); total > connections {
+			client.count(StatsDTotalConnections, int64(total-connections))
+			connections = total
+		}
+		// Quotes are counted once the connection is done, so a failed
+		// delivery is never reported as served
+		if served := s.QuotesServed(); served > quotes {
+			client.count(StatsDQuotesServed, int64(served-quotes))
+			quotes = served
+		}
+		client.gauge(StatsDActiveConnections, int64(s.ActiveConnections()))
+	}
+
+	// Challenges waiting for a solution by session ID
+	solving := make(map[string]time.Time)
+	report := func(event ConnectionEvent) {
+		switch event.State {
+		case StateSolving:
+			solving[event.SessionID] = event.Time
+		case StateDelivering:
+			if issued, ok := solving[event.SessionID]; ok {
+				client.timing(StatsDSolveTime, event.Time.Sub(issued))
+				delete(solving, event.SessionID)
+			}
+		case StateDone:
+			delete(solving, event.SessionID)
+		}
+	}
+
+	for {
+		select {
+		case event := <-events:
+			report(event)
+		case <-ticker.C:
+			flush()
+		case <-s.ctx.Done():
+			for {
+				select {
+				case event := <-events:
+					report(event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
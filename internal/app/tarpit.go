@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"word-of-wisdom/internal/ratelimit"
+)
+
+// failureTrackedIPs bounds the failure counts kept for escalating penalties
+const failureTrackedIPs = 10_000
+
+// failureTracker counts the consecutive invalid PoW solutions per IP, which
+// escalate the tarpit delay and the difficulty penalty
+type failureTracker struct {
+	counts *ratelimit.LRU[*atomic.Int64]
+}
+
+func newFailureTracker() *failureTracker {
+	return &failureTracker{counts: ratelimit.NewLRU[*atomic.Int64](failureTrackedIPs)}
+}
+
+// add records a failure of ip and returns its consecutive failures
+func (f *failureTracker) add(ip string) int64 {
+	failures, _ := f.counts.GetOrAdd(ip, func() *atomic.Int64 { return new(atomic.Int64) })
+	return failures.Add(1)
+}
+
+// count returns the consecutive failures of ip
+func (f *failureTracker) count(ip string) int64 {
+	if failures, ok := f.counts.Peek(ip); ok {
+		return failures.Load()
+	}
+	return 0
+}
+
+// reset forgets the failures of ip, e.g. after a valid solution
+func (f *failureTracker) reset(ip string) {
+	f.counts.Remove(ip)
+}
+
+// tarpit delays invalid PoW responses to slow down clients brute-forcing the
+// server. The delay doubles with every consecutive failure of an IP, up to max.
+type tarpit struct {
+	base time.Duration
+	max  time.Duration
+}
+
+func newTarpit(base, maxDelay time.Duration) *tarpit {
+	return &tarpit{
+		base: base,
+		max:  max(base, maxDelay),
+	}
+}
+
+// delay returns how long to hold the response to the given number of consecutive failures
+func (t *tarpit) delay(failures int64) time.Duration {
+	d := t.base
+	for i := int64(1); i < failures && d < t.max; i++ {
+		d *= 2
+	}
+	return min(d, t.max)
+}
+
+// difficultyPenalty raises the difficulty of the challenges issued to an IP
+// by step per consecutive failure, up to max above the baseline
+type difficultyPenalty struct {
+	step int
+	max  int
+}
+
+// extra returns the difficulty added for the given number of consecutive failures
+func (p *difficultyPenalty) extra(failures int64) int {
+	return int(min(failures*int64(p.step), int64(p.max)))
+}
+
+// wait holds the response for d, unless the connection deadline passes or the
+// server starts shutting down, which returns early with ctx.Err() and nil respectively
+func (t *tarpit) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-shutdownFromContext(ctx):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type shutdownKey struct{}
+
+// withShutdown returns a copy of ctx carrying a channel closed once the server
+// starts shutting down, before in-flight connections are waited for
+func withShutdown(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, shutdown)
+}
+
+// shutdownFromContext returns the shutdown channel stored in ctx, or nil,
+// which never fires, if there is none
+func shutdownFromContext(ctx context.Context) <-chan struct{} {
+	shutdown, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return shutdown
+}
@@ -4,11 +4,15 @@ package app
 //go:generate mockery --name=quoteProvider --filename quote_provider.go --exported --with-expecter=True
 //go:generate mockery --name=Conn --filename conn.go --exported --with-expecter=True
 
-import "net"
+import (
+	"context"
+	"net"
+	"word-of-wisdom/pkg/protocol"
+)
 
 type (
 	Handler interface {
-		HandleConnection(conn Conn) error
+		HandleConnection(ctx context.Context, conn Conn) error
 	}
 
 	Conn interface {
@@ -21,6 +25,35 @@ type (
 	}
 
 	quoteProvider interface {
-		GetQuote() string
+		GetQuote() protocol.QuoteMessage
+	}
+
+	// contextQuoteProvider is a quote source bound by the request deadline, e.g. a database
+	contextQuoteProvider interface {
+		Quote(ctx context.Context) (protocol.QuoteMessage, error)
+	}
+
+	// leveledPowChallenge issues challenges at a difficulty chosen per call
+	leveledPowChallenge interface {
+		GenerateChallengeAt(difficulty int) string
+	}
+
+	// compatPowChallenge issues challenges without difficulty for legacy clients
+	compatPowChallenge interface {
+		GenerateCompatChallengeAt(difficulty int) string
+		CompatDifficulty() int
+	}
+
+	// contextPowChallenge validates solutions within the request deadline
+	contextPowChallenge interface {
+		ValidateChallengeContext(ctx context.Context, challenge, response string) (bool, error)
+	}
+
+	quoteCollections interface {
+		GetCollectionQuote(name string) protocol.QuoteMessage
+	}
+
+	clientQuoteProvider interface {
+		GetQuoteFor(clientIP string) protocol.QuoteMessage
 	}
 )
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"time"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+)
+
+const (
+	// WebSocketPath is the endpoint of the WebSocket front-end
+	WebSocketPath = "/ws"
+
+	// webSocketPongWait is how long the client may stay silent, including
+	// pongs to the pings sent every webSocketPingPeriod
+	webSocketPongWait   = 10 * time.Second
+	webSocketPingPeriod = webSocketPongWait * 9 / 10
+
+	// webSocketWriteWait bounds writing a control frame
+	webSocketWriteWait = time.Second
+)
+
+// InvalidMessageMsg rejects WebSocket messages other than a JSON solution
+const InvalidMessageMsg = "Invalid message"
+
+// WebSocketHandler serves browser clients the JSON protocol over WebSocket,
+// see protocol.JSONMessage. After the upgrade the server sends a challenge
+// and the client answers with its solution, getting a quote or an error
+// before the server closes the connection. The whole exchange is bound by
+// the timeout, and the server pings the client so dead peers are noticed early.
+//
+// Browsers send an Origin header, so only pages served from the same host
+// may connect.
+type WebSocketHandler struct {
+	quoteProvider quoteProvider
+	powChallenge  powChallenge
+	timeout       time.Duration
+	upgrader      websocket.Upgrader
+	now           func() time.Time
+}
+
+// NewWebSocketHandler creates a WebSocket front-end finishing every exchange within timeout
+func NewWebSocketHandler(quoteProvider quoteProvider, powChallenge powChallenge, timeout time.Duration) *WebSocketHandler {
+	return &WebSocketHandler{
+		quoteProvider: quoteProvider,
+		powChallenge:  powChallenge,
+		timeout:       timeout,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: timeout,
+		},
+		now: time.Now,
+	}
+}
+
+// ServeHTTP upgrades the connection and performs a single challenge-quote exchange
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Upgrade answers failed handshakes with an HTTP error itself
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error(err).Debug("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	deadline := h.now().Add(h.timeout)
+	code, text := h.serve(conn, deadline)
+	if code != 0 {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), h.now().Add(webSocketWriteWait))
+	}
+}
+
+// serve runs the exchange and returns the close code and text to end it
+// with, or zero when the client is gone or already closed the connection
+func (h *WebSocketHandler) serve(conn *websocket.Conn, deadline time.Time) (int, string) {
+	conn.SetReadLimit(maxResponseSize)
+	_ = conn.SetWriteDeadline(deadline)
+
+	// Every pong proves the client alive for another webSocketPongWait
+	extendRead := func() error {
+		return conn.SetReadDeadline(earliest(h.now().Add(webSocketPongWait), deadline))
+	}
+	_ = extendRead()
+	conn.SetPongHandler(func(string) error { return extendRead() })
+
+	done := make(chan struct{})
+	defer close(done)
+	go h.ping(conn, done)
+
+	challenge := h.powChallenge.GenerateChallenge()
+	message := protocol.JSONMessage{Type: protocol.JSONChallenge, Challenge: challenge}
+	if difficulty, err := protocol.ChallengeDifficulty(challenge); err == nil {
+		message.Difficulty = difficulty
+	}
+	if err := conn.WriteJSON(message); err != nil {
+		return 0, ""
+	}
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		if errors.Is(err, websocket.ErrReadLimit) {
+			return websocket.CloseMessageTooBig, ErrResponseTooLong.Error()
+		}
+		// The client closed the connection or stopped answering
+		return 0, ""
+	}
+
+	var reply protocol.JSONMessage
+	if messageType != websocket.TextMessage || json.Unmarshal(data, &reply) != nil || reply.Type != protocol.JSONSolution {
+		return h.reject(conn, websocket.CloseUnsupportedData, InvalidMessageMsg)
+	}
+
+	if !h.powChallenge.ValidateChallenge(challenge, reply.Solution) {
+		return h.reject(conn, websocket.ClosePolicyViolation, InvalidMsg)
+	}
+
+	quote := h.quoteProvider.GetQuote()
+	if err := conn.WriteJSON(protocol.JSONMessage{Type: protocol.JSONQuote, Quote: quote.Text, Author: quote.Author}); err != nil {
+		return 0, ""
+	}
+	return websocket.CloseNormalClosure, ""
+}
+
+// reject sends an error message and returns the close code and text to end the exchange with
+func (h *WebSocketHandler) reject(conn *websocket.Conn, code int, reason string) (int, string) {
+	if err := conn.WriteJSON(protocol.JSONMessage{Type: protocol.JSONError, Error: reason}); err != nil {
+		return 0, ""
+	}
+	return code, reason
+}
+
+// ping pings the client every webSocketPingPeriod until done is closed
+func (h *WebSocketHandler) ping(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, h.now().Add(webSocketWriteWait)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// earliest returns the earlier of two times
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
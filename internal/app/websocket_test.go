@@ -0,0 +1,154 @@
+package app_test
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/app"
+	"word-of-wisdom/internal/config"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
+)
+
+// dialWebSocket starts a WebSocket front-end and connects a test client to it
+func dialWebSocket(t *testing.T, difficulty int) *websocket.Conn {
+	t.Helper()
+
+	quote := protocol.QuoteMessage{Text: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"}
+	handler := app.NewWebSocketHandler(
+		quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{quote}),
+		pow.NewSHA256PoW(difficulty),
+		5*time.Second,
+	)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+app.WebSocketPath, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+// readChallenge reads the challenge message and checks the advertised difficulty
+func readChallenge(t *testing.T, conn *websocket.Conn, difficulty int) string {
+	t.Helper()
+
+	var message protocol.JSONMessage
+	require.NoError(t, conn.ReadJSON(&message))
+	require.Equal(t, protocol.JSONChallenge, message.Type)
+	assert.Equal(t, difficulty, message.Difficulty)
+	return message.Challenge
+}
+
+// TestWebSocketHandler covers the handshake, a served quote and the closing handshake
+func TestWebSocketHandler(t *testing.T) {
+	difficulty := 2
+	conn := dialWebSocket(t, difficulty)
+
+	challenge := readChallenge(t, conn, difficulty)
+	solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+	require.NoError(t, err)
+
+	// Pings are answered while the server waits for the solution
+	pong := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		pong <- struct{}{}
+		return nil
+	})
+	require.NoError(t, conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(time.Second)))
+
+	require.NoError(t, conn.WriteJSON(protocol.JSONMessage{Type: protocol.JSONSolution, Solution: solution}))
+
+	var reply protocol.JSONMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, protocol.JSONMessage{Type: protocol.JSONQuote, Quote: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"}, reply)
+
+	select {
+	case <-pong:
+	default:
+		t.Fatal("Expected a pong before the quote")
+	}
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "Expected a normal closure, got %v", err)
+}
+
+// TestWebSocketHandlerRejections ensures invalid solutions and messages end with an error and a close code
+func TestWebSocketHandlerRejections(t *testing.T) {
+	cases := []struct {
+		name    string
+		send    func(conn *websocket.Conn) error
+		message string
+		code    int
+	}{
+		{
+			name: "invalid solution",
+			send: func(conn *websocket.Conn) error {
+				return conn.WriteJSON(protocol.JSONMessage{Type: protocol.JSONSolution, Solution: "invalid"})
+			},
+			message: app.InvalidMsg,
+			code:    websocket.ClosePolicyViolation,
+		},
+		{
+			name: "not json",
+			send: func(conn *websocket.Conn) error {
+				return conn.WriteMessage(websocket.TextMessage, []byte("solution"))
+			},
+			message: app.InvalidMessageMsg,
+			code:    websocket.CloseUnsupportedData,
+		},
+		{
+			name: "wrong type",
+			send: func(conn *websocket.Conn) error {
+				return conn.WriteJSON(protocol.JSONMessage{Type: protocol.JSONQuote})
+			},
+			message: app.InvalidMessageMsg,
+			code:    websocket.CloseUnsupportedData,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := dialWebSocket(t, 2)
+			readChallenge(t, conn, 2)
+
+			require.NoError(t, tc.send(conn))
+
+			var reply protocol.JSONMessage
+			require.NoError(t, conn.ReadJSON(&reply))
+			assert.Equal(t, protocol.JSONMessage{Type: protocol.JSONError, Error: tc.message}, reply)
+
+			_, _, err := conn.ReadMessage()
+			assert.True(t, websocket.IsCloseError(err, tc.code), "Expected close code %d, got %v", tc.code, err)
+		})
+	}
+}
+
+// TestWebSocketHandlerRateLimit ensures handshakes through RateLimitHTTP take a token of the client IP
+func TestWebSocketHandlerRateLimit(t *testing.T) {
+	log, _ := logtest.NewNullLogger()
+	server := app.NewServer(config.Config{MaxConnections: 1, RateLimitEvery100MS: 1}, log, &MockHandler{})
+	handler := server.RateLimitHTTP(app.NewWebSocketHandler(quotes.NewRandomQuoteProvider([]string{"quote"}), pow.NewSHA256PoW(1), 5*time.Second))
+	front := httptest.NewServer(handler)
+	t.Cleanup(front.Close)
+	url := "ws" + strings.TrimPrefix(front.URL, "http") + app.WebSocketPath
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	_ = conn.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err, "The burst should be used up")
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
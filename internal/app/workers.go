@@ -0,0 +1,67 @@
+package app
+
+import (
+	"net"
+	"time"
+)
+
+// job is an accepted connection with its sequence number and the time it was admitted
+type job struct {
+	conn  net.Conn
+	seq   uint64
+	start time.Time
+}
+
+// dispatch hands an admitted connection to the worker pool or to a dedicated
+// goroutine. The connection timeout runs from here, so time spent waiting for
+// a free worker counts against it. It reports false without blocking when the
+// queue of the worker pool is full, which happens only when the semaphore
+// admits more than MaxConnections connections, see WithSemaphore.
+func (s *Server) dispatch(conn net.Conn, seq uint64) bool {
+	start := time.Now()
+	if s.jobs == nil {
+		go s.handleClient(conn, seq, start)
+		return true
+	}
+
+	select {
+	case s.jobs <- job{conn: conn, seq: seq, start: start}:
+		return true
+	default:
+		return false
+	}
+}
+
+// startWorkers launches the worker pool consuming accepted connections
+func (s *Server) startWorkers() {
+	for id := 0; id < s.config.WorkerPoolSize; id++ {
+		s.workersWg.Add(1)
+		go s.worker(id)
+	}
+}
+
+// worker handles queued connections until the job channel is closed
+func (s *Server) worker(id int) {
+	defer s.workersWg.Done()
+
+	for j := range s.jobs {
+		s.busyWorkers.Store(id, j.conn.RemoteAddr().String())
+		s.handleClient(j.conn, j.seq, j.start)
+		s.busyWorkers.Delete(id)
+	}
+}
+
+// stopWorkers closes the job channel so workers exit once the queue is drained
+func (s *Server) stopWorkers() {
+	if s.jobs != nil {
+		close(s.jobs)
+	}
+}
+
+// logStuckWorkers reports workers still handling a client after the shutdown timeout
+func (s *Server) logStuckWorkers() {
+	s.busyWorkers.Range(func(id, client any) bool {
+		s.logger.Warnf("Worker %d did not finish in time, still handling client %s", id, client)
+		return true
+	})
+}
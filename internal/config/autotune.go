@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// connectionsPerCPU is the MaxConnections budget of a CPU. Connections
+	// mostly wait on the network, so a CPU serves many of them.
+	connectionsPerCPU = 256
+	// connectionMemory is the memory budgeted per connection: goroutine
+	// stacks, read and write buffers and a message of protocol.MaxMessageSize
+	connectionMemory = 128 * 1024
+	// minAutoConnections is the lower bound of the tuned MaxConnections
+	minAutoConnections = 16
+)
+
+// Resources describes the machine the server is tuned for
+type Resources struct {
+	CPUs int
+	// MemoryBytes is the memory available to the server, zero when unknown
+	MemoryBytes uint64
+}
+
+// DetectResources returns the CPUs usable by the process and, on Linux, the
+// available memory
+func DetectResources() Resources {
+	return Resources{
+		CPUs:        runtime.NumCPU(),
+		MemoryBytes: availableMemory(),
+	}
+}
+
+// AutoTune fills an unset MaxConnections from the detected resources
+func (c Config) AutoTune() Config {
+	return c.AutoTuneFor(DetectResources())
+}
+
+// AutoTuneFor fills an unset MaxConnections for the given resources. An
+// explicitly configured value is kept.
+//
+//	MaxConnections = min(CPUs*256, MemoryBytes/4/128KiB), within [16, cap]
+//
+// A quarter of the memory is budgeted for connections. When the memory is
+// unknown only the CPU count applies. WorkerPoolSize is never tuned: workers
+// block on client I/O for up to ConnectionTimeout, so a small pool would let a
+// few slow clients starve the server. It stays goroutine-per-connection
+// unless a pool is configured explicitly.
+func (c Config) AutoTuneFor(r Resources) Config {
+	cpus := max(r.CPUs, 1)
+
+	if c.MaxConnections <= 0 {
+		maxCap := c.MaxConnectionsCap
+		if maxCap <= 0 {
+			maxCap = DefaultMaxConnectionsCap
+		}
+
+		connections := cpus * connectionsPerCPU
+		if r.MemoryBytes > 0 {
+			connections = min(connections, int(min(r.MemoryBytes/4/connectionMemory, uint64(maxCap))))
+		}
+		c.MaxConnections = min(max(connections, minAutoConnections), maxCap)
+	}
+
+	return c
+}
+
+// availableMemory reads MemAvailable from /proc/meminfo, returning zero when unknown
+func availableMemory() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "MemAvailable:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
@@ -1,11 +1,263 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Bounds of the numeric fields checked by Validate
+const (
+	// MinMaxConnections is the smallest MaxConnections. The server needs at
+	// least one slot, zero or negative values would reject every client.
+	MinMaxConnections = 1
+	// MaxMaxConnections is the largest MaxConnections unless MaxConnectionsCap
+	// says otherwise. The server semaphore allocates a slot per connection, so
+	// absurd values could exhaust memory on startup.
+	MaxMaxConnections = 100_000
+
+	// MinRateLimitEvery100MS is the smallest per-IP burst. A burst of zero
+	// never grants a token, so every client would be rate limited.
+	MinRateLimitEvery100MS = 1
+	// MaxRateLimitEvery100MS is the largest per-IP burst. It already allows
+	// 100 000 connections per second from a single IP, larger values usually
+	// mean a unit mistake rather than an intended limit.
+	MaxRateLimitEvery100MS = 10_000
+)
+
+// DefaultMaxConnectionsCap is the upper bound for MaxConnections used when
+// MaxConnectionsCap is not set.
+const DefaultMaxConnectionsCap = MaxMaxConnections
 
 type Config struct {
-	Port                string
-	MaxConnections      int
-	ConnectionTimeout   time.Duration
-	ShutdownTimeout     time.Duration
-	RateLimitEvery100MS int
+	Port                string        `json:"port"`
+	MaxConnections      int           `json:"max_connections"`
+	ConnectionTimeout   time.Duration `json:"connection_timeout"`
+	ShutdownTimeout     time.Duration `json:"shutdown_timeout"`
+	RateLimitEvery100MS int           `json:"rate_limit_every_100ms"`
+	// HTTPPort enables the HTTP front-end on the given address, e.g. ":8080",
+	// for clients that cannot speak the TCP protocol. Empty disables it.
+	HTTPPort string `json:"http_port"`
+	// WebSocketPort enables the WebSocket front-end for browser clients on the
+	// given address, serving the JSON protocol at /ws. Empty disables it.
+	WebSocketPort string `json:"websocket_port"`
+	// AdminPort serves the admin API, e.g. GET /admin/connections, on the
+	// given address. Bind it to a private interface. Empty disables it.
+	AdminPort string `json:"admin_port"`
+	// StatsDAddr reports connection metrics to the StatsD endpoint at the
+	// given address, e.g. "127.0.0.1:8125", prefixed with StatsDPrefix. Empty
+	// disables it.
+	StatsDAddr   string `json:"statsd_addr"`
+	StatsDPrefix string `json:"statsd_prefix"`
+	// ProxyProtocol expects every connection to start with a PROXY protocol v1
+	// header, e.g. from a load balancer, whose source address replaces the
+	// remote address for rate limiting and logs. Connections without a valid
+	// header are closed, so only enable it behind such a load balancer.
+	ProxyProtocol bool `json:"proxy_protocol"`
+	// SubnetMask is the IPv4 CIDR prefix length used to aggregate clients
+	// into subnets for rate limiting (e.g. 24). Zero disables subnet limiting.
+	SubnetMask int `json:"subnet_mask"`
+	// SubnetRateLimit is the burst allowed per subnet every 100ms, checked in
+	// addition to the per-IP limit.
+	SubnetRateLimit int `json:"subnet_rate_limit"`
+	// LimiterIdleTTL drops the rate limiters of IPs and subnets idle for
+	// longer, bounding the memory held for past clients. Zero keeps them forever.
+	LimiterIdleTTL time.Duration `json:"limiter_idle_ttl"`
+	// MaxTrackedIPs caps the number of per-IP rate limiters kept in memory,
+	// evicting the least recently used ones. An evicted IP starts over with a
+	// full burst. Zero keeps every limiter until LimiterIdleTTL drops it.
+	MaxTrackedIPs int `json:"max_tracked_ips"`
+	// MaxConnectionsCap overrides DefaultMaxConnectionsCap.
+	MaxConnectionsCap int `json:"max_connections_cap"`
+	// MaxRequestsPerConnection is the number of quotes a client may request
+	// over a single connection. Values above 1 enable streaming mode.
+	MaxRequestsPerConnection int `json:"max_requests_per_connection"`
+	// MaxBytesPerConnection closes connections once the client has sent more
+	// bytes in total, e.g. junk solutions. Zero disables the limit.
+	MaxBytesPerConnection int64 `json:"max_bytes_per_connection"`
+	// SlowReadMaxBytes and SlowReadStrikes close slowloris clients sending a
+	// few bytes at a time to hold their connection: every read of at most
+	// SlowReadMaxBytes bytes not finishing a line halves the time left until
+	// the read deadline, and the SlowReadStrikes-th one within the same line
+	// closes the connection. Zero disables it.
+	SlowReadMaxBytes int `json:"slow_read_max_bytes"`
+	SlowReadStrikes  int `json:"slow_read_strikes"`
+	// PreStopDelay keeps the listener open after shutdown starts so load
+	// balancers can stop routing traffic before connections are refused.
+	PreStopDelay time.Duration `json:"pre_stop_delay"`
+	// MaintenanceMessage is sent as an ERROR line to clients connecting once
+	// shutdown has started, e.g. during PreStopDelay, instead of serving them.
+	// Empty keeps serving them until the listener closes.
+	MaintenanceMessage string `json:"maintenance_message"`
+	// WorkerPoolSize is the number of workers handling connections. Zero
+	// handles every connection in its own goroutine.
+	WorkerPoolSize int `json:"worker_pool_size"`
+	// MaxQueueDepth is the number of clients waiting for a free connection slot
+	// when MaxConnections is reached. Zero rejects them right away.
+	MaxQueueDepth int `json:"max_queue_depth"`
+	// QueueWaitTimeout bounds the wait in the queue. Zero waits until a slot
+	// frees up or the server shuts down.
+	QueueWaitTimeout time.Duration `json:"queue_wait_timeout"`
+	// LogSampleLimit is the number of rejection and rate-limit log lines
+	// emitted per LogSampleWindow, the rest are summarized. Zero logs every event.
+	LogSampleLimit  int           `json:"log_sample_limit"`
+	LogSampleWindow time.Duration `json:"log_sample_window"`
+	// InvalidPoWDelay holds the response to an invalid PoW solution to slow
+	// down brute-forcing clients, doubling with consecutive failures of an IP
+	// up to InvalidPoWMaxDelay. Zero answers right away.
+	InvalidPoWDelay    time.Duration `json:"invalid_pow_delay"`
+	InvalidPoWMaxDelay time.Duration `json:"invalid_pow_max_delay"`
+	// InvalidPoWPenaltyStep raises the difficulty of the challenges issued to
+	// an IP by the given step per consecutive invalid solution, up to
+	// InvalidPoWMaxPenalty above the baseline. Zero disables the penalty.
+	InvalidPoWPenaltyStep int `json:"invalid_pow_penalty_step"`
+	InvalidPoWMaxPenalty  int `json:"invalid_pow_max_penalty"`
+	// MaxGoroutines logs a warning whenever the process runs more goroutines,
+	// checked every GoroutineCheckInterval (10s when zero), to detect leaks
+	// when embedded into constrained environments. Zero disables the check.
+	MaxGoroutines          int           `json:"max_goroutines"`
+	GoroutineCheckInterval time.Duration `json:"goroutine_check_interval"`
+	// RequireAck waits up to AckTimeout (1s when zero) for the client to
+	// acknowledge the quotes with an ACK line before closing the connection.
+	// A missing acknowledgement is only logged.
+	RequireAck bool          `json:"require_ack"`
+	AckTimeout time.Duration `json:"ack_timeout"`
+	// ChallengeCommitment first sends clients a commitment to the challenge
+	// and reveals the challenge, and so its difficulty, only once they
+	// committed to solve it, so clients cannot pick only easy challenges.
+	ChallengeCommitment bool `json:"challenge_commitment"`
+	// QuoteCooldown is the minimum interval between quote grants to a client
+	// IP, turning it away with a "come back later" error in between, e.g. to
+	// stop the harvesting of the whole quote database. Zero disables it.
+	QuoteCooldown time.Duration `json:"quote_cooldown"`
+	// RejectStubQuote answers with an error instead of the stub quote when the
+	// quote provider is empty, so clients retry rather than get a placeholder.
+	RejectStubQuote bool `json:"reject_stub_quote"`
+	// StructuredRejections sends rate-limit and capacity rejections as JSON
+	// hints with the difficulty, a retry delay and the reason, see protocol.Rejection.
+	StructuredRejections bool `json:"structured_rejections"`
+	// SelfTestAttempts solves a challenge at startup within the given number
+	// of attempts and refuses to start if it fails, catching an impossible
+	// difficulty or broken hashing. Zero skips the self-test.
+	SelfTestAttempts int `json:"self_test_attempts"`
+	// CompatDifficulty serves clients predating the advertised difficulty:
+	// clients not starting with a hello get a challenge without difficulty,
+	// validated at CompatDifficulty, even while the PoW difficulty is higher;
+	// a penalty or progression is added on top of it. Every other client must
+	// send a hello. Zero disables it.
+	CompatDifficulty int `json:"compat_difficulty"`
+	// LegacyHelloWait is how long a client may stay silent before it is
+	// served as a legacy client, 250ms when zero. Every connection without a
+	// hello waits that long, occupying a worker of WorkerPoolSize meanwhile.
+	LegacyHelloWait time.Duration `json:"legacy_hello_wait"`
+	// LegacyPoWHashing validates solutions hashed together with the challenge
+	// without separator, for clients predating protocol.SolutionSeparator.
+	LegacyPoWHashing bool `json:"legacy_pow_hashing"`
+	// LineEnding terminates the lines sent to clients: LineEndingLF (the
+	// default when empty) or LineEndingCRLF for telnet-style clients. Client
+	// lines are accepted with either ending.
+	LineEnding string `json:"line_ending"`
+	// ProbeLogging sets how connections failing without sending a single
+	// byte, e.g. port scans and TCP health checks, are logged: ProbeLogError
+	// (the default when empty) like any handler error, ProbeLogDebug at debug
+	// level, or ProbeLogSilent not at all.
+	ProbeLogging string `json:"probe_logging"`
+	// SilentIPs are client IPs, e.g. of liveness probes, whose connections
+	// are only logged at warning level and above.
+	SilentIPs []string `json:"silent_ips"`
+	// ConnectionRejectionMessages overrides the texts of ERROR lines sent to
+	// turned away clients. Empty fields keep the defaults.
+	ConnectionRejectionMessages ConnectionRejectionMessages `json:"connection_rejection_messages"`
+	// CaptureFile records the raw bytes exchanged with clients, with hashed
+	// IPs, to the given file for debugging. Empty disables capturing.
+	CaptureFile string `json:"capture_file"`
+	// CaptureMaxBytes bounds the capture file, 10 MiB when zero.
+	CaptureMaxBytes int64 `json:"capture_max_bytes"`
+	// InstanceID names this server in the VERSION line that starts every
+	// connection, e.g. for debugging behind a load balancer. Usually
+	// DefaultInstanceID. Empty omits it.
+	InstanceID string `json:"instance_id"`
+	// ChallengeSalt is a deployment specific token hashed into every challenge.
+	// It is sensitive and never marshaled.
+	ChallengeSalt string `json:"-"`
+}
+
+// Probe logging modes, see Config.ProbeLogging
+const (
+	ProbeLogError  = "error"
+	ProbeLogDebug  = "debug"
+	ProbeLogSilent = "silent"
+)
+
+// Line endings, see Config.LineEnding
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+// ConnectionRejectionMessages are the texts sent after the ERROR prefix when
+// a client is rate limited, the server is at capacity or paused, or an internal error occurs
+type ConnectionRejectionMessages struct {
+	RateLimit string `json:"rate_limit"`
+	Capacity  string `json:"capacity"`
+	Paused    string `json:"paused"`
+	Internal  string `json:"internal"`
+}
+
+// DefaultInstanceID returns the host name, or an empty string when it is unknown
+func DefaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// LineTerminator returns the characters ending the lines sent to clients, see LineEnding
+func (c Config) LineTerminator() string {
+	if c.LineEnding == LineEndingCRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// SafeCopy returns a copy of the config with sensitive fields zeroed,
+// suitable for exposing the effective configuration.
+func (c Config) SafeCopy() Config {
+	c.ChallengeSalt = ""
+	return c
+}
+
+// Validate checks that the configuration can be safely used to start the server.
+func (c Config) Validate() error {
+	maxCap := c.MaxConnectionsCap
+	if maxCap <= 0 {
+		maxCap = DefaultMaxConnectionsCap
+	}
+
+	if c.MaxConnections < MinMaxConnections {
+		return fmt.Errorf("max connections must be at least %d, got %d", MinMaxConnections, c.MaxConnections)
+	}
+	if c.MaxConnections > maxCap {
+		return fmt.Errorf("max connections %d exceeds the cap of %d", c.MaxConnections, maxCap)
+	}
+
+	if c.RateLimitEvery100MS < MinRateLimitEvery100MS || c.RateLimitEvery100MS > MaxRateLimitEvery100MS {
+		return fmt.Errorf("rate limit every 100ms must be between %d and %d, got %d",
+			MinRateLimitEvery100MS, MaxRateLimitEvery100MS, c.RateLimitEvery100MS)
+	}
+
+	switch c.ProbeLogging {
+	case "", ProbeLogError, ProbeLogDebug, ProbeLogSilent:
+	default:
+		return fmt.Errorf("unknown probe logging mode %q", c.ProbeLogging)
+	}
+
+	switch c.LineEnding {
+	case "", LineEndingLF, LineEndingCRLF:
+	default:
+		return fmt.Errorf("unknown line ending %q", c.LineEnding)
+	}
+
+	return nil
 }
@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/config"
+)
+
+// TestValidateMaxConnections ensures absurd MaxConnections values are rejected.
+func TestValidateMaxConnections(t *testing.T) {
+	cfg := config.Config{MaxConnections: 100, RateLimitEvery100MS: 5}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MaxConnections = config.DefaultMaxConnectionsCap + 1
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds the cap")
+
+	cfg.MaxConnections = 0
+	assert.Error(t, cfg.Validate())
+}
+
+// TestValidateBounds ensures numeric fields are accepted at their bounds and rejected just outside.
+func TestValidateBounds(t *testing.T) {
+	valid := config.Config{MaxConnections: 100, RateLimitEvery100MS: 5}
+
+	tests := []struct {
+		name    string
+		modify  func(*config.Config)
+		wantErr string
+	}{
+		{name: "min connections", modify: func(c *config.Config) { c.MaxConnections = config.MinMaxConnections }},
+		{name: "max connections", modify: func(c *config.Config) { c.MaxConnections = config.MaxMaxConnections }},
+		{name: "below min connections", modify: func(c *config.Config) { c.MaxConnections = config.MinMaxConnections - 1 }, wantErr: "max connections must be at least"},
+		{name: "negative connections", modify: func(c *config.Config) { c.MaxConnections = -1 }, wantErr: "max connections must be at least"},
+		{name: "above max connections", modify: func(c *config.Config) { c.MaxConnections = config.MaxMaxConnections + 1 }, wantErr: "exceeds the cap"},
+		{name: "min rate limit", modify: func(c *config.Config) { c.RateLimitEvery100MS = config.MinRateLimitEvery100MS }},
+		{name: "max rate limit", modify: func(c *config.Config) { c.RateLimitEvery100MS = config.MaxRateLimitEvery100MS }},
+		{name: "below min rate limit", modify: func(c *config.Config) { c.RateLimitEvery100MS = config.MinRateLimitEvery100MS - 1 }, wantErr: "rate limit every 100ms"},
+		{name: "above max rate limit", modify: func(c *config.Config) { c.RateLimitEvery100MS = config.MaxRateLimitEvery100MS + 1 }, wantErr: "rate limit every 100ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, cfg.Validate())
+			} else {
+				assert.ErrorContains(t, cfg.Validate(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidateCustomCap ensures the upper bound can be configured.
+func TestValidateCustomCap(t *testing.T) {
+	cfg := config.Config{MaxConnections: 500, MaxConnectionsCap: 1000, RateLimitEvery100MS: 5}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MaxConnections = 1001
+	assert.Error(t, cfg.Validate())
+}
+
+// TestMarshalHidesSensitiveFields ensures secrets never appear in the JSON representation.
+func TestMarshalHidesSensitiveFields(t *testing.T) {
+	cfg := config.Config{
+		Port:                     ":9000",
+		MaxConnections:           100,
+		ConnectionTimeout:        2 * time.Second,
+		ShutdownTimeout:          5 * time.Second,
+		RateLimitEvery100MS:      5,
+		SubnetMask:               24,
+		SubnetRateLimit:          2,
+		MaxConnectionsCap:        1000,
+		MaxRequestsPerConnection: 3,
+		PreStopDelay:             time.Second,
+		WorkerPoolSize:           4,
+		ChallengeSalt:            "super-secret-salt",
+	}
+
+	for _, c := range []config.Config{cfg, cfg.SafeCopy()} {
+		data, err := json.Marshal(c)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "super-secret-salt")
+		assert.Contains(t, string(data), `"max_connections":100`)
+		assert.Contains(t, string(data), `"worker_pool_size":4`)
+	}
+
+	assert.Empty(t, cfg.SafeCopy().ChallengeSalt)
+	assert.Equal(t, "super-secret-salt", cfg.ChallengeSalt, "SafeCopy should not modify the original")
+}
+
+// TestAutoTuneScalesWithCPUs ensures MaxConnections grows with the CPU count and stays within bounds.
+func TestAutoTuneScalesWithCPUs(t *testing.T) {
+	small := config.Config{}.AutoTuneFor(config.Resources{CPUs: 1})
+	large := config.Config{RateLimitEvery100MS: 5}.AutoTuneFor(config.Resources{CPUs: 16})
+
+	assert.Equal(t, 256, small.MaxConnections)
+	assert.Equal(t, 4096, large.MaxConnections)
+	assert.Zero(t, large.WorkerPoolSize, "The worker pool should stay disabled unless configured")
+	assert.NoError(t, large.Validate())
+
+	huge := config.Config{}.AutoTuneFor(config.Resources{CPUs: 1024})
+	assert.Equal(t, config.DefaultMaxConnectionsCap, huge.MaxConnections)
+
+	none := config.Config{}.AutoTuneFor(config.Resources{})
+	assert.Equal(t, 256, none.MaxConnections, "Unknown CPU count should count as one CPU")
+}
+
+// TestAutoTuneMemory ensures low memory lowers MaxConnections, but never below the minimum.
+func TestAutoTuneMemory(t *testing.T) {
+	cfg := config.Config{}.AutoTuneFor(config.Resources{CPUs: 16, MemoryBytes: 64 << 20})
+	assert.Equal(t, 128, cfg.MaxConnections, "A quarter of 64 MiB at 128 KiB per connection")
+
+	tiny := config.Config{}.AutoTuneFor(config.Resources{CPUs: 16, MemoryBytes: 1 << 20})
+	assert.Equal(t, 16, tiny.MaxConnections)
+}
+
+// TestAutoTuneKeepsExplicitValues ensures configured values are not overridden.
+func TestAutoTuneKeepsExplicitValues(t *testing.T) {
+	cfg := config.Config{MaxConnections: 100, WorkerPoolSize: 8}.AutoTuneFor(config.Resources{CPUs: 16})
+	assert.Equal(t, 100, cfg.MaxConnections)
+	assert.Equal(t, 8, cfg.WorkerPoolSize)
+
+	detected := config.Config{RateLimitEvery100MS: 5}.AutoTune()
+	assert.NoError(t, detected.Validate())
+	assert.Positive(t, detected.MaxConnections)
+	assert.Zero(t, detected.WorkerPoolSize)
+}
+
+// TestLineEnding ensures only known line endings are accepted and mapped to their characters.
+func TestLineEnding(t *testing.T) {
+	cfg := config.Config{MaxConnections: 100, RateLimitEvery100MS: 5}
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "\n", cfg.LineTerminator())
+
+	cfg.LineEnding = config.LineEndingCRLF
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "\r\n", cfg.LineTerminator())
+
+	cfg.LineEnding = "cr"
+	assert.ErrorContains(t, cfg.Validate(), "unknown line ending")
+}
+
+// TestValidateProbeLogging ensures only known probe logging modes are accepted.
+func TestValidateProbeLogging(t *testing.T) {
+	cfg := config.Config{MaxConnections: 100, RateLimitEvery100MS: 5, ProbeLogging: config.ProbeLogSilent}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.ProbeLogging = "verbose"
+	assert.ErrorContains(t, cfg.Validate(), "unknown probe logging mode")
+}
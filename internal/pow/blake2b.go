@@ -0,0 +1,57 @@
+package pow
+
+import (
+	"encoding/hex"
+	"golang.org/x/crypto/blake2b"
+	"strings"
+	"sync/atomic"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// BLAKE2bPoW uses BLAKE2b-256 instead of SHA-256 with the same challenge and
+// solution format. Clients must hash with the same algorithm.
+type BLAKE2bPoW struct {
+	difficulty atomic.Int64
+	nonces     *nonceSource
+	salt       string
+	legacy     bool
+	compat     int
+}
+
+func NewBLAKE2bPoW(difficulty int, opts ...Option) PoW {
+	o := newOptions(opts)
+	p := &BLAKE2bPoW{
+		nonces: newNonceSource(o.reader),
+		salt:   o.salt,
+		legacy: o.legacyHashing,
+		compat: o.compat,
+	}
+	p.difficulty.Store(int64(difficulty))
+	return p
+}
+
+// GenerateChallenge creates a random challenge string.
+func (p *BLAKE2bPoW) GenerateChallenge() string {
+	return p.GenerateChallengeAt(p.Difficulty())
+}
+
+// ValidateChallenge checks if the provided solution meets the difficulty embedded in the challenge.
+func (p *BLAKE2bPoW) ValidateChallenge(challenge, solution string) bool {
+	difficulty, ok := challengeDifficulty(challenge, p.salt, p.compat)
+	if !ok {
+		return false
+	}
+
+	hash := blake2b.Sum256(protocol.SolutionHashInput(challenge, solution, p.legacy))
+	return strings.HasPrefix(hex.EncodeToString(hash[:]), strings.Repeat("0", difficulty))
+}
+
+// Difficulty returns the difficulty used for new challenges.
+func (p *BLAKE2bPoW) Difficulty() int {
+	return int(p.difficulty.Load())
+}
+
+// SetDifficulty changes the difficulty of new challenges at runtime.
+func (p *BLAKE2bPoW) SetDifficulty(difficulty int) {
+	p.difficulty.Store(int64(difficulty))
+}
@@ -0,0 +1,83 @@
+package pow
+
+import (
+	"sync"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// DefaultChallengePoolSize is the recommended BufferedPoW pool size: ten
+// times the expected number of concurrent callers. Pools smaller than the
+// number of callers keep running dry and fall back to on-demand generation,
+// larger ones only hold more memory. BenchmarkBufferedPoW_GenerateChallenge
+// shows that with plain SHA-256 challenges crypto/rand is fast enough for the
+// pool to stay within noise, so it only pays off for slow inner PoWs or
+// entropy sources.
+const DefaultChallengePoolSize = 100
+
+// BufferedPoW hands out challenges pre-generated in the background, so
+// issuing a challenge does not wait for the random source. When the pool is
+// empty, challenges are generated on demand.
+type BufferedPoW struct {
+	PoW
+	pool     chan string
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBufferedPoW keeps up to size challenges of inner ready. A size of zero
+// disables the pool. Close stops the background generation.
+func NewBufferedPoW(inner PoW, size int) *BufferedPoW {
+	p := &BufferedPoW{
+		PoW:  inner,
+		pool: make(chan string, size),
+		done: make(chan struct{}),
+	}
+	if size > 0 {
+		go p.fill()
+	}
+	return p
+}
+
+// fill keeps the pool full until Close
+func (p *BufferedPoW) fill() {
+	for {
+		challenge := p.PoW.GenerateChallenge()
+		select {
+		case p.pool <- challenge:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// GenerateChallenge returns a pre-generated challenge, or a new one when the pool is empty.
+// Pooled challenges issued before a difficulty change are skipped.
+func (p *BufferedPoW) GenerateChallenge() string {
+	for {
+		select {
+		case challenge := <-p.pool:
+			if difficulty, err := protocol.ChallengeDifficulty(challenge); err == nil && difficulty == p.Difficulty() {
+				return challenge
+			}
+		default:
+			return p.PoW.GenerateChallenge()
+		}
+	}
+}
+
+// SetDifficulty changes the difficulty and drops pooled challenges issued with the old one.
+func (p *BufferedPoW) SetDifficulty(difficulty int) {
+	p.PoW.SetDifficulty(difficulty)
+	for {
+		select {
+		case <-p.pool:
+		default:
+			return
+		}
+	}
+}
+
+// Close stops the background generation
+func (p *BufferedPoW) Close() {
+	p.stopOnce.Do(func() { close(p.done) })
+}
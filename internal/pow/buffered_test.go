@@ -0,0 +1,56 @@
+package pow_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"word-of-wisdom/internal/pow"
+)
+
+// TestBufferedPoW ensures pooled challenges are valid and follow difficulty changes.
+func TestBufferedPoW(t *testing.T) {
+	p := pow.NewBufferedPoW(pow.NewSHA256PoW(2), 10)
+	defer p.Close()
+
+	challenge := p.GenerateChallenge()
+	if !p.ValidateChallenge(challenge, solvePoW(challenge, 2)) {
+		t.Fatal("Valid PoW solution was rejected")
+	}
+
+	p.SetDifficulty(3)
+	for i := 0; i < 20; i++ {
+		if challenge := p.GenerateChallenge(); !strings.HasPrefix(challenge, "3:") {
+			t.Fatalf("Expected difficulty 3 after the change, got %q", challenge)
+		}
+	}
+}
+
+// BenchmarkBufferedPoW_GenerateChallenge measures the latency of issuing a
+// challenge with 10 concurrent callers for several pool sizes. The pool size
+// below the caller count shows the pool-empty case.
+func BenchmarkBufferedPoW_GenerateChallenge(b *testing.B) {
+	const callers = 10
+
+	for _, size := range []int{0, 5, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("pool=%d", size), func(b *testing.B) {
+			p := pow.NewBufferedPoW(pow.NewSHA256PoW(4), size)
+			defer p.Close()
+
+			var wg sync.WaitGroup
+			perCaller := b.N/callers + 1
+
+			b.ResetTimer()
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < perCaller; j++ {
+						p.GenerateChallenge()
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
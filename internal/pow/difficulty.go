@@ -0,0 +1,38 @@
+package pow
+
+import "math"
+
+// bitsPerLevel is the number of hash bits fixed by one difficulty level:
+// each level requires one more leading zero hex digit.
+const bitsPerLevel = 4
+
+// DifficultyForAttempts converts an approximate number of hash attempts into
+// the nearest difficulty level. Solving is probabilistic: at difficulty d a
+// client needs 16^d attempts on average, but any single solve may take far
+// fewer or far more. The nearest level is chosen on the log2 scale.
+func DifficultyForAttempts(attempts uint64) int {
+	if attempts <= 1 {
+		return 0
+	}
+
+	bits := math.Log2(float64(attempts))
+	return int(math.Round(bits / bitsPerLevel))
+}
+
+// ExpectedAttempts returns the average number of hash attempts needed to solve
+// a challenge at the given difficulty. Difficulties of 16 and above need 2^64
+// attempts or more and are clamped to math.MaxUint64.
+func ExpectedAttempts(difficulty int) uint64 {
+	if difficulty <= 0 {
+		return 1
+	}
+	if difficulty >= 64/bitsPerLevel {
+		return math.MaxUint64
+	}
+	return uint64(1) << (bitsPerLevel * difficulty)
+}
+
+// NewSHA256PoWForAttempts creates a SHA-256 PoW whose difficulty requires roughly the given number of attempts.
+func NewSHA256PoWForAttempts(attempts uint64) PoW {
+	return NewSHA256PoW(DifficultyForAttempts(attempts))
+}
@@ -0,0 +1,72 @@
+package pow_test
+
+import (
+	"math"
+	"testing"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestDifficultyForAttempts ensures the conversion picks the nearest difficulty level.
+func TestDifficultyForAttempts(t *testing.T) {
+	cases := []struct {
+		attempts uint64
+		expected int
+	}{
+		{0, 0},
+		{1, 0},
+		{16, 1},
+		{65536, 4},
+		{100_000, 4},
+		{1_000_000, 5},
+		{1 << 20, 5},
+		{1 << 21, 5},
+		{1 << 23, 6},
+	}
+
+	for _, c := range cases {
+		if got := pow.DifficultyForAttempts(c.attempts); got != c.expected {
+			t.Errorf("DifficultyForAttempts(%d) = %d, expected %d", c.attempts, got, c.expected)
+		}
+	}
+}
+
+// TestExpectedAttemptsRoundTrip ensures converting back and forth keeps the difficulty.
+func TestExpectedAttemptsRoundTrip(t *testing.T) {
+	for difficulty := 0; difficulty <= 8; difficulty++ {
+		if got := pow.DifficultyForAttempts(pow.ExpectedAttempts(difficulty)); got != difficulty {
+			t.Errorf("Round trip of difficulty %d returned %d", difficulty, got)
+		}
+	}
+}
+
+// TestExpectedAttemptsClamped ensures difficulties beyond 64 bits do not wrap around.
+func TestExpectedAttemptsClamped(t *testing.T) {
+	cases := map[int]uint64{
+		-1: 1,
+		15: 1 << 60,
+		16: math.MaxUint64,
+		17: math.MaxUint64,
+		64: math.MaxUint64,
+	}
+	for difficulty, want := range cases {
+		if got := pow.ExpectedAttempts(difficulty); got != want {
+			t.Errorf("ExpectedAttempts(%d) = %d, want %d", difficulty, got, want)
+		}
+	}
+}
+
+// TestNewSHA256PoWForAttempts ensures validation works with the converted difficulty.
+func TestNewSHA256PoWForAttempts(t *testing.T) {
+	p := pow.NewSHA256PoWForAttempts(4096)
+	challenge := p.GenerateChallenge()
+
+	difficulty, err := protocol.ChallengeDifficulty(challenge)
+	if err != nil || difficulty != 3 {
+		t.Fatalf("Expected advertised difficulty 3, got %d (%v)", difficulty, err)
+	}
+
+	if !p.ValidateChallenge(challenge, solvePoW(challenge, difficulty)) {
+		t.Fatal("Valid PoW solution was rejected")
+	}
+}
@@ -0,0 +1,86 @@
+package pow
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expirySeparator splits the issued challenge from its expiry
+const expirySeparator = "@"
+
+// ExpiringPoW embeds an expiry into the challenges of an inner PoW, so
+// solutions to challenges hoarded by clients are rejected once they expire.
+// The expiry is only tamper-proof when the challenges are also signed,
+// e.g. by wrapping ExpiringPoW in HMACDistributedPoW.
+type ExpiringPoW struct {
+	PoW
+
+	ttl  time.Duration
+	skew time.Duration
+	now  func() time.Time
+}
+
+// ExpiringOption configures an ExpiringPoW
+type ExpiringOption func(*ExpiringPoW)
+
+// WithClockSkew accepts challenges up to skew past their expiry, tolerating
+// clock differences between the instances issuing and validating them
+func WithClockSkew(skew time.Duration) ExpiringOption {
+	return func(p *ExpiringPoW) {
+		p.skew = skew
+	}
+}
+
+// WithExpiryClock replaces time.Now, e.g. to control time in tests
+func WithExpiryClock(now func() time.Time) ExpiringOption {
+	return func(p *ExpiringPoW) {
+		p.now = now
+	}
+}
+
+// NewExpiringPoW wraps inner so that its challenges expire after ttl
+func NewExpiringPoW(ttl time.Duration, inner PoW, opts ...ExpiringOption) *ExpiringPoW {
+	p := &ExpiringPoW{
+		PoW: inner,
+		ttl: ttl,
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// GenerateChallenge appends the expiry in Unix milliseconds to the inner
+// challenge. Clients hash the whole string, the expiry included.
+func (p *ExpiringPoW) GenerateChallenge() string {
+	expiry := p.now().Add(p.ttl).UnixMilli()
+	return p.PoW.GenerateChallenge() + expirySeparator + strconv.FormatInt(expiry, 10)
+}
+
+// ValidateChallenge rejects challenges expired for longer than the clock skew
+// tolerance before checking the solution with the inner PoW.
+func (p *ExpiringPoW) ValidateChallenge(challenge, solution string) bool {
+	i := strings.LastIndex(challenge, expirySeparator)
+	if i < 0 {
+		return false
+	}
+
+	// Wrappers such as HMACDistributedPoW may append their own suffix
+	field := challenge[i+len(expirySeparator):]
+	if end := strings.IndexFunc(field, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+		field = field[:end]
+	}
+
+	expiry, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	if p.now().After(time.UnixMilli(expiry).Add(p.skew)) {
+		return false
+	}
+
+	return p.PoW.ValidateChallenge(challenge, solution)
+}
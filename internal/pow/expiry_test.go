@@ -0,0 +1,84 @@
+package pow_test
+
+import (
+	"testing"
+	"time"
+	"word-of-wisdom/internal/pow"
+)
+
+// TestExpiringPoWClockSkew ensures challenges just past their expiry are accepted
+// within the clock skew tolerance and rejected beyond it.
+func TestExpiringPoWClockSkew(t *testing.T) {
+	difficulty := 2
+	issuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+
+	p := pow.NewExpiringPoW(time.Minute, pow.NewSHA256PoW(difficulty),
+		pow.WithClockSkew(5*time.Second),
+		pow.WithExpiryClock(func() time.Time { return now }),
+	)
+
+	challenge := p.GenerateChallenge()
+	solution := solvePoW(challenge, difficulty)
+
+	if !p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Solution to a fresh challenge was rejected")
+	}
+
+	now = issuedAt.Add(time.Minute + 3*time.Second)
+	if !p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Challenge expired within the skew tolerance was rejected")
+	}
+
+	now = issuedAt.Add(time.Minute + 6*time.Second)
+	if p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Challenge expired beyond the skew tolerance was accepted")
+	}
+}
+
+// TestExpiringPoWNoSkew ensures challenges are rejected right after expiry by default.
+func TestExpiringPoWNoSkew(t *testing.T) {
+	difficulty := 2
+	issuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+
+	p := pow.NewExpiringPoW(time.Minute, pow.NewSHA256PoW(difficulty),
+		pow.WithExpiryClock(func() time.Time { return now }),
+	)
+
+	challenge := p.GenerateChallenge()
+	solution := solvePoW(challenge, difficulty)
+
+	now = issuedAt.Add(time.Minute + time.Millisecond)
+	if p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Expired challenge was accepted")
+	}
+	if p.ValidateChallenge(challenge+"0", solution) {
+		t.Fatal("Challenge with a tampered expiry was accepted")
+	}
+}
+
+// TestExpiringPoWSigned ensures the expiry is checked when the challenge is also signed.
+func TestExpiringPoWSigned(t *testing.T) {
+	difficulty := 2
+	issuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+
+	p := pow.NewHMACDistributedPoW([]byte("cluster-secret"), pow.NewExpiringPoW(time.Minute, pow.NewSHA256PoW(difficulty),
+		pow.WithClockSkew(5*time.Second),
+		pow.WithExpiryClock(func() time.Time { return now }),
+	))
+
+	challenge := p.GenerateChallenge()
+	solution := solvePoW(challenge, difficulty)
+
+	now = issuedAt.Add(time.Minute + 3*time.Second)
+	if !p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Signed challenge expired within the skew tolerance was rejected")
+	}
+
+	now = issuedAt.Add(time.Minute + 6*time.Second)
+	if p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Signed challenge expired beyond the skew tolerance was accepted")
+	}
+}
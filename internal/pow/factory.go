@@ -0,0 +1,20 @@
+package pow
+
+import "fmt"
+
+const (
+	AlgorithmSHA256  = "sha256"
+	AlgorithmBLAKE2b = "blake2b"
+)
+
+// New creates a PoW implementation by algorithm name
+func New(algorithm string, difficulty int, opts ...Option) (PoW, error) {
+	switch algorithm {
+	case AlgorithmSHA256:
+		return NewSHA256PoW(difficulty, opts...), nil
+	case AlgorithmBLAKE2b:
+		return NewBLAKE2bPoW(difficulty, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown PoW algorithm %q", algorithm)
+	}
+}
@@ -0,0 +1,103 @@
+package pow
+
+import (
+	"crypto/sha1"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// hashcashVersion is the only stamp version accepted
+	hashcashVersion = "1"
+
+	// hashcashResource names the resource of issued stamps unless a salt is set
+	hashcashResource = "word-of-wisdom"
+
+	// hashcashDateLayout is the YYMMDDhhmmss stamp date
+	hashcashDateLayout = "060102150405"
+
+	// hashcashFields is the number of fields of a complete stamp
+	hashcashFields = 7
+)
+
+// HashcashPoW issues Hashcash v1 stamps, so off-the-shelf Hashcash clients
+// can solve them. The challenge is a stamp without its counter:
+//
+//	1:<bits>:<date>:<resource>:<ext>:<rand>:
+//
+// The client answers with the counter or with the complete stamp. The
+// difficulty is the number of leading zero bits of the stamp's SHA-1 hash.
+// The salt, if any, is used as the resource.
+type HashcashPoW struct {
+	difficulty atomic.Int64
+	nonces     *nonceSource
+	resource   string
+	now        func() time.Time
+}
+
+func NewHashcashPoW(difficulty int, opts ...Option) PoW {
+	o := newOptions(opts)
+	p := &HashcashPoW{
+		nonces:   newNonceSource(o.reader),
+		resource: hashcashResource,
+		now:      time.Now,
+	}
+	if o.salt != "" {
+		p.resource = o.salt
+	}
+	p.difficulty.Store(int64(difficulty))
+	return p
+}
+
+// GenerateChallenge creates a stamp without counter for the current difficulty.
+func (p *HashcashPoW) GenerateChallenge() string {
+	return p.GenerateChallengeAt(p.Difficulty())
+}
+
+// ValidateChallenge checks that the stamp completed by the solution is
+// issued for the resource and its SHA-1 hash has the embedded number of
+// leading zero bits.
+func (p *HashcashPoW) ValidateChallenge(challenge, solution string) bool {
+	stamp := solution
+	if !strings.HasPrefix(solution, challenge) {
+		stamp = challenge + solution
+	}
+
+	fields := strings.Split(stamp, ":")
+	if len(fields) != hashcashFields || fields[0] != hashcashVersion || fields[3] != p.resource || fields[6] == "" {
+		return false
+	}
+
+	difficulty, err := strconv.Atoi(fields[1])
+	if err != nil || difficulty < 0 {
+		return false
+	}
+
+	hash := sha1.Sum([]byte(stamp))
+	return leadingZeroBits(hash[:]) >= difficulty
+}
+
+// Difficulty returns the number of leading zero bits required by new stamps.
+func (p *HashcashPoW) Difficulty() int {
+	return int(p.difficulty.Load())
+}
+
+// SetDifficulty changes the difficulty of new stamps at runtime.
+func (p *HashcashPoW) SetDifficulty(difficulty int) {
+	p.difficulty.Store(int64(difficulty))
+}
+
+// leadingZeroBits counts the zero bits at the start of the hash
+func leadingZeroBits(hash []byte) int {
+	var n int
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package pow_test
+
+import (
+	"crypto/sha1"
+	"math/bits"
+	"strconv"
+	"strings"
+	"testing"
+	"word-of-wisdom/internal/pow"
+)
+
+// solveHashcash finds a counter giving the stamp the required leading zero bits
+func solveHashcash(challenge string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		hash := sha1.Sum([]byte(challenge + strconv.Itoa(counter)))
+		var zeros int
+		for _, b := range hash {
+			zeros += bits.LeadingZeros8(b)
+			if b != 0 {
+				break
+			}
+		}
+		if zeros >= difficulty {
+			return strconv.Itoa(counter)
+		}
+	}
+}
+
+// TestHashcashKnownTokens validates stamps minted by the reference Hashcash implementation.
+func TestHashcashKnownTokens(t *testing.T) {
+	p := pow.NewHashcashPoW(20, pow.WithSalt("adam@cypherspace.org"))
+
+	tokens := []string{
+		"1:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:ckvi",
+		"1:20:060408:adam@cypherspace.org::1QTjaYd7niiQA/sc:ePa",
+	}
+	for _, token := range tokens {
+		i := strings.LastIndex(token, ":") + 1
+		if !p.ValidateChallenge(token[:i], token[i:]) {
+			t.Fatalf("Valid token %q was rejected", token)
+		}
+		if !p.ValidateChallenge(token[:i], token) {
+			t.Fatalf("Valid token %q sent as a complete stamp was rejected", token)
+		}
+	}
+
+	// The same token claiming a higher difficulty does not meet it
+	if p.ValidateChallenge("1:30:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:", "ckvi") {
+		t.Fatal("Token with a raised difficulty was accepted")
+	}
+	if p.ValidateChallenge("1:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:", "ckvj") {
+		t.Fatal("Token with a wrong counter was accepted")
+	}
+	if pow.NewHashcashPoW(20).ValidateChallenge("1:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:", "ckvi") {
+		t.Fatal("Token for another resource was accepted")
+	}
+}
+
+// TestHashcashGenerateChallenge ensures issued stamps have the Hashcash format and can be solved.
+func TestHashcashGenerateChallenge(t *testing.T) {
+	difficulty := 8
+	p := pow.NewHashcashPoW(difficulty)
+
+	challenge := p.GenerateChallenge()
+	fields := strings.Split(challenge, ":")
+	if len(fields) != 7 || fields[0] != "1" || fields[1] != "8" || fields[3] != "word-of-wisdom" || fields[6] != "" {
+		t.Fatalf("Unexpected stamp format %q", challenge)
+	}
+	if len(fields[2]) != len("060102150405") {
+		t.Fatalf("Unexpected stamp date %q", fields[2])
+	}
+
+	if !p.ValidateChallenge(challenge, solveHashcash(challenge, difficulty)) {
+		t.Fatal("Valid stamp was rejected")
+	}
+	if p.ValidateChallenge(challenge, "") {
+		t.Fatal("Stamp without a counter was accepted")
+	}
+}
@@ -0,0 +1,29 @@
+package pow
+
+import (
+	"crypto/sha256"
+	"hash"
+	"sync"
+)
+
+// hasherPool reuses SHA-256 states across validations, see WithHasherPool
+type hasherPool struct {
+	pool sync.Pool
+}
+
+func newHasherPool() *hasherPool {
+	return &hasherPool{pool: sync.Pool{New: func() any { return sha256.New() }}}
+}
+
+// sum returns the SHA-256 digest of data like sha256.Sum256
+func (p *hasherPool) sum(data []byte) [sha256.Size]byte {
+	h := p.pool.Get().(hash.Hash)
+	defer p.pool.Put(h)
+
+	h.Reset()
+	h.Write(data)
+
+	var digest [sha256.Size]byte
+	h.Sum(digest[:0])
+	return digest
+}
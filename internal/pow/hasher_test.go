@@ -0,0 +1,86 @@
+package pow_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"word-of-wisdom/internal/pow"
+)
+
+// TestHasherPoolMatchesStdlib ensures pooled hashers accept exactly the
+// solutions sha256.Sum256 accepts, also when shared between goroutines.
+func TestHasherPoolMatchesStdlib(t *testing.T) {
+	std := pow.NewSHA256PoW(2)
+	pooled := pow.NewSHA256PoW(2, pow.WithHasherPool(true))
+	challenge := std.GenerateChallenge()
+
+	var wg sync.WaitGroup
+	accepted := make([]int, 8)
+	for g := range accepted {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for nonce := g * 1000; nonce < (g+1)*1000; nonce++ {
+				solution := strconv.Itoa(nonce)
+				valid := std.ValidateChallenge(challenge, solution)
+				if pooled.ValidateChallenge(challenge, solution) != valid {
+					t.Errorf("Pooled hasher disagrees on solution %q", solution)
+					return
+				}
+				if valid {
+					accepted[g]++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var total int
+	for _, n := range accepted {
+		total += n
+	}
+	// About 1 in 256 solutions meets difficulty 2
+	if total == 0 {
+		t.Fatal("Expected some of 8000 solutions to be valid")
+	}
+}
+
+// benchmarkValidateChallenge validates 10 000 solutions per iteration, a
+// second worth of a busy server, and reports the allocations per validation
+func benchmarkValidateChallenge(b *testing.B, p pow.PoW) {
+	const validations = 10_000
+
+	challenge := p.GenerateChallenge()
+	solutions := make([]string, validations)
+	for i := range solutions {
+		solutions[i] = strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, solution := range solutions {
+			p.ValidateChallenge(challenge, solution)
+		}
+	}
+	b.ReportMetric(float64(testing.AllocsPerRun(1, func() { p.ValidateChallenge(challenge, "12345") })), "allocs/validation")
+}
+
+// BenchmarkValidateChallenge_HasherPool compares sha256.Sum256 with pooled
+// hashers, see WithHasherPool.
+//
+// On a single-core linux/amd64 VM with Go 1.24, 10 000 validations:
+//
+//	Stdlib      4.5-5.0ms, 3 allocs/validation
+//	HasherPool  5.1-6.8ms, 4 allocs/validation
+//
+// sha256.Sum256 keeps its state on the stack, so the pool cannot save the
+// allocations it was meant to and the digest escapes through hash.Hash.Sum.
+func BenchmarkValidateChallenge_HasherPool(b *testing.B) {
+	b.Run("Stdlib", func(b *testing.B) {
+		benchmarkValidateChallenge(b, pow.NewSHA256PoW(4))
+	})
+	b.Run("HasherPool", func(b *testing.B) {
+		benchmarkValidateChallenge(b, pow.NewSHA256PoW(4, pow.WithHasherPool(true)))
+	})
+}
@@ -0,0 +1,114 @@
+package pow
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// macSeparator splits the issued challenge from its HMAC
+const macSeparator = "."
+
+// HMACDistributedPoW signs the challenges of an inner PoW with a secret shared
+// by all server instances. Any instance holding the secret can validate a
+// challenge issued by another one, and clients cannot forge challenges, e.g.
+// with a lower embedded difficulty.
+//
+// Secrets are rotated with SetSecret: the previous secret keeps validating
+// in-flight challenges until it is retired with RetireSecret.
+type HMACDistributedPoW struct {
+	PoW
+
+	mu       sync.RWMutex
+	secret   []byte
+	previous [][]byte
+}
+
+// NewHMACDistributedPoW wraps inner so that its challenges carry an HMAC of the shared secret
+func NewHMACDistributedPoW(secret []byte, inner PoW) *HMACDistributedPoW {
+	return &HMACDistributedPoW{
+		PoW:    inner,
+		secret: secret,
+	}
+}
+
+// SetSecret signs new challenges with secret. The replaced secret is still
+// accepted for validation until it is retired.
+func (p *HMACDistributedPoW) SetSecret(secret []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if bytes.Equal(secret, p.secret) {
+		return
+	}
+	p.previous = append(p.previous, p.secret)
+	p.secret = secret
+}
+
+// RetireSecret stops accepting challenges signed with a previous secret.
+// The current signing secret cannot be retired.
+func (p *HMACDistributedPoW) RetireSecret(secret []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.previous = slices.DeleteFunc(p.previous, func(s []byte) bool {
+		return bytes.Equal(s, secret)
+	})
+}
+
+// GenerateChallenge appends the HMAC to the inner challenge. Clients hash the
+// whole string, the HMAC included.
+func (p *HMACDistributedPoW) GenerateChallenge() string {
+	p.mu.RLock()
+	secret := p.secret
+	p.mu.RUnlock()
+
+	challenge := p.PoW.GenerateChallenge()
+	return challenge + macSeparator + hex.EncodeToString(sign(secret, challenge))
+}
+
+// ValidateChallenge verifies the HMAC before checking the solution with the inner PoW.
+func (p *HMACDistributedPoW) ValidateChallenge(challenge, solution string) bool {
+	i := strings.LastIndex(challenge, macSeparator)
+	if i < 0 {
+		return false
+	}
+
+	mac, err := hex.DecodeString(challenge[i+len(macSeparator):])
+	if err != nil {
+		return false
+	}
+
+	if !p.signedWithAcceptedSecret(challenge[:i], mac) {
+		return false
+	}
+
+	return p.PoW.ValidateChallenge(challenge, solution)
+}
+
+// signedWithAcceptedSecret checks the MAC against the current and previous secrets
+func (p *HMACDistributedPoW) signedWithAcceptedSecret(challenge string, mac []byte) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if hmac.Equal(mac, sign(p.secret, challenge)) {
+		return true
+	}
+	for _, secret := range p.previous {
+		if hmac.Equal(mac, sign(secret, challenge)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns the HMAC-SHA256 of the challenge
+func sign(secret []byte, challenge string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(challenge))
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package pow_test
+
+import (
+	"testing"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestHMACDistributedPoW simulates two instances sharing a secret: a challenge
+// issued by one of them is validated by the other.
+func TestHMACDistributedPoW(t *testing.T) {
+	difficulty := 3
+	secret := []byte("cluster-secret")
+
+	instanceA := pow.NewHMACDistributedPoW(secret, pow.NewSHA256PoW(difficulty))
+	instanceB := pow.NewHMACDistributedPoW(secret, pow.NewSHA256PoW(difficulty))
+
+	challenge := instanceA.GenerateChallenge()
+	solution := solvePoW(challenge, difficulty)
+
+	if !instanceB.ValidateChallenge(challenge, solution) {
+		t.Fatal("Solution to a challenge from another instance was rejected")
+	}
+	if instanceB.ValidateChallenge(challenge, "invalid") {
+		t.Fatal("Invalid PoW solution was accepted")
+	}
+
+	other := pow.NewHMACDistributedPoW([]byte("other-secret"), pow.NewSHA256PoW(difficulty))
+	if other.ValidateChallenge(challenge, solution) {
+		t.Fatal("Challenge signed with a different secret was accepted")
+	}
+}
+
+// TestHMACDistributedPoWForgedChallenge ensures unsigned or tampered challenges are rejected.
+func TestHMACDistributedPoWForgedChallenge(t *testing.T) {
+	p := pow.NewHMACDistributedPoW([]byte("cluster-secret"), pow.NewSHA256PoW(4))
+
+	// A challenge with a lowered difficulty is trivially solved, but not signed
+	forged := protocol.FormatChallenge(0, "deadbeef")
+	if p.ValidateChallenge(forged, "1") {
+		t.Fatal("Unsigned challenge was accepted")
+	}
+
+	challenge := p.GenerateChallenge()
+	tampered := protocol.FormatChallenge(0, challenge[len("4:"):])
+	if p.ValidateChallenge(tampered, "1") {
+		t.Fatal("Tampered challenge was accepted")
+	}
+}
+
+// TestHMACDistributedPoWSecretRotation ensures challenges signed with a previous
+// secret validate during the overlap window and fail once it is retired.
+func TestHMACDistributedPoWSecretRotation(t *testing.T) {
+	difficulty := 2
+	oldSecret := []byte("old-secret")
+	newSecret := []byte("new-secret")
+
+	p := pow.NewHMACDistributedPoW(oldSecret, pow.NewSHA256PoW(difficulty))
+
+	inFlight := p.GenerateChallenge()
+	solution := solvePoW(inFlight, difficulty)
+
+	p.SetSecret(newSecret)
+
+	fresh := p.GenerateChallenge()
+	if !p.ValidateChallenge(fresh, solvePoW(fresh, difficulty)) {
+		t.Fatal("Challenge signed with the new secret was rejected")
+	}
+	if !p.ValidateChallenge(inFlight, solution) {
+		t.Fatal("Challenge signed with the previous secret was rejected during overlap")
+	}
+
+	p.RetireSecret(oldSecret)
+
+	if p.ValidateChallenge(inFlight, solution) {
+		t.Fatal("Challenge signed with a retired secret was accepted")
+	}
+	if !p.ValidateChallenge(fresh, solvePoW(fresh, difficulty)) {
+		t.Fatal("Retiring a previous secret should not affect the current one")
+	}
+}
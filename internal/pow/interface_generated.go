@@ -7,5 +7,11 @@ type PoW interface {
 	// GenerateChallenge creates a random challenge string.
 	GenerateChallenge() string
 	// ValidateChallenge checks if the provided solution meets the required difficulty.
+	// The difficulty embedded in the challenge is used, so challenges issued before
+	// a difficulty change are validated at the difficulty they were issued with.
 	ValidateChallenge(challenge, solution string) bool
+	// Difficulty returns the difficulty used for new challenges.
+	Difficulty() int
+	// SetDifficulty changes the difficulty of new challenges at runtime.
+	SetDifficulty(difficulty int)
 }
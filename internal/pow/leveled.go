@@ -0,0 +1,67 @@
+package pow
+
+import (
+	"strconv"
+	"strings"
+)
+
+// GenerateChallengeAt creates a random challenge at the given difficulty,
+// leaving the difficulty of GenerateChallenge unchanged, e.g. to raise it
+// per connection. ValidateChallenge checks it at the embedded difficulty.
+func (p *SHA256PoW) GenerateChallengeAt(difficulty int) string {
+	return newChallenge(p.nonces, difficulty, p.salt)
+}
+
+// GenerateChallengeAt creates a random challenge at the given difficulty.
+func (p *BLAKE2bPoW) GenerateChallengeAt(difficulty int) string {
+	return newChallenge(p.nonces, difficulty, p.salt)
+}
+
+// GenerateCompatChallenge creates a challenge in the format predating the
+// advertised difficulty, without it, for clients unable to parse it.
+// ValidateChallenge checks it at the difficulty of WithCompatDifficulty.
+func (p *SHA256PoW) GenerateCompatChallenge() string {
+	return p.salt + p.nonces.nonce()
+}
+
+// GenerateCompatChallenge creates a challenge without difficulty, see SHA256PoW.GenerateCompatChallenge.
+func (p *BLAKE2bPoW) GenerateCompatChallenge() string {
+	return p.salt + p.nonces.nonce()
+}
+
+// GenerateCompatChallengeAt creates a challenge without difficulty like
+// GenerateCompatChallenge, validated at the given difficulty when it exceeds
+// the compat difficulty, e.g. to penalize legacy clients. The raised
+// difficulty is appended to the nonce, which legacy clients treat as opaque.
+func (p *SHA256PoW) GenerateCompatChallengeAt(difficulty int) string {
+	return newCompatChallenge(p.nonces, difficulty, p.compat, p.salt)
+}
+
+// GenerateCompatChallengeAt creates a challenge without difficulty, see SHA256PoW.GenerateCompatChallengeAt.
+func (p *BLAKE2bPoW) GenerateCompatChallengeAt(difficulty int) string {
+	return newCompatChallenge(p.nonces, difficulty, p.compat, p.salt)
+}
+
+// CompatDifficulty returns the difficulty compat challenges are validated at, zero if rejected
+func (p *SHA256PoW) CompatDifficulty() int {
+	return p.compat
+}
+
+// CompatDifficulty returns the difficulty compat challenges are validated at, zero if rejected
+func (p *BLAKE2bPoW) CompatDifficulty() int {
+	return p.compat
+}
+
+// GenerateChallengeAt creates a stamp without counter requiring the given
+// number of leading zero bits.
+func (p *HashcashPoW) GenerateChallengeAt(difficulty int) string {
+	return strings.Join([]string{
+		hashcashVersion,
+		strconv.Itoa(difficulty),
+		p.now().UTC().Format(hashcashDateLayout),
+		p.resource,
+		"",
+		p.nonces.nonce(),
+		"",
+	}, ":")
+}
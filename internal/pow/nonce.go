@@ -0,0 +1,53 @@
+package pow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// nonceSize is the number of random bytes in a challenge nonce
+	nonceSize = 8
+
+	// nonceReadAttempts bounds the retries of a failing random source
+	nonceReadAttempts = 3
+)
+
+// nonceSource generates challenge nonces from crypto/rand. If the random
+// source keeps failing, it falls back to a time-seeded math/rand generator
+// rather than panicking or issuing an empty challenge.
+type nonceSource struct {
+	reader io.Reader
+
+	mu       sync.Mutex
+	fallback *mathrand.Rand
+}
+
+func newNonceSource(reader io.Reader) *nonceSource {
+	if reader == nil {
+		reader = rand.Reader
+	}
+	return &nonceSource{
+		reader:   reader,
+		fallback: mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// nonce returns a hex encoded random nonce
+func (s *nonceSource) nonce() string {
+	b := make([]byte, nonceSize)
+	for attempt := 0; attempt < nonceReadAttempts; attempt++ {
+		if _, err := io.ReadFull(s.reader, b); err == nil {
+			return hex.EncodeToString(b)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%016x", s.fallback.Uint64())
+}
@@ -0,0 +1,74 @@
+package pow_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"word-of-wisdom/internal/pow"
+)
+
+// failingReader fails the first n reads, then reads from r
+type failingReader struct {
+	n int
+	r *bytes.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n != 0 {
+		f.n--
+		return 0, errors.New("entropy unavailable")
+	}
+	return f.r.Read(p)
+}
+
+// TestGenerateChallengeRandFailure ensures a failing random source yields usable, distinct challenges.
+func TestGenerateChallengeRandFailure(t *testing.T) {
+	difficulty := 2
+	p := pow.NewSHA256PoW(difficulty, pow.WithRandReader(&failingReader{n: -1}))
+
+	challenge1 := p.GenerateChallenge()
+	challenge2 := p.GenerateChallenge()
+
+	_, nonce, _ := strings.Cut(challenge1, ":")
+	if nonce == "" {
+		t.Fatal("Challenge nonce should not be empty")
+	}
+	if challenge1 == challenge2 {
+		t.Fatal("Generated challenges should be different")
+	}
+	if !p.ValidateChallenge(challenge1, solvePoW(challenge1, difficulty)) {
+		t.Fatal("Valid PoW solution was rejected")
+	}
+}
+
+// TestGenerateChallengeRandRetry ensures a transient failure of the random source is retried.
+func TestGenerateChallengeRandRetry(t *testing.T) {
+	reader := &failingReader{n: 2, r: bytes.NewReader(bytes.Repeat([]byte{0xab}, 8))}
+	p := pow.NewSHA256PoW(2, pow.WithRandReader(reader))
+
+	if challenge := p.GenerateChallenge(); challenge != "2:abababababababab" {
+		t.Fatalf("Expected the nonce from the random source, got %q", challenge)
+	}
+}
+
+// TestGenerateChallengeFixedEntropy ensures a fixed entropy source yields the same challenges on every run.
+func TestGenerateChallengeFixedEntropy(t *testing.T) {
+	entropy := bytes.Repeat([]byte("0123456789abcdef"), 4)
+
+	for name, newPoW := range map[string]func(pow.Option) pow.PoW{
+		"sha256":  func(opt pow.Option) pow.PoW { return pow.NewSHA256PoW(3, opt) },
+		"blake2b": func(opt pow.Option) pow.PoW { return pow.NewBLAKE2bPoW(3, opt) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			first := newPoW(pow.WithRandReader(bytes.NewReader(entropy)))
+			second := newPoW(pow.WithRandReader(bytes.NewReader(entropy)))
+
+			for i := 0; i < 4; i++ {
+				if a, b := first.GenerateChallenge(), second.GenerateChallenge(); a != b {
+					t.Fatalf("Challenge %d differs between runs: %q and %q", i, a, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package pow
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"word-of-wisdom/pkg/protocol"
+)
+
+type options struct {
+	salt          string
+	reader        io.Reader
+	legacyHashing bool
+	compat        int
+	hasherPool    bool
+}
+
+// Option configures a PoW implementation
+type Option func(*options)
+
+// WithSalt mixes a deployment specific token into every challenge. It is
+// hashed together with the challenge, so solutions are only valid for the
+// deployment that issued them. Clients treat it as part of the opaque challenge.
+func WithSalt(salt string) Option {
+	return func(o *options) {
+		o.salt = salt
+	}
+}
+
+// WithLegacyHashing hashes the challenge and solution concatenated without
+// protocol.SolutionSeparator, as clients built before the separator do
+func WithLegacyHashing(enabled bool) Option {
+	return func(o *options) {
+		o.legacyHashing = enabled
+	}
+}
+
+// WithCompatDifficulty validates challenges in the format predating the
+// advertised difficulty, a bare nonce, at the given difficulty, so clients
+// unable to parse the difficulty can still be served, see
+// SHA256PoW.GenerateCompatChallenge. Zero rejects such challenges.
+func WithCompatDifficulty(difficulty int) Option {
+	return func(o *options) {
+		o.compat = difficulty
+	}
+}
+
+// WithHasherPool hashes solutions with SHA-256 states reused through a
+// sync.Pool instead of sha256.Sum256. sha256.Sum256 keeps its state on the
+// stack, so the pool saves no allocation, see BenchmarkValidateChallenge_HasherPool,
+// and is off by default.
+func WithHasherPool(enabled bool) Option {
+	return func(o *options) {
+		o.hasherPool = enabled
+	}
+}
+
+// WithRandReader replaces crypto/rand as the source of challenge nonces, e.g.
+// with a fixed reader to issue the same challenges on every run in tests
+func WithRandReader(reader io.Reader) Option {
+	return func(o *options) {
+		o.reader = reader
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// compatDifficultySeparator appends a raised difficulty to the nonce of a
+// compat challenge. Nonces are hex, so it cannot be mistaken for part of one.
+const compatDifficultySeparator = "+"
+
+// newCompatChallenge formats a random challenge without difficulty, appending
+// the difficulty to the nonce when it exceeds the compat difficulty
+func newCompatChallenge(nonces *nonceSource, difficulty, compat int, salt string) string {
+	challenge := salt + nonces.nonce()
+	if difficulty > compat {
+		challenge += compatDifficultySeparator + strconv.Itoa(difficulty)
+	}
+	return challenge
+}
+
+// newChallenge formats a random challenge with the difficulty and salt
+func newChallenge(nonces *nonceSource, difficulty int, salt string) string {
+	return protocol.FormatChallenge(difficulty, salt+nonces.nonce())
+}
+
+// challengeDifficulty returns the difficulty of a challenge issued with the
+// given salt. Compatibility challenges without difficulty have the compat
+// difficulty or the higher one appended to their nonce, unless it is zero.
+func challengeDifficulty(challenge, salt string, compat int) (int, bool) {
+	if compat > 0 && !strings.Contains(challenge, protocol.ChallengeSeparator) {
+		nonce, ok := strings.CutPrefix(challenge, salt)
+		if challenge == "" || !ok {
+			return 0, false
+		}
+		_, raised, ok := strings.Cut(nonce, compatDifficultySeparator)
+		if !ok {
+			return compat, true
+		}
+		difficulty, err := strconv.Atoi(raised)
+		if err != nil || difficulty <= compat {
+			return 0, false
+		}
+		return difficulty, true
+	}
+
+	difficulty, err := protocol.ChallengeDifficulty(challenge)
+	if err != nil {
+		return 0, false
+	}
+
+	_, nonce, _ := strings.Cut(challenge, protocol.ChallengeSeparator)
+	if !strings.HasPrefix(nonce, salt) {
+		return 0, false
+	}
+
+	return difficulty, true
+}
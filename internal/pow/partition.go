@@ -0,0 +1,36 @@
+package pow
+
+// NonceRange is the half-open range [Start, End) of nonces searched by a single solver worker
+type NonceRange struct {
+	Start, End int64
+}
+
+// PartitionNonceSpace divides [rangeStart, rangeEnd) into numWorkers
+// contiguous ranges whose sizes differ by at most one, the larger ones first.
+// Ranges are empty when there are more workers than nonces. It returns nil
+// when numWorkers is not positive or the space is empty. There is no parallel
+// solver yet; wowclient.Solve searches the nonces sequentially, so this is a
+// standalone helper for one.
+func PartitionNonceSpace(numWorkers int, rangeStart, rangeEnd int64) []NonceRange {
+	if numWorkers <= 0 || rangeEnd <= rangeStart {
+		return nil
+	}
+
+	// Unsigned arithmetic keeps spans wider than math.MaxInt64 exact
+	span := uint64(rangeEnd) - uint64(rangeStart)
+	size, remainder := span/uint64(numWorkers), span%uint64(numWorkers)
+
+	ranges := make([]NonceRange, numWorkers)
+	start := rangeStart
+	for i := range ranges {
+		n := size
+		if uint64(i) < remainder {
+			n++
+		}
+		end := int64(uint64(start) + n)
+		ranges[i] = NonceRange{Start: start, End: end}
+		start = end
+	}
+
+	return ranges
+}
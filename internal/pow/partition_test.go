@@ -0,0 +1,68 @@
+package pow_test
+
+import (
+	"math"
+	"testing"
+	"word-of-wisdom/internal/pow"
+)
+
+// TestPartitionNonceSpace ensures the ranges are contiguous, non-overlapping,
+// cover the whole space and are balanced.
+func TestPartitionNonceSpace(t *testing.T) {
+	tests := []struct {
+		name       string
+		workers    int
+		start, end int64
+	}{
+		{name: "even", workers: 4, start: 0, end: 1000},
+		{name: "remainder", workers: 3, start: 0, end: 10},
+		{name: "offset", workers: 7, start: 1 << 20, end: 1<<20 + 12345},
+		{name: "single worker", workers: 1, start: 5, end: 6},
+		{name: "more workers than nonces", workers: 8, start: 0, end: 3},
+		{name: "negative start", workers: 5, start: -100, end: 100},
+		{name: "full int64", workers: 16, start: math.MinInt64, end: math.MaxInt64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges := pow.PartitionNonceSpace(tt.workers, tt.start, tt.end)
+			if len(ranges) != tt.workers {
+				t.Fatalf("Expected %d ranges, got %d", tt.workers, len(ranges))
+			}
+			if ranges[0].Start != tt.start {
+				t.Fatalf("First range starts at %d, expected %d", ranges[0].Start, tt.start)
+			}
+			if last := ranges[len(ranges)-1]; last.End != tt.end {
+				t.Fatalf("Last range ends at %d, expected %d", last.End, tt.end)
+			}
+
+			minSize, maxSize := uint64(math.MaxUint64), uint64(0)
+			for i, r := range ranges {
+				if r.End < r.Start {
+					t.Fatalf("Range %d is inverted: %+v", i, r)
+				}
+				if i > 0 && r.Start != ranges[i-1].End {
+					t.Fatalf("Range %d starts at %d, previous ended at %d", i, r.Start, ranges[i-1].End)
+				}
+				size := uint64(r.End) - uint64(r.Start)
+				minSize, maxSize = min(minSize, size), max(maxSize, size)
+			}
+			if maxSize-minSize > 1 {
+				t.Fatalf("Range sizes vary from %d to %d", minSize, maxSize)
+			}
+		})
+	}
+}
+
+// TestPartitionNonceSpaceEmpty ensures invalid inputs yield no ranges.
+func TestPartitionNonceSpaceEmpty(t *testing.T) {
+	if ranges := pow.PartitionNonceSpace(0, 0, 100); ranges != nil {
+		t.Fatalf("Expected no ranges without workers, got %v", ranges)
+	}
+	if ranges := pow.PartitionNonceSpace(4, 10, 10); ranges != nil {
+		t.Fatalf("Expected no ranges for an empty space, got %v", ranges)
+	}
+	if ranges := pow.PartitionNonceSpace(4, 10, 5); ranges != nil {
+		t.Fatalf("Expected no ranges for an inverted space, got %v", ranges)
+	}
+}
@@ -0,0 +1,53 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultSelfTestAttempts is a budget solving difficulty 4 with a wide margin
+// in well under a second, see ExpectedAttempts
+const DefaultSelfTestAttempts = 1 << 22
+
+var ErrSelfTestFailed = errors.New("PoW self-test failed")
+
+// SelfTestResult describes a successful self-test
+type SelfTestResult struct {
+	Challenge string
+	Solution  string
+	Attempts  int
+	Duration  time.Duration
+}
+
+// SelfTest generates a challenge and solves it by trying the solutions "0",
+// "1", ... the way clients do, giving up after maxAttempts. It catches
+// misconfigurations like an impossible difficulty or broken hashing before
+// serving traffic. Only ValidateChallenge is used, so any PoW can be tested.
+// The duration estimates how long a client on the same hardware needs.
+func SelfTest(ctx context.Context, p PoW, maxAttempts int) (SelfTestResult, error) {
+	start := time.Now()
+	challenge := p.GenerateChallenge()
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		// Check for cancellation periodically without slowing down hashing
+		if attempt%4096 == 0 && ctx.Err() != nil {
+			return SelfTestResult{}, fmt.Errorf("%w: %w", ErrSelfTestFailed, ctx.Err())
+		}
+
+		solution := strconv.Itoa(attempt)
+		if p.ValidateChallenge(challenge, solution) {
+			return SelfTestResult{
+				Challenge: challenge,
+				Solution:  solution,
+				Attempts:  attempt + 1,
+				Duration:  time.Since(start),
+			}, nil
+		}
+	}
+
+	return SelfTestResult{}, fmt.Errorf("%w: no solution for %q at difficulty %d within %d attempts",
+		ErrSelfTestFailed, challenge, p.Difficulty(), maxAttempts)
+}
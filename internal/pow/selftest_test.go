@@ -0,0 +1,49 @@
+package pow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/pow"
+)
+
+// TestSelfTest ensures a sane difficulty passes the self-test.
+func TestSelfTest(t *testing.T) {
+	p := pow.NewSHA256PoW(2)
+
+	result, err := pow.SelfTest(context.Background(), p, pow.DefaultSelfTestAttempts)
+	if err != nil {
+		t.Fatalf("Self-test failed: %v", err)
+	}
+	if !p.ValidateChallenge(result.Challenge, result.Solution) {
+		t.Fatal("Self-test returned an invalid solution")
+	}
+	if result.Attempts < 1 || result.Duration <= 0 {
+		t.Fatalf("Expected attempts and duration to be reported, got %+v", result)
+	}
+}
+
+// TestSelfTestImpossibleDifficulty ensures an absurd difficulty fails within the budget.
+func TestSelfTestImpossibleDifficulty(t *testing.T) {
+	start := time.Now()
+	_, err := pow.SelfTest(context.Background(), pow.NewSHA256PoW(64), 10_000)
+
+	if !errors.Is(err, pow.ErrSelfTestFailed) {
+		t.Fatalf("Expected ErrSelfTestFailed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Self-test took %v despite the attempt budget", elapsed)
+	}
+}
+
+// TestSelfTestCanceled ensures the self-test stops when the context is done.
+func TestSelfTestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := pow.SelfTest(ctx, pow.NewSHA256PoW(64), pow.DefaultSelfTestAttempts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
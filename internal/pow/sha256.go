@@ -5,32 +5,66 @@ package pow
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
-	"math/rand"
 	"strings"
-	"time"
+	"sync/atomic"
+	"word-of-wisdom/pkg/protocol"
 )
 
 type SHA256PoW struct {
-	difficulty int
-	rng        *rand.Rand
+	difficulty atomic.Int64
+	nonces     *nonceSource
+	salt       string
+	legacy     bool
+	compat     int
+	hashers    *hasherPool
 }
 
-func NewSHA256PoW(difficulty int) PoW {
-	return &SHA256PoW{
-		difficulty: difficulty,
-		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+func NewSHA256PoW(difficulty int, opts ...Option) PoW {
+	o := newOptions(opts)
+	p := &SHA256PoW{
+		nonces: newNonceSource(o.reader),
+		salt:   o.salt,
+		legacy: o.legacyHashing,
+		compat: o.compat,
 	}
+	if o.hasherPool {
+		p.hashers = newHasherPool()
+	}
+	p.difficulty.Store(int64(difficulty))
+	return p
 }
 
 // GenerateChallenge creates a random challenge string.
 func (p *SHA256PoW) GenerateChallenge() string {
-	return fmt.Sprintf("%x", p.rng.Int63())
+	return p.GenerateChallengeAt(p.Difficulty())
 }
 
 // ValidateChallenge checks if the provided solution meets the required difficulty.
+// The difficulty embedded in the challenge is used, so challenges issued before
+// a difficulty change are validated at the difficulty they were issued with.
 func (p *SHA256PoW) ValidateChallenge(challenge, solution string) bool {
-	hash := sha256.Sum256([]byte(challenge + solution))
+	difficulty, ok := challengeDifficulty(challenge, p.salt, p.compat)
+	if !ok {
+		return false
+	}
+
+	input := protocol.SolutionHashInput(challenge, solution, p.legacy)
+	var hash [sha256.Size]byte
+	if p.hashers != nil {
+		hash = p.hashers.sum(input)
+	} else {
+		hash = sha256.Sum256(input)
+	}
 	hashStr := hex.EncodeToString(hash[:]) // TODO improve it with binary
-	return strings.HasPrefix(hashStr, strings.Repeat("0", p.difficulty))
+	return strings.HasPrefix(hashStr, strings.Repeat("0", difficulty))
+}
+
+// Difficulty returns the difficulty used for new challenges.
+func (p *SHA256PoW) Difficulty() int {
+	return int(p.difficulty.Load())
+}
+
+// SetDifficulty changes the difficulty of new challenges at runtime.
+func (p *SHA256PoW) SetDifficulty(difficulty int) {
+	p.difficulty.Store(int64(difficulty))
 }
@@ -1,13 +1,18 @@
 package pow_test
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
 )
 
 // TestGenerateChallenge ensures that the challenge is not empty and varies across calls.
@@ -27,11 +32,101 @@ func TestGenerateChallenge(t *testing.T) {
 	}
 }
 
+// TestGenerateChallengeConcurrentUnique ensures concurrent callers never get the same challenge.
+// Run with -race to catch data races in the random source.
+func TestGenerateChallengeConcurrentUnique(t *testing.T) {
+	const (
+		goroutines = 100
+		perWorker  = 1000
+	)
+
+	for name, p := range map[string]pow.PoW{
+		"crypto": pow.NewSHA256PoW(4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			results := make([][]string, goroutines)
+
+			var wg sync.WaitGroup
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i] = make([]string, 0, perWorker)
+					for j := 0; j < perWorker; j++ {
+						results[i] = append(results[i], p.GenerateChallenge())
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Nonces are 64 random bits: among 100 000 challenges a collision has a
+			// probability of about 3e-10, so any duplicate points to a broken source
+			seen := make(map[string]struct{}, goroutines*perWorker)
+			for _, challenges := range results {
+				for _, challenge := range challenges {
+					if _, ok := seen[challenge]; ok {
+						t.Fatalf("Duplicate challenge %q", challenge)
+					}
+					seen[challenge] = struct{}{}
+				}
+			}
+			if len(seen) != goroutines*perWorker {
+				t.Fatalf("Expected %d challenges, got %d", goroutines*perWorker, len(seen))
+			}
+		})
+	}
+}
+
+// TestSolutionSeparator ensures the separator resolves the ambiguity of plain concatenation
+// and that the client solver and the server agree in both hashing modes.
+func TestSolutionSeparator(t *testing.T) {
+	if !bytes.Equal(protocol.SolutionHashInput("ab", "c", true), protocol.SolutionHashInput("a", "bc", true)) {
+		t.Fatal("Legacy hashing should concatenate challenge and solution")
+	}
+	if bytes.Equal(protocol.SolutionHashInput("ab", "c", false), protocol.SolutionHashInput("a", "bc", false)) {
+		t.Fatal("Different challenge and solution pairs should hash differently")
+	}
+
+	difficulty := 3
+	p := pow.NewSHA256PoW(difficulty)
+	challenge := p.GenerateChallenge()
+
+	solution, err := wowclient.Solve(context.Background(), challenge, difficulty)
+	if err != nil {
+		t.Fatalf("Failed to solve: %v", err)
+	}
+	if !p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Client solution was rejected")
+	}
+
+	legacy := pow.NewSHA256PoW(difficulty, pow.WithLegacyHashing(true))
+	legacySolution := solveLegacy(challenge, difficulty)
+	if !legacy.ValidateChallenge(challenge, legacySolution) {
+		t.Fatal("Legacy solution was rejected in legacy mode")
+	}
+
+	hash := sha256.Sum256(protocol.SolutionHashInput(challenge, legacySolution, false))
+	if !strings.HasPrefix(hex.EncodeToString(hash[:]), "000") && p.ValidateChallenge(challenge, legacySolution) {
+		t.Fatal("Legacy solution was accepted with the separator")
+	}
+}
+
+// solveLegacy finds a solution hashing the challenge and solution without separator.
+func solveLegacy(challenge string, difficulty int) string {
+	prefix := strings.Repeat("0", difficulty)
+	for nonce := 0; ; nonce++ {
+		hash := sha256.Sum256(protocol.SolutionHashInput(challenge, fmt.Sprintf("%d", nonce), true))
+		if strings.HasPrefix(hex.EncodeToString(hash[:]), prefix) {
+			return fmt.Sprintf("%d", nonce)
+		}
+	}
+}
+
 // solvePoW finds a valid solution for a given challenge and difficulty.
 func solvePoW(challenge string, difficulty int) string {
 	prefix := strings.Repeat("0", difficulty)
 	for nonce := 0; ; nonce++ {
-		hash := sha256.Sum256([]byte(challenge + fmt.Sprintf("%d", nonce)))
+		hash := sha256.Sum256(protocol.SolutionHashInput(challenge, fmt.Sprintf("%d", nonce), false))
 		hashStr := hex.EncodeToString(hash[:])
 		if strings.HasPrefix(hashStr, prefix) {
 			return fmt.Sprintf("%d", nonce)
@@ -129,3 +224,110 @@ func TestPerformance(t *testing.T) {
 		t.Fatalf("PoW validation took too long: %s", elapsed)
 	}
 }
+
+// TestDifficultyChange ensures challenges keep the difficulty they were issued with.
+func TestDifficultyChange(t *testing.T) {
+	p := pow.NewSHA256PoW(2)
+	oldChallenge := p.GenerateChallenge()
+	oldSolution := solvePoW(oldChallenge, 2)
+
+	p.SetDifficulty(4)
+	if p.Difficulty() != 4 {
+		t.Fatalf("Expected difficulty 4, got %d", p.Difficulty())
+	}
+
+	// The old challenge is still validated at difficulty 2
+	if !p.ValidateChallenge(oldChallenge, oldSolution) {
+		t.Fatal("Solution for a challenge issued before the change was rejected")
+	}
+
+	// New challenges are issued and validated at difficulty 4
+	newChallenge := p.GenerateChallenge()
+	if !p.ValidateChallenge(newChallenge, solvePoW(newChallenge, 4)) {
+		t.Fatal("Valid PoW solution at the new difficulty was rejected")
+	}
+
+	weakSolution := solvePoW(newChallenge, 2)
+	hash := sha256.Sum256(protocol.SolutionHashInput(newChallenge, weakSolution, false))
+	if !strings.HasPrefix(hex.EncodeToString(hash[:]), "0000") && p.ValidateChallenge(newChallenge, weakSolution) {
+		t.Fatal("Solution at the old difficulty was accepted for a new challenge")
+	}
+}
+
+// TestCompatDifficulty ensures challenges without difficulty are only accepted
+// with a compatibility difficulty, and validated at it.
+func TestCompatDifficulty(t *testing.T) {
+	p := pow.NewSHA256PoW(4, pow.WithCompatDifficulty(2), pow.WithSalt("deployment")).(*pow.SHA256PoW)
+
+	challenge := p.GenerateCompatChallenge()
+	if strings.Contains(challenge, protocol.ChallengeSeparator) || !strings.HasPrefix(challenge, "deployment") {
+		t.Fatalf("Compat challenge %q should be a salted nonce without difficulty", challenge)
+	}
+
+	solution := solvePoW(challenge, 2)
+	if !p.ValidateChallenge(challenge, solution) {
+		t.Fatal("Compat solution was rejected")
+	}
+
+	weakSolution := solvePoW(challenge, 1)
+	hash := sha256.Sum256(protocol.SolutionHashInput(challenge, weakSolution, false))
+	if !strings.HasPrefix(hex.EncodeToString(hash[:]), "00") && p.ValidateChallenge(challenge, weakSolution) {
+		t.Fatal("Solution below the compat difficulty was accepted")
+	}
+
+	if pow.NewSHA256PoW(4, pow.WithSalt("deployment")).ValidateChallenge(challenge, solution) {
+		t.Fatal("Compat challenge was accepted without a compat difficulty")
+	}
+
+	advertised := p.GenerateChallenge()
+	if !p.ValidateChallenge(advertised, solvePoW(advertised, 4)) {
+		t.Fatal("Advertised challenge was rejected with a compat difficulty")
+	}
+}
+
+// TestCompatChallengeAt ensures compat challenges are validated at a raised
+// difficulty, and at the compat difficulty when it is not higher.
+func TestCompatChallengeAt(t *testing.T) {
+	p := pow.NewSHA256PoW(1, pow.WithCompatDifficulty(1), pow.WithSalt("deployment")).(*pow.SHA256PoW)
+
+	if challenge := p.GenerateCompatChallengeAt(1); strings.Contains(challenge, "+") {
+		t.Fatalf("Compat challenge %q at the compat difficulty should be a plain salted nonce", challenge)
+	}
+
+	challenge := p.GenerateCompatChallengeAt(3)
+	if strings.Contains(challenge, protocol.ChallengeSeparator) || !strings.HasSuffix(challenge, "+3") {
+		t.Fatalf("Compat challenge %q should carry the raised difficulty without the challenge separator", challenge)
+	}
+	if !p.ValidateChallenge(challenge, solvePoW(challenge, 3)) {
+		t.Fatal("Solution at the raised difficulty was rejected")
+	}
+
+	weakSolution := solvePoW(challenge, 1)
+	hash := sha256.Sum256(protocol.SolutionHashInput(challenge, weakSolution, false))
+	if !strings.HasPrefix(hex.EncodeToString(hash[:]), "000") && p.ValidateChallenge(challenge, weakSolution) {
+		t.Fatal("Solution below the raised difficulty was accepted")
+	}
+
+	if p.ValidateChallenge("deployment"+"ab+1", "0") || p.ValidateChallenge("deployment"+"ab+x", "0") {
+		t.Fatal("Compat challenge with an invalid raised difficulty was accepted")
+	}
+}
+
+// TestChallengeSalt ensures a solution for one deployment salt fails under another.
+func TestChallengeSalt(t *testing.T) {
+	powA := pow.NewSHA256PoW(2, pow.WithSalt("deployment-a"))
+	powB := pow.NewSHA256PoW(2, pow.WithSalt("deployment-b"))
+
+	challenge := powA.GenerateChallenge()
+	if !strings.Contains(challenge, "deployment-a") {
+		t.Fatalf("Challenge %q does not carry the salt", challenge)
+	}
+
+	solution := solvePoW(challenge, 2)
+	if !powA.ValidateChallenge(challenge, solution) {
+		t.Fatal("Valid solution was rejected by the issuing deployment")
+	}
+	if powB.ValidateChallenge(challenge, solution) {
+		t.Fatal("Solution for another deployment's salt was accepted")
+	}
+}
@@ -0,0 +1,80 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Difficulties tried by TuneDifficulty and the solves measured at each
+const (
+	TuneMinDifficulty = 1
+	TuneMaxDifficulty = 10
+	TuneTrials        = 20
+)
+
+// SolveTimer measures how long a single solve at the given difficulty takes
+type SolveTimer func(ctx context.Context, difficulty int) (time.Duration, error)
+
+// TuneDifficulty recommends the difficulty of the given algorithm whose median
+// solve time on this machine is closest to targetMedianMs. Every level is 16
+// times harder than the previous one, so levels are only measured until their
+// median exceeds the target.
+func TuneDifficulty(ctx context.Context, targetMedianMs int, algo string) (int, error) {
+	p, err := New(algo, TuneMinDifficulty)
+	if err != nil {
+		return 0, err
+	}
+
+	return TuneDifficultyWith(ctx, targetMedianMs, func(ctx context.Context, difficulty int) (time.Duration, error) {
+		p.SetDifficulty(difficulty)
+		result, err := SelfTest(ctx, p, math.MaxInt)
+		return result.Duration, err
+	})
+}
+
+// TuneDifficultyWith is TuneDifficulty measuring solves with timer
+func TuneDifficultyWith(ctx context.Context, targetMedianMs int, timer SolveTimer) (int, error) {
+	if targetMedianMs <= 0 {
+		return 0, fmt.Errorf("target median must be positive, got %dms", targetMedianMs)
+	}
+	target := time.Duration(targetMedianMs) * time.Millisecond
+
+	best, bestDiff := 0, time.Duration(math.MaxInt64)
+	for difficulty := TuneMinDifficulty; difficulty <= TuneMaxDifficulty; difficulty++ {
+		median, err := medianSolveTime(ctx, difficulty, timer)
+		if err != nil {
+			if best != 0 && errors.Is(err, ctx.Err()) {
+				return best, nil
+			}
+			return 0, fmt.Errorf("failed to measure difficulty %d: %w", difficulty, err)
+		}
+
+		if diff := (median - target).Abs(); diff < bestDiff {
+			best, bestDiff = difficulty, diff
+		}
+		if median >= target {
+			break
+		}
+	}
+
+	return best, nil
+}
+
+// medianSolveTime measures TuneTrials solves and returns the median
+func medianSolveTime(ctx context.Context, difficulty int, timer SolveTimer) (time.Duration, error) {
+	durations := make([]time.Duration, 0, TuneTrials)
+	for range TuneTrials {
+		d, err := timer(ctx, difficulty)
+		if err != nil {
+			return 0, err
+		}
+		durations = append(durations, d)
+	}
+
+	slices.Sort(durations)
+	return (durations[(TuneTrials-1)/2] + durations[TuneTrials/2]) / 2, nil
+}
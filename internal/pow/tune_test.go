@@ -0,0 +1,90 @@
+package pow_test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/pow"
+)
+
+// fakeTimer reports solve times growing 16 times per difficulty level, starting at base for difficulty 1.
+func fakeTimer(base time.Duration, measured map[int]int) pow.SolveTimer {
+	return func(_ context.Context, difficulty int) (time.Duration, error) {
+		measured[difficulty]++
+		return base << (4 * (difficulty - 1)), nil
+	}
+}
+
+// TestTuneDifficulty ensures the difficulty with the closest median is recommended.
+func TestTuneDifficulty(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		targetMs int
+		want     int
+	}{
+		{name: "below the easiest", targetMs: 1, want: 1},
+		{name: "exact", targetMs: 256, want: 3},
+		{name: "closer to the lower", targetMs: 1000, want: 3},
+		{name: "closer to the higher", targetMs: 3000, want: 4},
+		{name: "beyond the hardest", base: time.Nanosecond, targetMs: math.MaxInt32, want: pow.TuneMaxDifficulty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			measured := map[int]int{}
+			got, err := pow.TuneDifficultyWith(context.Background(), tt.targetMs, fakeTimer(cmp.Or(tt.base, time.Millisecond), measured))
+			if err != nil {
+				t.Fatalf("Tuning failed: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Expected difficulty %d, got %d", tt.want, got)
+			}
+			if measured[got] != pow.TuneTrials {
+				t.Fatalf("Expected %d trials at difficulty %d, got %d", pow.TuneTrials, got, measured[got])
+			}
+		})
+	}
+}
+
+// TestTuneDifficultyStopsAboveTarget ensures harder levels are not measured once the target is exceeded.
+func TestTuneDifficultyStopsAboveTarget(t *testing.T) {
+	measured := map[int]int{}
+	if _, err := pow.TuneDifficultyWith(context.Background(), 100, fakeTimer(time.Millisecond, measured)); err != nil {
+		t.Fatalf("Tuning failed: %v", err)
+	}
+
+	if len(measured) != 3 {
+		t.Fatalf("Expected difficulties 1 to 3 to be measured, got %v", measured)
+	}
+}
+
+// TestTuneDifficultyErrors ensures invalid targets and failing measurements are reported.
+func TestTuneDifficultyErrors(t *testing.T) {
+	if _, err := pow.TuneDifficultyWith(context.Background(), 0, fakeTimer(time.Millisecond, map[int]int{})); err == nil {
+		t.Fatal("Expected an error for a zero target")
+	}
+
+	failing := func(context.Context, int) (time.Duration, error) { return 0, errors.New("broken") }
+	if _, err := pow.TuneDifficultyWith(context.Background(), 100, failing); err == nil {
+		t.Fatal("Expected the measurement error")
+	}
+
+	if _, err := pow.TuneDifficulty(context.Background(), 100, "md5"); err == nil {
+		t.Fatal("Expected an error for an unknown algorithm")
+	}
+}
+
+// TestTuneDifficultyReal ensures real measurements recommend a usable difficulty.
+func TestTuneDifficultyReal(t *testing.T) {
+	difficulty, err := pow.TuneDifficulty(context.Background(), 1, pow.AlgorithmSHA256)
+	if err != nil {
+		t.Fatalf("Tuning failed: %v", err)
+	}
+	if difficulty < pow.TuneMinDifficulty || difficulty > 3 {
+		t.Fatalf("Expected an easy difficulty for a 1ms target, got %d", difficulty)
+	}
+}
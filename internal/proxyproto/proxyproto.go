@@ -0,0 +1,156 @@
+// Package proxyproto parses the PROXY protocol header load balancers send
+// ahead of the client data to pass the original client address on.
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTextHeaderLength is the longest v1 header allowed by the specification, CRLF included
+const maxTextHeaderLength = 107
+
+// ErrInvalidHeader is returned when the connection does not start with a valid PROXY header
+var ErrInvalidHeader = errors.New("invalid PROXY header")
+
+// ParseTextHeader reads a PROXY protocol v1 header, e.g.
+// "PROXY TCP4 1.2.3.4 5.6.7.8 1234 9000\r\n", and returns the source address
+// as a *net.TCPAddr. For "PROXY UNKNOWN", sent e.g. for health checks of the
+// load balancer itself, it returns a nil address and no error, so the address
+// of the connection is kept.
+func ParseTextHeader(r *bufio.Reader) (net.Addr, error) {
+	line, err := readHeaderLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(line, " ")
+	if fields[0] != "PROXY" || len(fields) < 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	var ipv6 bool
+	switch fields[1] {
+	case "TCP4":
+	case "TCP6":
+		ipv6 = true
+	default:
+		return nil, fmt.Errorf("%w: unknown protocol %q", ErrInvalidHeader, fields[1])
+	}
+
+	srcIP, err := parseIP(fields[2], ipv6)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseIP(fields[3], ipv6); err != nil {
+		return nil, err
+	}
+	srcPort, err := parsePort(fields[4])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parsePort(fields[5]); err != nil {
+		return nil, err
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, nil
+}
+
+// readHeaderLine reads the header up to the CRLF without consuming any of the client data
+func readHeaderLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for len(line) < maxTextHeaderLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("failed to read PROXY header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			if len(line) < 2 || line[len(line)-2] != '\r' {
+				return "", fmt.Errorf("%w: missing CRLF", ErrInvalidHeader)
+			}
+			return string(line[:len(line)-2]), nil
+		}
+	}
+	return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidHeader, maxTextHeaderLength)
+}
+
+// parseIP parses an address of the family announced by the header
+func parseIP(s string, ipv6 bool) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || strings.Contains(s, ":") != ipv6 {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidHeader, s)
+	}
+	return ip, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid port %q", ErrInvalidHeader, s)
+	}
+	return int(port), nil
+}
+
+// Conn reads the PROXY header on first use and reports its source address as
+// the remote address. Reading the header lazily keeps a slow client from
+// blocking the goroutine accepting connections.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	source net.Addr
+	err    error
+}
+
+// NewConn wraps a connection starting with a PROXY header, which must arrive within timeout
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// Header reads the PROXY header, once, and returns the error of reading it
+func (c *Conn) Header() error {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.source, c.err = ParseTextHeader(c.reader)
+	})
+	return c.err
+}
+
+// RemoteAddr returns the source address of the header, or the address of the
+// connection for UNKNOWN and invalid headers
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.Header() == nil && c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// Read reads the client data following the header
+func (c *Conn) Read(p []byte) (int, error) {
+	if err := c.Header(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
@@ -0,0 +1,110 @@
+package proxyproto_test
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/proxyproto"
+)
+
+// TestParseTextHeader ensures the source address is taken from the header and the client data is left unread
+func TestParseTextHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		source net.Addr
+	}{
+		{
+			name:   "ipv4",
+			header: "PROXY TCP4 1.2.3.4 5.6.7.8 1234 9000\r\n",
+			source: &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1234},
+		},
+		{
+			name:   "ipv6",
+			header: "PROXY TCP6 2001:db8::1 2001:db8::2 40000 9000\r\n",
+			source: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000},
+		},
+		{
+			name:   "unknown",
+			header: "PROXY UNKNOWN ffff:f...f:ffff ffff:f...f:ffff 65535 65535\r\n",
+		},
+		{
+			name:   "unknown without addresses",
+			header: "PROXY UNKNOWN\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.header + "solution\n"))
+
+			source, err := proxyproto.ParseTextHeader(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.source, source)
+
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "solution\n", string(rest))
+		})
+	}
+}
+
+// TestParseTextHeaderInvalid ensures malformed headers are rejected with ErrInvalidHeader
+func TestParseTextHeaderInvalid(t *testing.T) {
+	headers := []string{
+		"solution\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1234 9000\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1234\r\n",
+		"PROXY UDP4 1.2.3.4 5.6.7.8 1234 9000\r\n",
+		"PROXY TCP4 2001:db8::1 5.6.7.8 1234 9000\r\n",
+		"PROXY TCP6 1.2.3.4 2001:db8::2 1234 9000\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 70000 9000\r\n",
+		"PROXY TCP4 1.2.3.4 5.6.7.8 1234 9000" + strings.Repeat(" ", 100) + "\r\n",
+	}
+
+	for _, header := range headers {
+		_, err := proxyproto.ParseTextHeader(bufio.NewReader(strings.NewReader(header)))
+		assert.ErrorIs(t, err, proxyproto.ErrInvalidHeader, "Header %q", header)
+	}
+
+	_, err := proxyproto.ParseTextHeader(bufio.NewReader(strings.NewReader("PROXY TCP4")))
+	assert.ErrorIs(t, err, io.EOF, "A truncated header should fail with the read error")
+}
+
+// TestConn ensures the connection reports the header source and reads the data after it
+func TestConn(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	conn := proxyproto.NewConn(serverConn, time.Second)
+	defer conn.Close()
+
+	go func() {
+		_, _ = io.WriteString(clientConn, "PROXY TCP4 1.2.3.4 5.6.7.8 1234 9000\r\nsolution\n")
+	}()
+
+	assert.Equal(t, &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1234}, conn.RemoteAddr())
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "solution\n", line)
+}
+
+// TestConnHeaderTimeout ensures a client not sending the header does not block forever
+func TestConnHeaderTimeout(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	conn := proxyproto.NewConn(serverConn, 50*time.Millisecond)
+	defer conn.Close()
+
+	err := conn.Header()
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+	assert.Equal(t, serverConn.RemoteAddr(), conn.RemoteAddr(), "The connection address should be kept without a header")
+}
@@ -0,0 +1,63 @@
+package quotes
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// ErrNotEnumerable is returned by WriteCsv for providers that cannot list their quotes
+var ErrNotEnumerable = errors.New("quote provider is not enumerable")
+
+// Quote is a served quote together with the collection it belongs to
+type Quote struct {
+	protocol.QuoteMessage
+	// Category names the collection, empty for the primary one
+	Category string
+}
+
+// Enumerable is implemented by providers able to list all of their quotes
+type Enumerable interface {
+	Quotes() []Quote
+}
+
+// csvHeader is the first record written by WriteCsv
+var csvHeader = []string{"text", "author", "category"}
+
+// WriteCsv writes the quotes of the provider as RFC 4180 CSV with the header
+// text,author,category. Fields with commas, quotes or line breaks are quoted.
+func WriteCsv(w io.Writer, provider QuoteProvider) error {
+	enumerable, ok := provider.(Enumerable)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotEnumerable, provider)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true // RFC 4180 ends records with CRLF
+
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	for _, quote := range enumerable.Quotes() {
+		if err := writer.Write([]string{quote.Text, quote.Author, quote.Category}); err != nil {
+			return fmt.Errorf("failed to write quote: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// uncategorized lists messages as quotes of the primary collection
+func uncategorized(messages []protocol.QuoteMessage) []Quote {
+	quotes := make([]Quote, 0, len(messages))
+	for _, message := range messages {
+		quotes = append(quotes, Quote{QuoteMessage: message})
+	}
+	return quotes
+}
@@ -0,0 +1,88 @@
+package quotes_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestWriteCsv ensures quotes with commas, quotes and line breaks are written as valid RFC 4180 CSV.
+func TestWriteCsv(t *testing.T) {
+	provider := quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{
+		{Text: "Simple", Author: "Anonymous"},
+		{Text: "Veni, vidi, vici", Author: "Julius Caesar"},
+		{Text: "First line\nsecond line"},
+		{Text: `He said "hello"`, Author: "Someone, Jr."},
+	})
+
+	var buf bytes.Buffer
+	if err := quotes.WriteCsv(&buf, provider); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	raw := buf.String()
+	if !strings.HasPrefix(raw, "text,author,category\r\n") {
+		t.Fatalf("Expected the header ending with CRLF, got %q", raw)
+	}
+	for _, field := range []string{`"Veni, vidi, vici"`, "\"First line\r\nsecond line\"", `"He said ""hello"""`, `"Someone, Jr."`} {
+		if !strings.Contains(raw, field) {
+			t.Errorf("Expected quoted field %q in %q", field, raw)
+		}
+	}
+
+	// The strict reader rejects bare quotes and checks the field count of every record
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"text", "author", "category"},
+		{"Simple", "Anonymous", ""},
+		{"Veni, vidi, vici", "Julius Caesar", ""},
+		{"First line\nsecond line", "", ""},
+		{`He said "hello"`, "Someone, Jr.", ""},
+	}
+	if !slices.EqualFunc(records, expected, slices.Equal) {
+		t.Fatalf("Expected records %q, got %q", expected, records)
+	}
+}
+
+// TestWriteCsvRegistry ensures collections are exported with their name as category.
+func TestWriteCsvRegistry(t *testing.T) {
+	registry := quotes.NewRegistry(quotes.NewRandomQuoteProvider([]string{"primary"}))
+	registry.Register("stoicism", quotes.NewRandomQuoteProvider([]string{"stoic"}))
+
+	var buf bytes.Buffer
+	if err := quotes.WriteCsv(&buf, registry); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	expected := "text,author,category\r\nprimary,,\r\nstoic,,stoicism\r\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+// opaqueProvider serves quotes without being able to list them
+type opaqueProvider struct{}
+
+func (opaqueProvider) GetQuote() protocol.QuoteMessage {
+	return protocol.QuoteMessage{Text: "opaque"}
+}
+
+// TestWriteCsvNotEnumerable ensures providers unable to list their quotes are reported.
+func TestWriteCsvNotEnumerable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := quotes.WriteCsv(&buf, opaqueProvider{}); !errors.Is(err, quotes.ErrNotEnumerable) {
+		t.Fatalf("Expected ErrNotEnumerable, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no output, got %q", buf.String())
+	}
+}
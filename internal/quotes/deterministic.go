@@ -0,0 +1,39 @@
+package quotes
+
+import (
+	"hash/fnv"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// DeterministicProvider maps every key to a fixed quote by hashing it, so the
+// same key always yields the same quote, e.g. for reproducible tests
+type DeterministicProvider struct {
+	quotes []protocol.QuoteMessage
+}
+
+// NewDeterministicProvider creates a provider mapping keys onto the quotes,
+// skipping those failing ValidateQuote
+func NewDeterministicProvider(quotes []protocol.QuoteMessage) *DeterministicProvider {
+	return &DeterministicProvider{quotes: validQuotes(quotes)}
+}
+
+// GetQuote returns the quote of the empty key
+func (p *DeterministicProvider) GetQuote() protocol.QuoteMessage {
+	return p.GetQuoteFor("")
+}
+
+// GetQuoteFor returns the quote at the FNV-1a hash of key modulo the quote count
+func (p *DeterministicProvider) GetQuoteFor(key string) protocol.QuoteMessage {
+	if len(p.quotes) == 0 {
+		return protocol.QuoteMessage{Text: Stub}
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return p.quotes[h.Sum64()%uint64(len(p.quotes))]
+}
+
+// Quotes lists the quotes keys are mapped onto
+func (p *DeterministicProvider) Quotes() []Quote {
+	return uncategorized(p.quotes)
+}
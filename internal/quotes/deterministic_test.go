@@ -0,0 +1,33 @@
+package quotes_test
+
+import (
+	"fmt"
+	"testing"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestDeterministicProvider ensures the same key always maps to the same quote.
+func TestDeterministicProvider(t *testing.T) {
+	provider := quotes.NewDeterministicProvider([]protocol.QuoteMessage{{Text: "A"}, {Text: "B"}, {Text: "C"}})
+
+	first := provider.GetQuoteFor("4:abc:1234")
+	for i := 0; i < 10; i++ {
+		if quote := provider.GetQuoteFor("4:abc:1234"); quote != first {
+			t.Fatalf("Expected %q for the same key, got %q", first.Text, quote.Text)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		seen[provider.GetQuoteFor(fmt.Sprintf("4:abc:%d", i)).Text] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("Different keys should map to different quotes, got %v", seen)
+	}
+
+	empty := quotes.NewDeterministicProvider(nil)
+	if text := empty.GetQuoteFor("key").Text; text != quotes.Stub {
+		t.Fatalf("Expected the stub for an empty provider, got %q", text)
+	}
+}
@@ -0,0 +1,63 @@
+package quotes
+
+import (
+	"context"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// FallibleProvider is a quote source that may fail, e.g. a database
+type FallibleProvider interface {
+	Quote(ctx context.Context) (protocol.QuoteMessage, error)
+}
+
+// FallbackProvider serves quotes of a fallible primary source, falling back
+// to another provider whenever the primary fails
+type FallbackProvider struct {
+	primary  FallibleProvider
+	fallback QuoteProvider
+	onError  func(error)
+}
+
+// NewFallbackProvider creates a provider falling back when primary fails.
+// onError, if not nil, is called with every error of the primary source.
+func NewFallbackProvider(primary FallibleProvider, fallback QuoteProvider, onError func(error)) *FallbackProvider {
+	return &FallbackProvider{
+		primary:  primary,
+		fallback: fallback,
+		onError:  onError,
+	}
+}
+
+// GetQuote returns a quote from the primary source or, on error, from the fallback
+func (p *FallbackProvider) GetQuote() protocol.QuoteMessage {
+	quote, _ := p.Quote(context.Background())
+	return quote
+}
+
+// Quote returns a quote from the primary source queried within ctx or, on
+// error, from the fallback. It never fails.
+func (p *FallbackProvider) Quote(ctx context.Context) (protocol.QuoteMessage, error) {
+	quote, err := p.primary.Quote(ctx)
+	if err == nil {
+		return quote, nil
+	}
+
+	if p.onError != nil {
+		p.onError(err)
+	}
+	return p.fallback.GetQuote(), nil
+}
+
+// Quotes lists the quotes of the primary source, or of the fallback when
+// the primary source is not enumerable or lists no quotes
+func (p *FallbackProvider) Quotes() []Quote {
+	if primary, ok := p.primary.(Enumerable); ok {
+		if quotes := primary.Quotes(); len(quotes) > 0 {
+			return quotes
+		}
+	}
+	if fallback, ok := p.fallback.(Enumerable); ok {
+		return fallback.Quotes()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package quotes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// CommentPrefix starts lines of a quote pack that are not quotes
+const CommentPrefix = "#"
+
+// LoadFile reads a quote pack with one quote per line, in the wire format
+// "text —— author". Blank and comment lines are skipped. Quotes starting with
+// a protocol prefix fail the whole pack with ErrReservedPrefix.
+func LoadFile(path string) ([]protocol.QuoteMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quotes file: %w", err)
+	}
+	defer f.Close()
+
+	quotes, err := parseQuotes(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotes file %s: %w", path, err)
+	}
+	return quotes, nil
+}
+
+// NewFileQuoteProvider creates a RandomQuoteProvider from a quote pack, see LoadFile
+func NewFileQuoteProvider(path string) (QuoteProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quotes file: %w", err)
+	}
+	defer f.Close()
+
+	provider, err := NewReaderProvider(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotes file %s: %w", path, err)
+	}
+	return provider, nil
+}
+
+// NewReaderProvider creates a RandomQuoteProvider from the quotes read from r,
+// one per line as in LoadFile, e.g. to load quotes in tests without files
+func NewReaderProvider(r io.Reader) (QuoteProvider, error) {
+	quotes, err := parseQuotes(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewAttributedQuoteProvider(quotes), nil
+}
+
+// parseQuotes reads one quote per line, skipping blank and comment lines
+func parseQuotes(r io.Reader) ([]protocol.QuoteMessage, error) {
+	var quotes []protocol.QuoteMessage
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, CommentPrefix) {
+			continue
+		}
+
+		quote := protocol.ParseQuote(line)
+		if err := ValidateQuote(quote); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, scanner.Err()
+}
@@ -0,0 +1,61 @@
+package quotes_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestReaderProvider ensures quotes are trimmed and blank and comment lines are skipped
+func TestReaderProvider(t *testing.T) {
+	provider, err := quotes.NewReaderProvider(strings.NewReader("# pack of one\n\n   Only quote —— Author  \n\t\n"))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, protocol.QuoteMessage{Text: "Only quote", Author: "Author"}, provider.GetQuote())
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+// TestReaderProviderError ensures read errors are returned
+func TestReaderProviderError(t *testing.T) {
+	_, err := quotes.NewReaderProvider(failingReader{})
+	assert.Error(t, err)
+}
+
+// TestFileQuoteProvider ensures quotes are loaded from a file
+func TestFileQuoteProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("From file\n"), 0o600))
+
+	provider, err := quotes.NewFileQuoteProvider(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "From file", provider.GetQuote().Text)
+
+	_, err = quotes.NewFileQuoteProvider(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestReaderProviderReservedPrefix ensures quotes that could be mistaken for control messages are rejected
+func TestReaderProviderReservedPrefix(t *testing.T) {
+	for _, prefix := range []string{protocol.PrefixError, protocol.PrefixChallenge} {
+		_, err := quotes.NewReaderProvider(strings.NewReader("Fine quote\n" + prefix + "not a quote —— Author\n"))
+		assert.ErrorIs(t, err, quotes.ErrReservedPrefix, prefix)
+		assert.ErrorContains(t, err, "line 2")
+	}
+
+	// The prefix is only reserved at the start of the quote
+	provider, err := quotes.NewReaderProvider(strings.NewReader("Mind the " + protocol.PrefixError + " prefix\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Mind the "+protocol.PrefixError+" prefix", provider.GetQuote().Text)
+}
@@ -2,8 +2,12 @@
 
 package quotes
 
+import (
+	"word-of-wisdom/pkg/protocol"
+)
+
 // QuoteProvider ...
 type QuoteProvider interface {
 	// GetQuote returns a random quote from the predefined list
-	GetQuote() string
+	GetQuote() protocol.QuoteMessage
 }
@@ -5,27 +5,87 @@ package quotes
 import (
 	"math/rand"
 	"time"
+	"word-of-wisdom/pkg/protocol"
 )
 
 const Stub = "Angry people are not always wise."
 
 type RandomQuoteProvider struct {
-	quotes []string
+	quotes []protocol.QuoteMessage
 	rng    *rand.Rand
 }
 
-func NewRandomQuoteProvider(quotes []string) QuoteProvider {
+// Option configures the random source, and for time-dependent providers the
+// clock, of a quote provider
+type Option func(*options)
+
+type options struct {
+	seed   int64
+	seeded bool
+	now    func() time.Time
+}
+
+// WithSeed seeds the random source of the provider, so it serves the same
+// sequence of quotes on every run, e.g. for reproducible tests. By default
+// it is seeded from the current time.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+		o.seeded = true
+	}
+}
+
+// WithClock replaces time.Now, e.g. to simulate the passage of time in tests.
+// Only time-dependent providers such as RecencyWeightedProvider use it.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// newOptions applies opts over the defaults
+func newOptions(opts []Option) options {
+	o := options{seed: time.Now().UnixNano(), now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// newRand creates the random source configured by opts
+func newRand(opts []Option) *rand.Rand {
+	return rand.New(rand.NewSource(newOptions(opts).seed))
+}
+
+// NewRandomQuoteProvider creates a provider of quotes without authors
+func NewRandomQuoteProvider(quotes []string, opts ...Option) QuoteProvider {
+	messages := make([]protocol.QuoteMessage, 0, len(quotes))
+	for _, q := range quotes {
+		messages = append(messages, protocol.QuoteMessage{Text: q})
+	}
+
+	return NewAttributedQuoteProvider(messages, opts...)
+}
+
+// NewAttributedQuoteProvider creates a provider of quotes with their authors,
+// skipping those failing ValidateQuote
+func NewAttributedQuoteProvider(quotes []protocol.QuoteMessage, opts ...Option) QuoteProvider {
 	return &RandomQuoteProvider{
-		quotes: quotes,
-		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		quotes: validQuotes(quotes),
+		rng:    newRand(opts),
 	}
 }
 
 // GetQuote returns a random quote from the predefined list
-func (q *RandomQuoteProvider) GetQuote() string {
+func (q *RandomQuoteProvider) GetQuote() protocol.QuoteMessage {
 	if len(q.quotes) == 0 {
-		return Stub
+		return protocol.QuoteMessage{Text: Stub}
 	}
 
 	return q.quotes[q.rng.Intn(len(q.quotes))]
 }
+
+// Quotes lists the predefined quotes
+func (q *RandomQuoteProvider) Quotes() []Quote {
+	return uncategorized(q.quotes)
+}
@@ -2,7 +2,9 @@ package quotes_test
 
 import (
 	"testing"
+	"time"
 	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
 )
 
 // TestRandomQuoteProvider ensures GetQuote returns a valid quote from the predefined list.
@@ -25,8 +27,8 @@ func TestRandomQuoteProvider(t *testing.T) {
 	// Check multiple calls return a valid quote
 	for i := 0; i < 10; i++ {
 		quote := provider.GetQuote()
-		if !quotesSet[quote] {
-			t.Errorf("Unexpected quote: %s", quote)
+		if !quotesSet[quote.Text] {
+			t.Errorf("Unexpected quote: %s", quote.Text)
 		}
 	}
 }
@@ -36,7 +38,44 @@ func TestEmptyQuoteProvider(t *testing.T) {
 	provider := quotes.NewRandomQuoteProvider([]string{})
 
 	quote := provider.GetQuote()
-	if quote != quotes.Stub {
-		t.Errorf("Expected empty quote, got: %s", quote)
+	if quote.Text != quotes.Stub {
+		t.Errorf("Expected empty quote, got: %s", quote.Text)
+	}
+}
+
+// TestAttributedQuoteProvider ensures quotes keep their authors.
+func TestAttributedQuoteProvider(t *testing.T) {
+	q := protocol.QuoteMessage{Text: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"}
+
+	provider := quotes.NewAttributedQuoteProvider([]protocol.QuoteMessage{q})
+
+	if quote := provider.GetQuote(); quote != q {
+		t.Errorf("Unexpected quote: %+v", quote)
+	}
+}
+
+// TestSeededProviders ensures providers with the same seed serve the same sequence of quotes.
+func TestSeededProviders(t *testing.T) {
+	q := []protocol.QuoteMessage{{Text: "A"}, {Text: "B"}, {Text: "C"}, {Text: "D"}, {Text: "E"}}
+	dated := make([]quotes.DatedQuote, 0, len(q))
+	for _, quote := range q {
+		dated = append(dated, quotes.DatedQuote{Quote: quote})
+	}
+
+	for name, newProvider := range map[string]func() quotes.QuoteProvider{
+		"random":  func() quotes.QuoteProvider { return quotes.NewAttributedQuoteProvider(q, quotes.WithSeed(42)) },
+		"shuffle": func() quotes.QuoteProvider { return quotes.NewShuffleProvider(q, quotes.WithSeed(42)) },
+		"recency": func() quotes.QuoteProvider {
+			return quotes.NewRecencyWeightedProvider(dated, time.Hour, quotes.WithSeed(42))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			first, second := newProvider(), newProvider()
+			for i := 0; i < 20; i++ {
+				if a, b := first.GetQuote(), second.GetQuote(); a != b {
+					t.Fatalf("Quote %d differs between runs: %q and %q", i, a.Text, b.Text)
+				}
+			}
+		})
 	}
 }
@@ -0,0 +1,95 @@
+package quotes
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// recencyBoost is the extra weight of a quote added just now. It halves every
+// half-life, so a new quote starts out (1 + recencyBoost) times as likely as an
+// old one and converges to the same weight over time.
+const recencyBoost = 4.0
+
+// DatedQuote is a quote together with the time it was added to the collection
+type DatedQuote struct {
+	Quote   protocol.QuoteMessage
+	AddedAt time.Time
+}
+
+// RecencyWeightedProvider serves recently added quotes more often. Their extra
+// weight decays with the given half-life until all quotes are equally likely.
+type RecencyWeightedProvider struct {
+	quotes   []DatedQuote
+	halfLife time.Duration
+	now      func() time.Time
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewRecencyWeightedProvider creates a provider favoring quotes added within
+// a few half-lives, skipping those failing ValidateQuote
+func NewRecencyWeightedProvider(quotes []DatedQuote, halfLife time.Duration, opts ...Option) *RecencyWeightedProvider {
+	invalid := func(q DatedQuote) bool { return reserved(q.Quote) }
+	if slices.ContainsFunc(quotes, invalid) {
+		quotes = slices.DeleteFunc(slices.Clone(quotes), invalid)
+	}
+
+	o := newOptions(opts)
+	return &RecencyWeightedProvider{
+		quotes:   quotes,
+		halfLife: halfLife,
+		now:      o.now,
+		rng:      rand.New(rand.NewSource(o.seed)),
+	}
+}
+
+// GetQuote returns a random quote, weighted by how recently it was added
+func (p *RecencyWeightedProvider) GetQuote() protocol.QuoteMessage {
+	if len(p.quotes) == 0 {
+		return protocol.QuoteMessage{Text: Stub}
+	}
+
+	// Weights depend on the current time, so they are recomputed on every call
+	now := p.now()
+	weights := make([]float64, len(p.quotes))
+	var total float64
+	for i, q := range p.quotes {
+		weights[i] = p.weight(now.Sub(q.AddedAt))
+		total += weights[i]
+	}
+
+	p.mu.Lock()
+	target := p.rng.Float64() * total
+	p.mu.Unlock()
+
+	for i, w := range weights {
+		target -= w
+		if target < 0 {
+			return p.quotes[i].Quote
+		}
+	}
+	return p.quotes[len(p.quotes)-1].Quote
+}
+
+// weight returns the selection weight of a quote of the given age
+func (p *RecencyWeightedProvider) weight(age time.Duration) float64 {
+	if p.halfLife <= 0 {
+		return 1
+	}
+	age = max(age, 0)
+	return 1 + recencyBoost*math.Pow(0.5, float64(age)/float64(p.halfLife))
+}
+
+// Quotes lists all quotes regardless of their weight
+func (p *RecencyWeightedProvider) Quotes() []Quote {
+	quotes := make([]Quote, 0, len(p.quotes))
+	for _, dated := range p.quotes {
+		quotes = append(quotes, Quote{QuoteMessage: dated.Quote})
+	}
+	return quotes
+}
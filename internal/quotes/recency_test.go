@@ -0,0 +1,58 @@
+package quotes_test
+
+import (
+	"testing"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestRecencyWeightedProvider ensures a fresh quote is over-represented at first
+// and converges to the same share as the old ones later.
+func TestRecencyWeightedProvider(t *testing.T) {
+	const (
+		oldQuotes = 9
+		draws     = 10_000
+		halfLife  = time.Hour
+	)
+
+	now := time.Now()
+	dated := []quotes.DatedQuote{{Quote: protocol.QuoteMessage{Text: "fresh"}, AddedAt: now}}
+	for i := 0; i < oldQuotes; i++ {
+		dated = append(dated, quotes.DatedQuote{
+			Quote:   protocol.QuoteMessage{Text: "old"},
+			AddedAt: now.Add(-100 * halfLife),
+		})
+	}
+
+	provider := quotes.NewRecencyWeightedProvider(dated, halfLife, quotes.WithClock(func() time.Time { return now }))
+
+	freshShare := func() float64 {
+		fresh := 0
+		for i := 0; i < draws; i++ {
+			if provider.GetQuote().Text == "fresh" {
+				fresh++
+			}
+		}
+		return float64(fresh) / draws
+	}
+
+	uniform := 1.0 / (oldQuotes + 1)
+
+	if share := freshShare(); share < 2*uniform {
+		t.Errorf("Fresh quote should be over-represented, got share %.3f", share)
+	}
+
+	now = now.Add(20 * halfLife)
+	if share := freshShare(); share < uniform*0.7 || share > uniform*1.3 {
+		t.Errorf("Fresh quote should converge to the uniform share %.3f, got %.3f", uniform, share)
+	}
+}
+
+// TestRecencyWeightedProviderEmpty ensures the stub is returned for an empty list.
+func TestRecencyWeightedProviderEmpty(t *testing.T) {
+	provider := quotes.NewRecencyWeightedProvider(nil, time.Hour)
+	if quote := provider.GetQuote(); quote.Text != quotes.Stub {
+		t.Errorf("Expected stub, got %q", quote.Text)
+	}
+}
@@ -0,0 +1,77 @@
+package quotes
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// Registry maps collection names to quote providers. Unknown or empty names
+// fall back to the primary collection.
+type Registry struct {
+	mu        sync.RWMutex
+	primary   QuoteProvider
+	providers map[string]QuoteProvider
+}
+
+// NewRegistry creates a registry serving the primary provider by default
+func NewRegistry(primary QuoteProvider) *Registry {
+	return &Registry{
+		primary:   primary,
+		providers: make(map[string]QuoteProvider),
+	}
+}
+
+// Register adds a named collection, replacing any previous one with that name
+func (r *Registry) Register(name string, provider QuoteProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.providers[name] = provider
+}
+
+// Provider returns the named collection or the primary one when it is unknown
+func (r *Registry) Provider(name string) QuoteProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if provider, ok := r.providers[name]; ok {
+		return provider
+	}
+	return r.primary
+}
+
+// GetCollectionQuote returns a quote from the named collection
+func (r *Registry) GetCollectionQuote(name string) protocol.QuoteMessage {
+	return r.Provider(name).GetQuote()
+}
+
+// GetQuote returns a quote from the primary collection
+func (r *Registry) GetQuote() protocol.QuoteMessage {
+	return r.primary.GetQuote()
+}
+
+// Quotes lists the quotes of the primary collection followed by those of
+// every named collection in name order, categorized by collection name.
+// Collections that are not enumerable are skipped.
+func (r *Registry) Quotes() []Quote {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var quotes []Quote
+	if primary, ok := r.primary.(Enumerable); ok {
+		quotes = append(quotes, primary.Quotes()...)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.providers)) {
+		collection, ok := r.providers[name].(Enumerable)
+		if !ok {
+			continue
+		}
+		for _, quote := range collection.Quotes() {
+			quote.Category = name
+			quotes = append(quotes, quote)
+		}
+	}
+	return quotes
+}
@@ -0,0 +1,28 @@
+package quotes_test
+
+import (
+	"testing"
+	"word-of-wisdom/internal/quotes"
+)
+
+// TestRegistry ensures named collections are served and unknown names fall back to the primary one.
+func TestRegistry(t *testing.T) {
+	registry := quotes.NewRegistry(quotes.NewRandomQuoteProvider([]string{"primary"}))
+	registry.Register("stoicism", quotes.NewRandomQuoteProvider([]string{"stoic"}))
+
+	cases := map[string]string{
+		"stoicism": "stoic",
+		"unknown":  "primary",
+		"":         "primary",
+	}
+
+	for name, expected := range cases {
+		if quote := registry.GetCollectionQuote(name); quote.Text != expected {
+			t.Errorf("Collection %q: expected %q, got %q", name, expected, quote.Text)
+		}
+	}
+
+	if quote := registry.GetQuote(); quote.Text != "primary" {
+		t.Errorf("Expected primary quote, got %q", quote.Text)
+	}
+}
@@ -0,0 +1,80 @@
+package quotes
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// RotatingFileProvider serves quote packs from several files in turn, e.g. for
+// A/B testing. Each rotation reloads the next file, so edits are picked up.
+type RotatingFileProvider struct {
+	paths  []string
+	next   int
+	active atomic.Pointer[QuoteProvider]
+}
+
+// NewRotatingFileProvider serves the first file and switches to the next one
+// every interval. If a file fails to load on rotation, the error is logged and
+// the current pack stays active until the next rotation. The returned func
+// stops the rotation.
+func NewRotatingFileProvider(paths []string, interval time.Duration) (QuoteProvider, func(), error) {
+	if len(paths) == 0 {
+		return nil, nil, errors.New("no quote files to rotate")
+	}
+
+	p := &RotatingFileProvider{paths: paths}
+	if err := p.rotate(); err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.rotate(); err != nil {
+					logger.Error(err).Warn("Failed to rotate quote file, serving the current pack")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return p, stop, nil
+}
+
+// rotate loads the next file and makes it the active pack
+func (p *RotatingFileProvider) rotate() error {
+	path := p.paths[p.next]
+	p.next = (p.next + 1) % len(p.paths)
+
+	quotes, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	provider := NewAttributedQuoteProvider(quotes)
+	p.active.Store(&provider)
+	return nil
+}
+
+// GetQuote returns a random quote from the active pack
+func (p *RotatingFileProvider) GetQuote() protocol.QuoteMessage {
+	return (*p.active.Load()).GetQuote()
+}
+
+// Quotes lists the quotes of the active pack
+func (p *RotatingFileProvider) Quotes() []Quote {
+	return (*p.active.Load()).(Enumerable).Quotes()
+}
@@ -0,0 +1,100 @@
+package quotes_test
+
+import (
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/logger"
+)
+
+// writeQuotesFile writes a quote pack into the test directory
+func writeQuotesFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write quotes file: %v", err)
+	}
+	return path
+}
+
+// waitForQuote polls the provider until it serves the expected quote
+func waitForQuote(t *testing.T, provider quotes.QuoteProvider, text string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if provider.GetQuote().Text == text {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Provider never served %q", text)
+}
+
+// TestRotatingFileProvider ensures the provider switches between the files.
+func TestRotatingFileProvider(t *testing.T) {
+	packA := writeQuotesFile(t, "a.txt", "Pack A —— Author A\n")
+	packB := writeQuotesFile(t, "b.txt", "\nPack B\n")
+
+	provider, stop, err := quotes.NewRotatingFileProvider([]string{packA, packB}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	defer stop()
+
+	quote := provider.GetQuote()
+	if quote.Text != "Pack A" || quote.Author != "Author A" {
+		t.Fatalf("Expected the first pack, got %+v", quote)
+	}
+
+	waitForQuote(t, provider, "Pack B")
+	waitForQuote(t, provider, "Pack A")
+
+	stop()
+	stop() // stopping twice is safe
+}
+
+// TestRotatingFileProviderMissingFile ensures a missing first file is reported.
+func TestRotatingFileProviderMissingFile(t *testing.T) {
+	_, _, err := quotes.NewRotatingFileProvider([]string{filepath.Join(t.TempDir(), "missing.txt")}, time.Second)
+	if err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+}
+
+// TestRotatingFileProviderRotationError ensures a file failing to load on rotation is logged and the current pack kept.
+func TestRotatingFileProviderRotationError(t *testing.T) {
+	packA := writeQuotesFile(t, "a.txt", "Pack A\n")
+	packB := writeQuotesFile(t, "b.txt", "Pack B\n")
+
+	provider, stop, err := quotes.NewRotatingFileProvider([]string{packA, packB}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	defer stop()
+
+	hook := logtest.NewLocal(logger.GetLogger())
+	defer hook.Reset()
+	if err := os.Remove(packB); err != nil {
+		t.Fatalf("Failed to remove quotes file: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hook.LastEntry() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Failed rotation was not logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if entry := hook.LastEntry(); entry.Level != logrus.WarnLevel || entry.Data[logrus.ErrorKey] == nil {
+		t.Fatalf("Expected a warning with the rotation error, got %v: %s", entry.Level, entry.Message)
+	}
+	if quote := provider.GetQuote(); quote.Text != "Pack A" {
+		t.Fatalf("Expected the current pack, got %+v", quote)
+	}
+}
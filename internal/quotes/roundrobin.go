@@ -0,0 +1,107 @@
+package quotes
+
+import (
+	"sync"
+	"time"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// RoundRobinProvider walks through the quote list in order, keeping a
+// separate cursor per client key (usually the client IP). Cursors of clients
+// idle for longer than the TTL are dropped, so a returning client starts over.
+type RoundRobinProvider struct {
+	quotes    []protocol.QuoteMessage
+	idleTTL   time.Duration
+	cursors   sync.Map
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type cursor struct {
+	mu       sync.Mutex
+	next     int
+	lastSeen time.Time
+}
+
+// RoundRobinOption configures a RoundRobinProvider
+type RoundRobinOption func(*RoundRobinProvider)
+
+// WithRoundRobinClock replaces time.Now, e.g. to expire cursors in tests
+func WithRoundRobinClock(now func() time.Time) RoundRobinOption {
+	return func(p *RoundRobinProvider) {
+		p.now = now
+	}
+}
+
+// NewRoundRobinProvider creates a provider with per-client cursors expiring
+// after idleTTL, skipping quotes failing ValidateQuote
+func NewRoundRobinProvider(quotes []protocol.QuoteMessage, idleTTL time.Duration, opts ...RoundRobinOption) *RoundRobinProvider {
+	p := &RoundRobinProvider{
+		quotes:  validQuotes(quotes),
+		idleTTL: idleTTL,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// GetQuote returns the next quote of a cursor shared by all clients
+func (p *RoundRobinProvider) GetQuote() protocol.QuoteMessage {
+	return p.GetQuoteFor("")
+}
+
+// GetQuoteFor returns the next quote for the given client key
+func (p *RoundRobinProvider) GetQuoteFor(key string) protocol.QuoteMessage {
+	if len(p.quotes) == 0 {
+		return protocol.QuoteMessage{Text: Stub}
+	}
+
+	now := p.now()
+	p.sweep(now)
+
+	value, _ := p.cursors.LoadOrStore(key, &cursor{})
+	c := value.(*cursor)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	quote := p.quotes[c.next%len(p.quotes)]
+	c.next++
+	c.lastSeen = now
+
+	return quote
+}
+
+// sweep drops idle cursors, at most once per TTL
+func (p *RoundRobinProvider) sweep(now time.Time) {
+	if p.idleTTL <= 0 {
+		return
+	}
+
+	p.sweepMu.Lock()
+	if now.Sub(p.lastSweep) < p.idleTTL {
+		p.sweepMu.Unlock()
+		return
+	}
+	p.lastSweep = now
+	p.sweepMu.Unlock()
+
+	p.cursors.Range(func(key, value any) bool {
+		c := value.(*cursor)
+		c.mu.Lock()
+		idle := now.Sub(c.lastSeen) >= p.idleTTL
+		c.mu.Unlock()
+		if idle {
+			p.cursors.Delete(key)
+		}
+		return true
+	})
+}
+
+// Quotes lists the quotes in the order they are served
+func (p *RoundRobinProvider) Quotes() []Quote {
+	return uncategorized(p.quotes)
+}
@@ -0,0 +1,53 @@
+package quotes_test
+
+import (
+	"testing"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+var roundRobinQuotes = []protocol.QuoteMessage{{Text: "first"}, {Text: "second"}, {Text: "third"}}
+
+// TestRoundRobinPerIP ensures each IP independently walks the list in order.
+func TestRoundRobinPerIP(t *testing.T) {
+	p := quotes.NewRoundRobinProvider(roundRobinQuotes, time.Minute)
+
+	expect := func(ip, text string) {
+		t.Helper()
+		if quote := p.GetQuoteFor(ip); quote.Text != text {
+			t.Errorf("IP %s: expected %q, got %q", ip, text, quote.Text)
+		}
+	}
+
+	expect("10.0.0.1", "first")
+	expect("10.0.0.1", "second")
+	expect("10.0.0.2", "first")
+	expect("10.0.0.1", "third")
+	expect("10.0.0.2", "second")
+	expect("10.0.0.1", "first")
+}
+
+// TestRoundRobinIdleCleanup ensures idle cursors are dropped and restart from the beginning.
+func TestRoundRobinIdleCleanup(t *testing.T) {
+	now := time.Now()
+	p := quotes.NewRoundRobinProvider(roundRobinQuotes, time.Minute, quotes.WithRoundRobinClock(func() time.Time { return now }))
+
+	p.GetQuoteFor("10.0.0.1")
+	p.GetQuoteFor("10.0.0.1")
+
+	now = now.Add(2 * time.Minute)
+	p.GetQuoteFor("10.0.0.2") // triggers the sweep
+
+	if quote := p.GetQuoteFor("10.0.0.1"); quote.Text != "first" {
+		t.Errorf("Expected a fresh cursor, got %q", quote.Text)
+	}
+}
+
+// TestRoundRobinEmpty ensures the stub is returned for an empty list.
+func TestRoundRobinEmpty(t *testing.T) {
+	p := quotes.NewRoundRobinProvider(nil, time.Minute)
+	if quote := p.GetQuoteFor("10.0.0.1"); quote.Text != quotes.Stub {
+		t.Errorf("Expected stub, got %q", quote.Text)
+	}
+}
@@ -0,0 +1,183 @@
+package quotes
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+)
+
+const (
+	// DefaultRSSElement is the item element quotes are read from by default
+	DefaultRSSElement = "title"
+
+	// rssFetchTimeout bounds a single feed request of the default HTTP client
+	rssFetchTimeout = 10 * time.Second
+
+	// rssMaxFeedSize bounds the feed body read per request
+	rssMaxFeedSize = 1 << 20
+)
+
+// rssFeed is the part of an RSS 2.0 document quotes are read from
+type rssFeed struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+// rssItem keeps every child element, so the quote element is configurable
+type rssItem struct {
+	Elements []rssElement `xml:",any"`
+}
+
+type rssElement struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// RSSProvider serves quotes read from the items of an RSS 2.0 feed, fetched
+// again once they are older than the TTL
+type RSSProvider struct {
+	url     string
+	element string
+	ttl     time.Duration
+	client  *http.Client
+
+	mu         sync.Mutex
+	quotes     []protocol.QuoteMessage
+	fetched    time.Time
+	refreshing bool
+	rng        *rand.Rand
+}
+
+// NewRSSProvider fetches the feed at url and serves the text of the given
+// child element of its items, DefaultRSSElement when empty, in the wire
+// format "text —— author". Only direct children of <item> are supported, e.g.
+// "title" or "description", not full XPath expressions. Items with an empty
+// element or a reserved protocol prefix are skipped. After ttl, the next quote
+// triggers a refresh in the background; the cached quotes are served until it
+// succeeds. The ttl must be positive. A nil httpClient uses a client with a
+// 10s timeout.
+func NewRSSProvider(url string, itemXPath string, ttl time.Duration, httpClient *http.Client) (QuoteProvider, error) {
+	if ttl <= 0 {
+		return nil, errors.New("RSS feed TTL must be positive")
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: rssFetchTimeout}
+	}
+
+	p := &RSSProvider{
+		url:     url,
+		element: itemXPath,
+		ttl:     ttl,
+		client:  httpClient,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if p.element == "" {
+		p.element = DefaultRSSElement
+	}
+
+	quotes, err := p.fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	p.quotes = quotes
+	p.fetched = time.Now()
+	return p, nil
+}
+
+// GetQuote returns a random quote of the feed, refreshing it in the background once expired
+func (p *RSSProvider) GetQuote() protocol.QuoteMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.fetched) >= p.ttl && !p.refreshing {
+		p.refreshing = true
+		go p.refresh()
+	}
+
+	return p.quotes[p.rng.Intn(len(p.quotes))]
+}
+
+// Quotes lists the cached quotes of the feed
+func (p *RSSProvider) Quotes() []Quote {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return uncategorized(p.quotes)
+}
+
+// refresh fetches the feed again, keeping the cached quotes if it fails. A
+// failed refresh is logged and retried after another TTL rather than on
+// every quote.
+func (p *RSSProvider) refresh() {
+	quotes, err := p.fetch(context.Background())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		logger.Error(err).WithField("url", p.url).Warn("Failed to refresh RSS feed, serving cached quotes")
+	} else {
+		p.quotes = quotes
+	}
+	p.fetched = time.Now()
+	p.refreshing = false
+}
+
+// fetch downloads and parses the feed
+func (p *RSSProvider) fetch(ctx context.Context) ([]protocol.QuoteMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feed request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch feed: unexpected status %s", resp.Status)
+	}
+
+	quotes, err := parseRSS(io.LimitReader(resp.Body, rssMaxFeedSize), p.element)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %w", p.url, err)
+	}
+	return quotes, nil
+}
+
+// parseRSS reads the quotes from the given child element of every feed item
+func parseRSS(r io.Reader, element string) ([]protocol.QuoteMessage, error) {
+	var feed rssFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var quotes []protocol.QuoteMessage
+	for _, item := range feed.Items {
+		for _, e := range item.Elements {
+			if e.XMLName.Local != element {
+				continue
+			}
+			text := strings.TrimSpace(e.Value)
+			if text != "" && !reserved(protocol.QuoteMessage{Text: text}) {
+				quotes = append(quotes, protocol.ParseQuote(text))
+			}
+			break
+		}
+	}
+
+	if len(quotes) == 0 {
+		return nil, ErrNoQuotes
+	}
+	return quotes, nil
+}
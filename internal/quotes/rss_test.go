@@ -0,0 +1,148 @@
+package quotes_test
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// rssFeed builds a minimal RSS 2.0 document with one item per title
+func rssFeed(titles ...string) string {
+	var items strings.Builder
+	for _, title := range titles {
+		fmt.Fprintf(&items, "<item><title>%s</title><description>About %s</description></item>", title, title)
+	}
+	return `<?xml version="1.0"?><rss version="2.0"><channel><title>Quotes</title>` + items.String() + `</channel></rss>`
+}
+
+// TestRSSProvider ensures quotes are read from the feed items and refreshed after the TTL.
+func TestRSSProvider(t *testing.T) {
+	var feed atomic.Value
+	feed.Store(rssFeed("Know thyself. —— Socrates", "QUOTE:reserved", ""))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(feed.Load().(string)))
+	}))
+	defer server.Close()
+
+	provider, err := quotes.NewRSSProvider(server.URL, "", 50*time.Millisecond, server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	want := protocol.QuoteMessage{Text: "Know thyself.", Author: "Socrates"}
+	for i := 0; i < 5; i++ {
+		if quote := provider.GetQuote(); quote != want {
+			t.Fatalf("Expected %+v, got %+v", want, quote)
+		}
+	}
+
+	feed.Store(rssFeed("Fresh quote"))
+	time.Sleep(60 * time.Millisecond)
+
+	// The expired quotes are served once more while the feed is refreshed
+	deadline := time.Now().Add(time.Second)
+	for provider.GetQuote().Text != "Fresh quote" {
+		if time.Now().After(deadline) {
+			t.Fatal("Refreshed feed was not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRSSProviderElement ensures quotes can be read from another item element.
+func TestRSSProviderElement(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(rssFeed("A")))
+	}))
+	defer server.Close()
+
+	provider, err := quotes.NewRSSProvider(server.URL, "description", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if text := provider.GetQuote().Text; text != "About A" {
+		t.Fatalf("Expected the description, got %q", text)
+	}
+}
+
+// TestRSSProviderErrors ensures unusable feeds fail the provider creation.
+func TestRSSProviderErrors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"not found": http.NotFound,
+		"not xml":   func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("quotes")) },
+		"no items":  func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte(rssFeed())) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			if _, err := quotes.NewRSSProvider(server.URL, "", time.Hour, server.Client()); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
+
+// TestRSSProviderTTL ensures a non-positive TTL is rejected instead of refreshing on every quote.
+func TestRSSProviderTTL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(rssFeed("Know thyself.")))
+	}))
+	defer server.Close()
+
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, err := quotes.NewRSSProvider(server.URL, "", ttl, server.Client()); err == nil {
+			t.Fatalf("Expected an error for TTL %v", ttl)
+		}
+	}
+}
+
+// TestRSSProviderRefreshError ensures a failed refresh is logged and the cached quotes are still served.
+func TestRSSProviderRefreshError(t *testing.T) {
+	var broken atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(rssFeed("Know thyself.")))
+	}))
+	defer server.Close()
+
+	provider, err := quotes.NewRSSProvider(server.URL, "", 10*time.Millisecond, server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	hook := logtest.NewLocal(logger.GetLogger())
+	defer hook.Reset()
+
+	broken.Store(true)
+	time.Sleep(20 * time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if quote := provider.GetQuote(); quote.Text != "Know thyself." {
+			t.Fatalf("Expected the cached quote, got %q", quote.Text)
+		}
+		if entry := hook.LastEntry(); entry != nil {
+			if entry.Level != logrus.WarnLevel || entry.Data[logrus.ErrorKey] == nil {
+				t.Fatalf("Expected a warning with the refresh error, got %v: %s", entry.Level, entry.Message)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Failed refresh was not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,102 @@
+package quotes
+
+import (
+	"math/rand"
+	"sync"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// ShuffleProvider serves every quote once in a random order before any quote
+// repeats, then reshuffles.
+type ShuffleProvider struct {
+	mu     sync.Mutex
+	quotes []protocol.QuoteMessage
+	deck   []protocol.QuoteMessage
+	rng    *rand.Rand
+}
+
+// NewShuffleProvider creates a provider iterating over shuffled quotes,
+// skipping those failing ValidateQuote
+func NewShuffleProvider(quotes []protocol.QuoteMessage, opts ...Option) *ShuffleProvider {
+	return &ShuffleProvider{
+		quotes: validQuotes(quotes),
+		rng:    newRand(opts),
+	}
+}
+
+// GetQuote returns the next quote of the shuffled deck
+func (p *ShuffleProvider) GetQuote() protocol.QuoteMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.quotes) == 0 {
+		return protocol.QuoteMessage{Text: Stub}
+	}
+
+	if len(p.deck) == 0 {
+		p.shuffle()
+	}
+
+	quote := p.deck[len(p.deck)-1]
+	p.deck = p.deck[:len(p.deck)-1]
+	return quote
+}
+
+// SetQuotes replaces the quotes and starts a new shuffled deck, skipping
+// those failing ValidateQuote
+func (p *ShuffleProvider) SetQuotes(quotes []protocol.QuoteMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.quotes = validQuotes(quotes)
+	p.shuffle()
+}
+
+// Quotes lists the quotes in their original order
+func (p *ShuffleProvider) Quotes() []Quote {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return uncategorized(p.quotes)
+}
+
+// shuffle deals a new deck from the quotes, the caller holds the lock
+func (p *ShuffleProvider) shuffle() {
+	p.deck = append(p.deck[:0], p.quotes...)
+	p.rng.Shuffle(len(p.deck), func(i, j int) {
+		p.deck[i], p.deck[j] = p.deck[j], p.deck[i]
+	})
+}
+
+// ShuffledFileProvider serves the quotes of a file in shuffled order and
+// re-reads the file on Reload.
+type ShuffledFileProvider struct {
+	*ShuffleProvider
+
+	path string
+}
+
+// NewShuffledFileProvider loads the quote pack at path and iterates over it
+// without repeats. Use Reload to pick up edits of the file.
+func NewShuffledFileProvider(path string) (*ShuffledFileProvider, error) {
+	quotes, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShuffledFileProvider{
+		ShuffleProvider: NewShuffleProvider(quotes),
+		path:            path,
+	}, nil
+}
+
+// Reload re-reads the file and reshuffles. On error the current quotes stay active.
+func (p *ShuffledFileProvider) Reload() error {
+	quotes, err := LoadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	p.SetQuotes(quotes)
+	return nil
+}
@@ -0,0 +1,74 @@
+package quotes_test
+
+import (
+	"os"
+	"testing"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestShuffleProvider ensures no quote repeats before the whole list is served.
+func TestShuffleProvider(t *testing.T) {
+	provider := quotes.NewShuffleProvider([]protocol.QuoteMessage{{Text: "A"}, {Text: "B"}, {Text: "C"}})
+
+	for round := 0; round < 3; round++ {
+		seen := make(map[string]bool)
+		for i := 0; i < 3; i++ {
+			text := provider.GetQuote().Text
+			if seen[text] {
+				t.Fatalf("Quote %q repeated within round %d", text, round)
+			}
+			seen[text] = true
+		}
+	}
+}
+
+// TestShuffledFileProviderReload ensures quotes of the reloaded file appear in the rotation.
+func TestShuffledFileProviderReload(t *testing.T) {
+	path := writeQuotesFile(t, "quotes.txt", "Old A\nOld B\n")
+
+	provider, err := quotes.NewShuffledFileProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("New A\nNew B\nNew C\n"), 0o600); err != nil {
+		t.Fatalf("Failed to rewrite quotes file: %v", err)
+	}
+	if err := provider.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		seen[provider.GetQuote().Text] = true
+	}
+	for _, text := range []string{"New A", "New B", "New C"} {
+		if !seen[text] {
+			t.Fatalf("Reloaded quote %q not served, got %v", text, seen)
+		}
+	}
+}
+
+// TestShuffledFileProviderMissingFile ensures a missing file is reported and a failed reload keeps the quotes.
+func TestShuffledFileProviderMissingFile(t *testing.T) {
+	if _, err := quotes.NewShuffledFileProvider("does-not-exist.txt"); err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+
+	path := writeQuotesFile(t, "quotes.txt", "Only\n")
+	provider, err := quotes.NewShuffledFileProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove quotes file: %v", err)
+	}
+
+	if err := provider.Reload(); err == nil {
+		t.Fatal("Expected an error when reloading a missing file")
+	}
+	if text := provider.GetQuote().Text; text != "Only" {
+		t.Fatalf("Expected the quotes to stay active, got %q", text)
+	}
+}
@@ -0,0 +1,125 @@
+package quotes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"time"
+	"word-of-wisdom/pkg/protocol"
+)
+
+const (
+	// DefaultSQLiteQuery picks a random quote from a quotes(text, author) table
+	DefaultSQLiteQuery = "SELECT text, author FROM quotes ORDER BY RANDOM() LIMIT 1"
+
+	// sqliteListQuery lists every quote of the default quotes(text, author) table
+	sqliteListQuery = "SELECT text, author FROM quotes"
+
+	// sqliteQueryTimeout bounds a single quote query
+	sqliteQueryTimeout = time.Second
+
+	// sqliteMaxOpenConns sizes the connection pool
+	sqliteMaxOpenConns = 4
+)
+
+var ErrNoQuotes = errors.New("no quotes found")
+
+// SQLiteProvider queries a random quote from an SQLite database per request
+type SQLiteProvider struct {
+	db    *sql.DB
+	query string
+}
+
+// NewSQLiteProvider opens the database at dsn. The query must return the
+// quote text and author of a single random quote; an empty query uses
+// DefaultSQLiteQuery.
+func NewSQLiteProvider(dsn, query string) (*SQLiteProvider, error) {
+	if query == "" {
+		query = DefaultSQLiteQuery
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quotes database: %w", err)
+	}
+	db.SetMaxOpenConns(sqliteMaxOpenConns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqliteQueryTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to quotes database: %w", err)
+	}
+
+	return &SQLiteProvider{db: db, query: query}, nil
+}
+
+// Quote queries a random quote, giving up after the query timeout. A quote
+// failing ValidateQuote is returned as an error.
+func (p *SQLiteProvider) Quote(ctx context.Context) (protocol.QuoteMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, sqliteQueryTimeout)
+	defer cancel()
+
+	var text string
+	var author sql.NullString
+	err := p.db.QueryRowContext(ctx, p.query).Scan(&text, &author)
+	if errors.Is(err, sql.ErrNoRows) {
+		return protocol.QuoteMessage{}, ErrNoQuotes
+	}
+	if err != nil {
+		return protocol.QuoteMessage{}, fmt.Errorf("failed to query quote: %w", err)
+	}
+
+	quote := protocol.QuoteMessage{Text: text, Author: author.String}
+	if err := ValidateQuote(quote); err != nil {
+		return protocol.QuoteMessage{}, err
+	}
+	return quote, nil
+}
+
+// GetQuote returns a random quote, or the stub when the query fails.
+// Wrap the provider with NewFallbackProvider to serve other quotes instead.
+func (p *SQLiteProvider) GetQuote() protocol.QuoteMessage {
+	quote, err := p.Quote(context.Background())
+	if err != nil {
+		return protocol.QuoteMessage{Text: Stub}
+	}
+	return quote
+}
+
+// Quotes lists the quotes of the default quotes(text, author) table passing
+// ValidateQuote, or nil when the query fails, e.g. for a custom schema
+func (p *SQLiteProvider) Quotes() []Quote {
+	ctx, cancel := context.WithTimeout(context.Background(), sqliteQueryTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteListQuery)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var quotes []Quote
+	for rows.Next() {
+		var text string
+		var author sql.NullString
+		if err := rows.Scan(&text, &author); err != nil {
+			return nil
+		}
+		quote := protocol.QuoteMessage{Text: text, Author: author.String}
+		if !reserved(quote) {
+			quotes = append(quotes, Quote{QuoteMessage: quote})
+		}
+	}
+	if rows.Err() != nil {
+		return nil
+	}
+	return quotes
+}
+
+// Close closes the database
+func (p *SQLiteProvider) Close() error {
+	return p.db.Close()
+}
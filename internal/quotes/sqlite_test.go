@@ -0,0 +1,108 @@
+package quotes_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// newQuotesDB creates a shared in-memory database with the given quotes, kept alive until the test ends
+func newQuotesDB(t *testing.T, quotes ...protocol.QuoteMessage) string {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec("CREATE TABLE quotes (text TEXT NOT NULL, author TEXT)")
+	require.NoError(t, err)
+	for _, q := range quotes {
+		_, err = db.Exec("INSERT INTO quotes (text, author) VALUES (?, ?)", q.Text, q.Author)
+		require.NoError(t, err)
+	}
+
+	return dsn
+}
+
+// TestSQLiteProvider ensures a random quote of the database is returned
+func TestSQLiteProvider(t *testing.T) {
+	stored := []protocol.QuoteMessage{
+		{Text: "Do what you can, with what you have, where you are.", Author: "Theodore Roosevelt"},
+		{Text: "The journey of a thousand miles begins with one step.", Author: "Lao Tzu"},
+	}
+	dsn := newQuotesDB(t, stored...)
+
+	provider, err := quotes.NewSQLiteProvider(dsn, "")
+	require.NoError(t, err)
+	defer provider.Close()
+
+	for i := 0; i < 5; i++ {
+		quote, err := provider.Quote(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, stored, quote)
+	}
+	assert.Contains(t, stored, provider.GetQuote())
+}
+
+// TestSQLiteProviderErrors ensures database errors surface and fall back
+func TestSQLiteProviderErrors(t *testing.T) {
+	dsn := newQuotesDB(t)
+
+	empty, err := quotes.NewSQLiteProvider(dsn, "")
+	require.NoError(t, err)
+	defer empty.Close()
+
+	_, err = empty.Quote(context.Background())
+	assert.ErrorIs(t, err, quotes.ErrNoQuotes)
+	assert.Equal(t, quotes.Stub, empty.GetQuote().Text)
+
+	broken, err := quotes.NewSQLiteProvider(dsn, "SELECT text, author FROM missing ORDER BY RANDOM() LIMIT 1")
+	require.NoError(t, err)
+	defer broken.Close()
+
+	_, err = broken.Quote(context.Background())
+	assert.ErrorContains(t, err, "no such table")
+
+	var reported []error
+	fallback := quotes.NewFallbackProvider(broken, quotes.NewRandomQuoteProvider([]string{"Fallback"}), func(err error) {
+		reported = append(reported, err)
+	})
+	assert.Equal(t, "Fallback", fallback.GetQuote().Text)
+	require.Len(t, reported, 1)
+
+	// The deadline of the caller bounds the primary query
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fallback = quotes.NewFallbackProvider(empty, quotes.NewRandomQuoteProvider([]string{"Fallback"}), func(err error) {
+		reported = append(reported, err)
+	})
+	quote, err := fallback.Quote(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Fallback", quote.Text)
+	require.Len(t, reported, 2)
+	assert.ErrorIs(t, reported[1], context.Canceled)
+
+	require.NoError(t, broken.Close())
+	_, err = broken.Quote(context.Background())
+	assert.Error(t, err, "Closed database should fail")
+}
+
+// TestSQLiteProviderReservedPrefix ensures stored quotes that could be mistaken for control messages are not served
+func TestSQLiteProviderReservedPrefix(t *testing.T) {
+	dsn := newQuotesDB(t, protocol.QuoteMessage{Text: protocol.PrefixError + "not a quote"})
+
+	provider, err := quotes.NewSQLiteProvider(dsn, "")
+	require.NoError(t, err)
+	defer provider.Close()
+
+	_, err = provider.Quote(context.Background())
+	assert.ErrorIs(t, err, quotes.ErrReservedPrefix)
+	assert.Equal(t, quotes.Stub, provider.GetQuote().Text)
+	assert.Empty(t, provider.Quotes())
+}
@@ -0,0 +1,35 @@
+package quotes
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// ErrReservedPrefix rejects quotes starting with a protocol message prefix, see protocol.HasReservedPrefix
+var ErrReservedPrefix = errors.New("quote starts with a reserved protocol prefix")
+
+// ValidateQuote returns ErrReservedPrefix for a quote that could be mistaken
+// for a control message. Every provider checks its quotes with it: loaders
+// reject the whole source, constructors and dynamic sources skip the quote.
+func ValidateQuote(quote protocol.QuoteMessage) error {
+	if protocol.HasReservedPrefix(quote.Text) {
+		return fmt.Errorf("%w: %q", ErrReservedPrefix, quote.Text)
+	}
+	return nil
+}
+
+// reserved reports whether the quote fails ValidateQuote
+func reserved(quote protocol.QuoteMessage) bool {
+	return ValidateQuote(quote) != nil
+}
+
+// validQuotes returns the quotes passing ValidateQuote, without copying them
+// when all do
+func validQuotes(quotes []protocol.QuoteMessage) []protocol.QuoteMessage {
+	if !slices.ContainsFunc(quotes, reserved) {
+		return quotes
+	}
+	return slices.DeleteFunc(slices.Clone(quotes), reserved)
+}
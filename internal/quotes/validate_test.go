@@ -0,0 +1,48 @@
+package quotes_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestValidateQuote ensures only quotes starting with a protocol prefix are rejected
+func TestValidateQuote(t *testing.T) {
+	for _, prefix := range protocol.KnownPrefixes() {
+		err := quotes.ValidateQuote(protocol.QuoteMessage{Text: prefix + "not a quote"})
+		assert.ErrorIs(t, err, quotes.ErrReservedPrefix, prefix)
+	}
+	assert.NoError(t, quotes.ValidateQuote(protocol.QuoteMessage{Text: "Mind the " + protocol.PrefixError + " prefix"}))
+}
+
+// TestProvidersSkipReservedQuotes ensures every provider built from a quote list skips reserved quotes
+func TestProvidersSkipReservedQuotes(t *testing.T) {
+	valid := protocol.QuoteMessage{Text: "Know thyself.", Author: "Socrates"}
+	list := []protocol.QuoteMessage{{Text: protocol.PrefixError + "not a quote"}, valid, {Text: protocol.PrefixChallenge + "1"}}
+
+	shuffled := quotes.NewShuffleProvider(nil)
+	shuffled.SetQuotes(list)
+
+	providers := map[string]quotes.QuoteProvider{
+		"attributed":    quotes.NewAttributedQuoteProvider(list),
+		"random":        quotes.NewRandomQuoteProvider([]string{list[0].Text, valid.Text}),
+		"shuffle":       quotes.NewShuffleProvider(list),
+		"shuffle reset": shuffled,
+		"deterministic": quotes.NewDeterministicProvider(list),
+		"round robin":   quotes.NewRoundRobinProvider(list, time.Minute),
+		"recency": quotes.NewRecencyWeightedProvider([]quotes.DatedQuote{
+			{Quote: list[0], AddedAt: time.Now()},
+			{Quote: valid, AddedAt: time.Now()},
+		}, time.Hour),
+	}
+	for name, provider := range providers {
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, valid.Text, provider.GetQuote().Text, name)
+		}
+	}
+
+	// The caller's list is left untouched
+	assert.Equal(t, protocol.PrefixError+"not a quote", list[0].Text)
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a concurrency-safe map holding at most capacity entries. Adding to a
+// full map evicts the least recently used entry. A zero capacity never evicts.
+type LRU[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+}
+
+type lruItem[V any] struct {
+	key   string
+	value V
+}
+
+// NewLRU creates an LRU holding at most capacity entries
+func NewLRU[V any](capacity int) *LRU[V] {
+	return &LRU[V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// GetOrAdd returns the value for key, marking it as recently used, or stores
+// the result of create. added reports whether the value was created.
+func (l *LRU[V]) GetOrAdd(key string, create func() V) (value V, added bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.order.MoveToFront(elem)
+		return elem.Value.(*lruItem[V]).value, false
+	}
+
+	if l.capacity > 0 && l.order.Len() >= l.capacity {
+		l.remove(l.order.Back())
+	}
+
+	value = create()
+	l.items[key] = l.order.PushFront(&lruItem[V]{key: key, value: value})
+	return value, true
+}
+
+// Contains reports whether key is stored without marking it as used
+func (l *LRU[V]) Contains(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.items[key]
+	return ok
+}
+
+// Peek returns the value for key without marking it as used
+func (l *LRU[V]) Peek(key string) (value V, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return value, false
+	}
+	return elem.Value.(*lruItem[V]).value, true
+}
+
+// Remove deletes key, if stored
+func (l *LRU[V]) Remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.remove(elem)
+	}
+}
+
+// RemoveIf deletes the entries matching the predicate and returns how many were removed
+func (l *LRU[V]) RemoveIf(match func(V) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var removed int
+	for elem := l.order.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*lruItem[V]).value) {
+			l.remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+// Len returns the number of stored entries
+func (l *LRU[V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
+
+func (l *LRU[V]) remove(elem *list.Element) {
+	l.order.Remove(elem)
+	delete(l.items, elem.Value.(*lruItem[V]).key)
+}
@@ -0,0 +1,74 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"word-of-wisdom/internal/ratelimit"
+)
+
+// TestLRUEvictsLeastRecentlyUsed ensures the size never exceeds the capacity and the oldest entries go first.
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	lru := ratelimit.NewLRU[int](3)
+
+	for i := 0; i < 10; i++ {
+		_, added := lru.GetOrAdd(fmt.Sprintf("10.0.0.%d", i), func() int { return i })
+		assert.True(t, added)
+		assert.LessOrEqual(t, lru.Len(), 3)
+	}
+	assert.Equal(t, 3, lru.Len())
+	assert.False(t, lru.Contains("10.0.0.6"))
+
+	// Using 10.0.0.7 makes 10.0.0.8 the least recently used
+	value, added := lru.GetOrAdd("10.0.0.7", func() int { return -1 })
+	assert.False(t, added)
+	assert.Equal(t, 7, value)
+
+	lru.GetOrAdd("10.0.0.10", func() int { return 10 })
+	assert.False(t, lru.Contains("10.0.0.8"))
+	assert.True(t, lru.Contains("10.0.0.7"))
+	assert.True(t, lru.Contains("10.0.0.9"))
+	assert.True(t, lru.Contains("10.0.0.10"))
+}
+
+// TestLRUUnbounded ensures a zero capacity never evicts.
+func TestLRUUnbounded(t *testing.T) {
+	lru := ratelimit.NewLRU[int](0)
+
+	for i := 0; i < 100; i++ {
+		lru.GetOrAdd(fmt.Sprint(i), func() int { return i })
+	}
+	assert.Equal(t, 100, lru.Len())
+}
+
+// TestLRURemoveIf ensures matching entries are removed.
+func TestLRURemoveIf(t *testing.T) {
+	lru := ratelimit.NewLRU[int](0)
+	for i := 0; i < 10; i++ {
+		lru.GetOrAdd(fmt.Sprint(i), func() int { return i })
+	}
+
+	assert.Equal(t, 5, lru.RemoveIf(func(v int) bool { return v%2 == 0 }))
+	assert.Equal(t, 5, lru.Len())
+	assert.False(t, lru.Contains("4"))
+	assert.True(t, lru.Contains("5"))
+}
+
+// TestLRUPeek ensures Peek reads entries without changing the eviction order.
+func TestLRUPeek(t *testing.T) {
+	lru := ratelimit.NewLRU[int](2)
+	lru.GetOrAdd("a", func() int { return 1 })
+	lru.GetOrAdd("b", func() int { return 2 })
+
+	value, ok := lru.Peek("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	_, ok = lru.Peek("missing")
+	assert.False(t, ok)
+
+	// Peeking did not save "a" from eviction
+	lru.GetOrAdd("c", func() int { return 3 })
+	assert.False(t, lru.Contains("a"))
+	assert.True(t, lru.Contains("b"))
+}
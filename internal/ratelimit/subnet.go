@@ -0,0 +1,16 @@
+package ratelimit
+
+import "net"
+
+const ipv4Bits = 32
+
+// SubnetKey masks the IP to its CIDR subnet so that every address inside the
+// subnet shares the same key. Non-IPv4 addresses are returned unchanged.
+func SubnetKey(ip net.IP, prefixLen int) string {
+	ip4 := ip.To4()
+	if ip4 == nil || prefixLen <= 0 || prefixLen > ipv4Bits {
+		return ip.String()
+	}
+
+	return ip4.Mask(net.CIDRMask(prefixLen, ipv4Bits)).String()
+}
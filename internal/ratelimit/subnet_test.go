@@ -0,0 +1,31 @@
+package ratelimit_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"word-of-wisdom/internal/ratelimit"
+)
+
+// TestSubnetKeySameSubnet ensures addresses from the same /24 share a key.
+func TestSubnetKeySameSubnet(t *testing.T) {
+	a := ratelimit.SubnetKey(net.ParseIP("203.0.113.10"), 24)
+	b := ratelimit.SubnetKey(net.ParseIP("203.0.113.250"), 24)
+
+	assert.Equal(t, "203.0.113.0", a)
+	assert.Equal(t, a, b)
+}
+
+// TestSubnetKeyDifferentSubnets ensures addresses from different /24s get different keys.
+func TestSubnetKeyDifferentSubnets(t *testing.T) {
+	a := ratelimit.SubnetKey(net.ParseIP("203.0.113.10"), 24)
+	b := ratelimit.SubnetKey(net.ParseIP("203.0.114.10"), 24)
+
+	assert.NotEqual(t, a, b)
+}
+
+// TestSubnetKeyPassThrough ensures invalid masks and IPv6 addresses keep the full address.
+func TestSubnetKeyPassThrough(t *testing.T) {
+	assert.Equal(t, "203.0.113.10", ratelimit.SubnetKey(net.ParseIP("203.0.113.10"), 0))
+	assert.Equal(t, "2001:db8::1", ratelimit.SubnetKey(net.ParseIP("2001:db8::1"), 24))
+}
@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"bufio"
+	"net"
+)
+
+// BufferedConn buffers writes to the underlying connection so that all
+// messages of a single protocol turn reach the client in one write syscall.
+type BufferedConn struct {
+	net.Conn
+	writer *bufio.Writer
+}
+
+// NewBufferedConn wraps the connection with a write buffer
+func NewBufferedConn(conn net.Conn) *BufferedConn {
+	return &BufferedConn{
+		Conn:   conn,
+		writer: bufio.NewWriter(conn),
+	}
+}
+
+// Write buffers p until Flush or Close is called
+func (c *BufferedConn) Write(p []byte) (int, error) {
+	return c.writer.Write(p)
+}
+
+// Flush sends all buffered data to the underlying connection
+func (c *BufferedConn) Flush() error {
+	return c.writer.Flush()
+}
+
+// Close flushes pending writes before closing the underlying connection
+func (c *BufferedConn) Close() error {
+	flushErr := c.Flush()
+	if err := c.Conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
@@ -0,0 +1,82 @@
+package transport_test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"word-of-wisdom/internal/transport"
+)
+
+// countingConn counts Write calls reaching the network
+type countingConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes++
+	return len(p), nil
+}
+
+// TestBufferedConnCoalescesWrites ensures buffered messages are written in a single call.
+func TestBufferedConnCoalescesWrites(t *testing.T) {
+	raw := &countingConn{}
+	conn := transport.NewBufferedConn(raw)
+
+	for i := 0; i < 3; i++ {
+		_, err := fmt.Fprintln(conn, "message")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, raw.writes, "Nothing should be written before flush")
+
+	require.NoError(t, conn.Flush())
+	assert.Equal(t, 1, raw.writes)
+}
+
+// TestBufferedConnFlushOnClose ensures buffered data reaches the peer before the connection closes.
+func TestBufferedConnFlushOnClose(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	conn := transport.NewBufferedConn(serverConn)
+
+	_, err := fmt.Fprintln(conn, "QUOTE:hello")
+	require.NoError(t, err)
+
+	received := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(clientConn)
+		received <- data
+	}()
+
+	require.NoError(t, conn.Close())
+	assert.Equal(t, "QUOTE:hello\n", string(<-received))
+}
+
+// BenchmarkWrites compares unbuffered and buffered writes of a typical exchange.
+func BenchmarkWrites(b *testing.B) {
+	messages := []string{"CHALLENGE:4:1a2b3c4d", "ERROR:Invalid PoW solution"}
+
+	b.Run("unbuffered", func(b *testing.B) {
+		conn := &countingConn{}
+		for i := 0; i < b.N; i++ {
+			for _, m := range messages {
+				_, _ = fmt.Fprintln(conn, m)
+			}
+		}
+		b.ReportMetric(float64(conn.writes)/float64(b.N), "writes/op")
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		raw := &countingConn{}
+		conn := transport.NewBufferedConn(raw)
+		for i := 0; i < b.N; i++ {
+			for _, m := range messages {
+				_, _ = fmt.Fprintln(conn, m)
+			}
+			_ = conn.Flush()
+		}
+		b.ReportMetric(float64(raw.writes)/float64(b.N), "writes/op")
+	})
+}
@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rotationDateLayout is appended to the file name of rotated logs
+const rotationDateLayout = "2006-01-02"
+
+// WithFileOutput returns a logger appending to filepath.Join(dir, filename) at
+// the level of the singleton logger. With rotateDaily, the file is renamed to
+// filename.YYYY-MM-DD at midnight, or filename.YYYY-MM-DD.N if that exists,
+// and a new one is started. The returned func stops the rotation and closes
+// the file.
+func WithFileOutput(dir, filename string, rotateDaily bool) (*logrus.Logger, func(), error) {
+	w, err := openRotatingFile(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := logrus.New()
+	l.SetFormatter(&logrus.TextFormatter{FullTimestamp: true, DisableColors: true})
+	if os.Getenv("LOG_FORMAT") == "json" {
+		l.SetFormatter(&logrus.JSONFormatter{})
+	}
+	l.SetOutput(w)
+	l.SetLevel(GetLogger().GetLevel())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if rotateDaily {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.rotateAtMidnight(done)
+		}()
+	}
+
+	var once sync.Once
+	closeFn := func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			if err := w.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to close log file: %v\n", err)
+			}
+		})
+	}
+
+	return l, closeFn, nil
+}
+
+// rotatingFile is a log file that can be swapped while loggers write to it
+type rotatingFile struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return &rotatingFile{path: path, file: file}, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+// Close syncs and closes the current file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.file.Sync(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// rotate renames the current file after the given day and opens a new one.
+// If the new file cannot be opened, logging continues in the renamed file.
+func (f *rotatingFile) rotate(day time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rotated, err := rotatedPath(f.path + "." + day.Format(rotationDateLayout))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	_ = f.file.Close()
+	f.file = file
+	return nil
+}
+
+// rotatedPath returns name, or name.N with the lowest N not taken, so that
+// rotating twice on the same day never overwrites an earlier log
+func rotatedPath(name string) (string, error) {
+	path := name
+	for n := 1; ; n++ {
+		_, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to rotate log file: %w", err)
+		}
+		path = fmt.Sprintf("%s.%d", name, n)
+	}
+}
+
+// rotateAtMidnight rotates the file at every local midnight until done is closed.
+// Each midnight is rotated once, even if the timer fires early or late.
+func (f *rotatingFile) rotateAtMidnight(done <-chan struct{}) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	for {
+		timer := time.NewTimer(time.Until(midnight))
+
+		select {
+		case <-timer.C:
+			if err := f.rotate(midnight.AddDate(0, 0, -1)); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+			for !midnight.After(time.Now()) {
+				midnight = midnight.AddDate(0, 0, 1)
+			}
+		case <-done:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package logger_test
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"word-of-wisdom/pkg/logger"
+)
+
+// TestWithFileOutput ensures every log line ends up in the file
+func TestWithFileOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	log, closeFn, err := logger.WithFileOutput(dir, "server.log", true)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		log.WithField("line", i).Info("Client disconnected")
+	}
+	closeFn()
+	closeFn() // closing twice is safe
+
+	content, err := os.ReadFile(filepath.Join(dir, "server.log"))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 100)
+	assert.Contains(t, lines[0], "line=0")
+	assert.Contains(t, lines[99], "line=99")
+	for _, line := range lines {
+		assert.Contains(t, line, "Client disconnected")
+	}
+}
+
+// TestWithFileOutputAppends ensures an existing log file is appended to
+func TestWithFileOutputAppends(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	require.NoError(t, os.WriteFile(path, []byte("previous run\n"), 0o644))
+
+	log, closeFn, err := logger.WithFileOutput(dir, "server.log", false)
+	require.NoError(t, err)
+	log.Info("next run")
+	closeFn()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "previous run\n"))
+	assert.Contains(t, string(content), "next run")
+
+	_, _, err = logger.WithFileOutput(filepath.Join(dir, "missing"), "server.log", false)
+	assert.Error(t, err)
+}
+
+// TestWithFileOutputLevel ensures the file logger keeps the configured level
+func TestWithFileOutputLevel(t *testing.T) {
+	level := logger.GetLogger().GetLevel()
+	logger.GetLogger().SetLevel(logrus.InfoLevel)
+	t.Cleanup(func() { logger.GetLogger().SetLevel(level) })
+
+	log, closeFn, err := logger.WithFileOutput(t.TempDir(), "server.log", false)
+	require.NoError(t, err)
+	defer closeFn()
+
+	assert.Equal(t, logrus.InfoLevel, log.GetLevel())
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"github.com/sirupsen/logrus"
 	"os"
 	"sync"
@@ -11,6 +12,11 @@ var (
 	once sync.Once
 )
 
+// RequestIDField is the field set by WithRequestID
+const RequestIDField = "request_id"
+
+type ctxKey struct{}
+
 // Init initializes the logger once
 func Init() {
 	once.Do(func() {
@@ -19,15 +25,48 @@ func Init() {
 			FullTimestamp: true,
 			ForceColors:   true,
 		})
+		if os.Getenv("LOG_FORMAT") == "json" {
+			log.SetFormatter(&logrus.JSONFormatter{})
+		}
 		log.SetOutput(os.Stdout)
 		log.SetLevel(logrus.DebugLevel)
 	})
 }
 
-// GetLogger returns a singleton logger instance
+// GetLogger returns a singleton logger instance. It is safe for concurrent use.
 func GetLogger() *logrus.Logger {
-	if log == nil {
-		Init()
-	}
+	Init()
 	return log
 }
+
+// Field returns an entry of the singleton logger with a single field
+func Field(key string, value interface{}) *logrus.Entry {
+	return GetLogger().WithField(key, value)
+}
+
+// Error returns an entry of the singleton logger carrying err in the error field
+func Error(err error) *logrus.Entry {
+	return GetLogger().WithError(err)
+}
+
+// WithRequestID returns an entry of the singleton logger correlating the
+// lines of a request, or a plain entry when id is empty
+func WithRequestID(id string) *logrus.Entry {
+	if id == "" {
+		return logrus.NewEntry(GetLogger())
+	}
+	return Field(RequestIDField, id)
+}
+
+// NewContext returns a copy of ctx carrying the given log entry
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, entry)
+}
+
+// FromContext returns the log entry stored in ctx or a plain entry of the singleton logger
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(ctxKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(GetLogger())
+}
@@ -0,0 +1,35 @@
+package logger_test
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"word-of-wisdom/pkg/logger"
+)
+
+// TestEntryConstructors ensures the entries come from the singleton logger with the expected fields
+func TestEntryConstructors(t *testing.T) {
+	err := errors.New("boom")
+
+	tests := []struct {
+		name  string
+		entry *logrus.Entry
+		data  logrus.Fields
+	}{
+		{name: "field", entry: logger.Field("challenge", "4:abc"), data: logrus.Fields{"challenge": "4:abc"}},
+		{name: "error", entry: logger.Error(err), data: logrus.Fields{logrus.ErrorKey: err}},
+		{name: "request id", entry: logger.WithRequestID("req-42"), data: logrus.Fields{logger.RequestIDField: "req-42"}},
+		{name: "empty request id", entry: logger.WithRequestID(""), data: logrus.Fields{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Same(t, logger.GetLogger(), tt.entry.Logger)
+			assert.Equal(t, tt.data, tt.entry.Data)
+		})
+	}
+
+	chained := logger.WithRequestID("req-42").WithField("event", "quote_served")
+	assert.Equal(t, logrus.Fields{logger.RequestIDField: "req-42", "event": "quote_served"}, chained.Data)
+}
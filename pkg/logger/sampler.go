@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler bounds the log volume of high-frequency events: only the first
+// limit events of each kind are logged per window, the rest are counted
+// and reported by Sample or Flush once the window has passed.
+type Sampler struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*sampleWindow
+}
+
+type sampleWindow struct {
+	start time.Time
+	seen  int
+}
+
+// NewSampler creates a sampler logging up to limit events of each kind per window
+func NewSampler(limit int, window time.Duration) *Sampler {
+	return &Sampler{
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+		windows: make(map[string]*sampleWindow),
+	}
+}
+
+// Window returns the sampling window
+func (s *Sampler) Window() time.Duration {
+	return s.window
+}
+
+// Sample records an event and reports whether it should be logged. When the
+// event starts a new window, it also returns how many events were suppressed
+// in the previous one so the caller can log a summary.
+func (s *Sampler) Sample(event string) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	w, ok := s.windows[event]
+	if !ok {
+		w = &sampleWindow{start: now}
+		s.windows[event] = w
+	}
+
+	var suppressed int
+	if now.Sub(w.start) >= s.window {
+		suppressed = max(w.seen-s.limit, 0)
+		w.start = now
+		w.seen = 0
+	}
+
+	w.seen++
+	return w.seen <= s.limit, suppressed
+}
+
+// Flush ends the windows that have passed and returns how many events of each
+// kind they suppressed, so a summary is logged even when no further event
+// of that kind arrives. Kinds without suppressed events are omitted.
+func (s *Sampler) Flush() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	suppressed := make(map[string]int)
+	for event, w := range s.windows {
+		if now.Sub(w.start) < s.window {
+			continue
+		}
+		if n := w.seen - s.limit; n > 0 {
+			suppressed[event] = n
+		}
+		delete(s.windows, event)
+	}
+	return suppressed
+}
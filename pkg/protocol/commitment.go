@@ -0,0 +1,20 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// CommitChallenge returns the commitment the server sends before revealing
+// the challenge, the hex encoded SHA-256 hash of the challenge. The random
+// nonce of the challenge hides its difficulty until the client commits with
+// PrefixCommit, while the client can check the revealed challenge against it.
+func CommitChallenge(challenge string) string {
+	hash := sha256.Sum256([]byte(challenge))
+	return hex.EncodeToString(hash[:])
+}
+
+// VerifyCommitment reports whether the revealed challenge matches the commitment
+func VerifyCommitment(commitment, challenge string) bool {
+	return commitment == CommitChallenge(challenge)
+}
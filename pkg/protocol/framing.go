@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Framing delimits messages on the wire. The line protocol terminates every
+// message with a newline, framed protocols carry the message verbatim.
+type Framing interface {
+	// WriteMessage writes a single message, including its delimiter
+	WriteMessage(w io.Writer, message string) error
+}
+
+// Line endings of LineFraming
+const (
+	LF   = "\n"
+	CRLF = "\r\n"
+)
+
+// LineFraming terminates every message with Ending, LF when empty, the
+// default text protocol. CRLF suits telnet-style clients.
+type LineFraming struct {
+	Ending string
+}
+
+func (f LineFraming) WriteMessage(w io.Writer, message string) error {
+	_, err := io.WriteString(w, message+cmp.Or(f.Ending, LF))
+	return err
+}
+
+// LengthPrefixedFraming precedes every message with its length as a 4-byte
+// big-endian integer and adds no line ending, so payloads stay untouched.
+type LengthPrefixedFraming struct{}
+
+func (LengthPrefixedFraming) WriteMessage(w io.Writer, message string) error {
+	if uint64(len(message)) > math.MaxUint32 {
+		return fmt.Errorf("%w: %d bytes do not fit a frame", ErrMessageTooLarge, len(message))
+	}
+
+	frame := make([]byte, 4, 4+len(message))
+	binary.BigEndian.PutUint32(frame, uint32(len(message)))
+	_, err := w.Write(append(frame, message...))
+	return err
+}
+
+// ReadFrame reads a single message written by LengthPrefixedFraming,
+// returning ErrMessageTooLarge for frames longer than limit bytes
+func ReadFrame(r io.Reader, limit int64) (string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return "", err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if int64(n) > limit {
+		return "", fmt.Errorf("%w: frame of %d bytes exceeds %d", ErrMessageTooLarge, n, limit)
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
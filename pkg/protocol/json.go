@@ -0,0 +1,22 @@
+package protocol
+
+// Types of JSONMessage
+const (
+	JSONChallenge = "challenge" // server to client, carries Challenge and Difficulty
+	JSONSolution  = "solution"  // client to server, carries Solution
+	JSONQuote     = "quote"     // server to client, carries Quote and Author
+	JSONError     = "error"     // server to client, carries Error
+)
+
+// JSONMessage is a single message of the JSON protocol, spoken by the
+// WebSocket front-end with one message per text frame. The exchange mirrors
+// the line protocol: a challenge, the solution, then a quote or an error.
+type JSONMessage struct {
+	Type       string `json:"type"`
+	Challenge  string `json:"challenge,omitempty"`
+	Difficulty int    `json:"difficulty,omitempty"`
+	Solution   string `json:"solution,omitempty"`
+	Quote      string `json:"quote,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -1,7 +1,251 @@
 package protocol
 
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
 const (
-	PrefixChallenge = "CHALLENGE:"
-	PrefixQuote     = "QUOTE:"
-	PrefixError     = "ERROR:"
+	PrefixChallenge  = "CHALLENGE:"
+	PrefixQuote      = "QUOTE:"
+	PrefixError      = "ERROR:"
+	PrefixVersion    = "VERSION "    // announces the protocol version
+	PrefixDifficulty = "DIFFICULTY:" // announces the difficulty of the next challenge
+	PrefixDone       = "DONE"        // ends a keep-alive session, carries no payload
+	PrefixHeartbeat  = "HEARTBEAT"   // keeps an idle connection alive, carries no payload
+	PrefixCollection = "COLLECTION:" // names the quote collection in the client hello
+	PrefixSeed       = "SEED:"       // carries the quote selection seed in the client hello
+	PrefixAck        = "ACK"         // acknowledges the received quotes, carries no payload
+	PrefixCommitment = "COMMITMENT:" // commits to the next challenge before revealing it
+	PrefixCommit     = "COMMIT"      // commits the client to solve the committed challenge, carries no payload
 )
+
+// KnownPrefixes returns all message prefixes of the protocol, e.g. to build
+// prefix-driven dispatch tables. The returned slice may be modified.
+func KnownPrefixes() []string {
+	return []string{
+		PrefixChallenge,
+		PrefixQuote,
+		PrefixError,
+		PrefixVersion,
+		PrefixDifficulty,
+		PrefixDone,
+		PrefixHeartbeat,
+		PrefixCollection,
+		PrefixSeed,
+		PrefixAck,
+		// PrefixCommit is a prefix of PrefixCommitment, so it must come after it
+		PrefixCommitment,
+		PrefixCommit,
+	}
+}
+
+// HasReservedPrefix reports whether text starts with a message prefix. Quotes
+// always follow PrefixQuote on the wire, but clients matching prefixes anywhere
+// in a line could mistake such a quote for a control message, so quote
+// sources must not contain them.
+func HasReservedPrefix(text string) bool {
+	for _, prefix := range KnownPrefixes() {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSeedLength bounds the quote selection seed of the client hello
+const MaxSeedLength = 64
+
+// ValidSeed reports whether seed may select a quote: 1 to MaxSeedLength
+// ASCII letters, digits, dots, dashes or underscores
+func ValidSeed(seed string) bool {
+	if seed == "" || len(seed) > MaxSeedLength {
+		return false
+	}
+	for _, c := range seed {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorSeparator splits the quote text from its author on the wire
+const AuthorSeparator = " —— "
+
+// QuoteMessage is the canonical representation of a served quote
+type QuoteMessage struct {
+	Text   string
+	Author string
+}
+
+// String encodes the quote for the wire, omitting the separator when there is no author
+func (q QuoteMessage) String() string {
+	if q.Author == "" {
+		return q.Text
+	}
+	return q.Text + AuthorSeparator + q.Author
+}
+
+// ParseQuote decodes a quote line, with or without the QUOTE prefix.
+// Legacy quotes without an author get an empty Author.
+func ParseQuote(line string) QuoteMessage {
+	line = strings.TrimPrefix(strings.TrimRight(line, "\r\n"), PrefixQuote)
+
+	text, author, _ := strings.Cut(line, AuthorSeparator)
+	return QuoteMessage{Text: text, Author: author}
+}
+
+// ChallengeSeparator splits the advertised difficulty from the random part of a challenge
+const ChallengeSeparator = ":"
+
+var ErrInvalidChallenge = errors.New("invalid challenge format")
+
+// FormatChallenge embeds the difficulty into the challenge. The whole string
+// stays opaque for hashing, but clients can read the difficulty from it.
+func FormatChallenge(difficulty int, nonce string) string {
+	return strconv.Itoa(difficulty) + ChallengeSeparator + nonce
+}
+
+// ChallengeDifficulty extracts the difficulty advertised in a challenge
+func ChallengeDifficulty(challenge string) (int, error) {
+	raw, nonce, ok := strings.Cut(challenge, ChallengeSeparator)
+	if !ok || nonce == "" {
+		return 0, ErrInvalidChallenge
+	}
+
+	difficulty, err := strconv.Atoi(raw)
+	if err != nil || difficulty < 0 {
+		return 0, fmt.Errorf("%w: bad difficulty %q", ErrInvalidChallenge, raw)
+	}
+
+	return difficulty, nil
+}
+
+// SolutionSeparator separates the challenge from the solution in the hashed
+// input. Neither can contain a line ending, so the input maps back to a single pair.
+const SolutionSeparator = "\n"
+
+// SolutionHashInput returns the bytes hashed to check a solution. Concatenating
+// them directly would hash challenge "ab" with solution "c" like challenge "a"
+// with solution "bc". legacy does so anyway, for clients predating the separator.
+func SolutionHashInput(challenge, solution string, legacy bool) []byte {
+	if legacy {
+		return []byte(challenge + solution)
+	}
+	return []byte(challenge + SolutionSeparator + solution)
+}
+
+// MaxMessageSize is the default limit of a single message line, without the line ending
+const MaxMessageSize = 64 * 1024
+
+var ErrMessageTooLarge = errors.New("message too large")
+
+// Message is a single protocol line split into its known prefix, if any, and the payload
+type Message struct {
+	Prefix  string
+	Payload string
+}
+
+// String encodes the message for the wire, without the line ending
+func (m Message) String() string {
+	return m.Prefix + m.Payload
+}
+
+// ParseMessage splits a line into a known prefix and its payload. Lines
+// without a known prefix, e.g. PoW solutions, are returned as the payload.
+func ParseMessage(line string) Message {
+	line = strings.TrimRight(line, "\r\n")
+	for _, prefix := range KnownPrefixes() {
+		if payload, ok := strings.CutPrefix(line, prefix); ok {
+			return Message{Prefix: prefix, Payload: payload}
+		}
+	}
+	return Message{Payload: line}
+}
+
+// Decode reads a single message of at most MaxMessageSize bytes
+func Decode(r *bufio.Reader) (Message, error) {
+	return DecodeWithLimit(r, MaxMessageSize)
+}
+
+// DecodeWithLimit reads a single message, returning ErrMessageTooLarge as soon
+// as the line exceeds limit bytes without the line ending. The limit is checked
+// after every read from the underlying reader, so oversized lines are never
+// buffered whole; the rest of such a line is left unread.
+func DecodeWithLimit(r *bufio.Reader, limit int64) (Message, error) {
+	var line []byte
+	for {
+		// Wait for at least one byte, then consume whatever is buffered
+		if _, err := r.Peek(1); err != nil {
+			return Message{}, err
+		}
+		buffered, _ := r.Peek(r.Buffered())
+
+		end := bytes.IndexByte(buffered, '\n')
+		if end >= 0 {
+			buffered = buffered[:end+1]
+		}
+		line = append(line, buffered...)
+		_, _ = r.Discard(len(buffered))
+
+		if int64(len(bytes.TrimRight(line, "\r\n"))) > limit {
+			return Message{}, fmt.Errorf("%w: exceeds %d bytes", ErrMessageTooLarge, limit)
+		}
+		if end >= 0 {
+			return ParseMessage(string(line)), nil
+		}
+	}
+}
+
+// DecodeScanner reads the next message from a scanner created with Scanner or
+// ScannerWithLimit, returning ErrMessageTooLarge for lines over its limit and
+// io.EOF once the input ends
+func DecodeScanner(s *bufio.Scanner) (Message, error) {
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return Message{}, err
+		}
+		return Message{}, io.EOF
+	}
+	return ParseMessage(s.Text()), nil
+}
+
+// Rejection reasons carried by structured rejections
+const (
+	RejectionRateLimited = "rate_limited"
+	RejectionCapacity    = "capacity"
+	RejectionPaused      = "paused"
+)
+
+// Rejection is a structured hint sent as the JSON payload of an ERROR message,
+// so clients can adapt before retrying, e.g. pre-solve at the right difficulty
+type Rejection struct {
+	Reason       string `json:"reason"`
+	Difficulty   int    `json:"difficulty"`
+	RetryAfterMS int64  `json:"retry_after_ms"`
+}
+
+// FormatRejection encodes the rejection as an ERROR line, without the line ending
+func FormatRejection(r Rejection) string {
+	payload, _ := json.Marshal(r)
+	return PrefixError + string(payload)
+}
+
+// ParseRejection decodes the payload of an ERROR message. It reports false
+// for plain-text errors.
+func ParseRejection(payload string) (Rejection, bool) {
+	var r Rejection
+	if err := json.Unmarshal([]byte(payload), &r); err != nil || r.Reason == "" {
+		return Rejection{}, false
+	}
+	return r, true
+}
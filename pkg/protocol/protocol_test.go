@@ -0,0 +1,267 @@
+package protocol_test
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"word-of-wisdom/pkg/protocol"
+)
+
+// TestChallengeDifficulty ensures the advertised difficulty is read from a challenge
+func TestChallengeDifficulty(t *testing.T) {
+	difficulty, err := protocol.ChallengeDifficulty(protocol.FormatChallenge(4, "1a2b"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, difficulty)
+
+	for _, challenge := range []string{"", "1a2b", "x:1a2b", "4:", "-1:1a2b"} {
+		_, err := protocol.ChallengeDifficulty(challenge)
+		assert.ErrorIs(t, err, protocol.ErrInvalidChallenge, challenge)
+	}
+}
+
+// TestParseQuote ensures quotes round-trip with and without an author
+func TestParseQuote(t *testing.T) {
+	attributed := protocol.QuoteMessage{Text: "Know thyself.", Author: "Socrates"}
+	assert.Equal(t, attributed, protocol.ParseQuote(protocol.PrefixQuote+attributed.String()+"\n"))
+
+	legacy := protocol.ParseQuote("QUOTE:Know thyself.")
+	assert.Equal(t, protocol.QuoteMessage{Text: "Know thyself."}, legacy)
+	assert.Equal(t, "Know thyself.", legacy.String())
+}
+
+// TestKnownPrefixes ensures every prefix is listed once and callers cannot alter the list
+func TestKnownPrefixes(t *testing.T) {
+	prefixes := protocol.KnownPrefixes()
+	assert.ElementsMatch(t, []string{
+		protocol.PrefixChallenge,
+		protocol.PrefixQuote,
+		protocol.PrefixError,
+		protocol.PrefixVersion,
+		protocol.PrefixDifficulty,
+		protocol.PrefixDone,
+		protocol.PrefixHeartbeat,
+		protocol.PrefixCollection,
+		protocol.PrefixSeed,
+		protocol.PrefixAck,
+		protocol.PrefixCommitment,
+		protocol.PrefixCommit,
+	}, prefixes)
+
+	prefixes[0] = "MODIFIED:"
+	assert.Equal(t, protocol.PrefixChallenge, protocol.KnownPrefixes()[0])
+}
+
+// TestDecodeWithLimit ensures lines up to the limit are decoded and longer ones rejected
+func TestDecodeWithLimit(t *testing.T) {
+	cases := []struct {
+		name    string
+		size    int
+		tooLong bool
+	}{
+		{"under the limit", protocol.MaxMessageSize - 1, false},
+		{"at the limit", protocol.MaxMessageSize, false},
+		{"over the limit", protocol.MaxMessageSize + 1, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			line := protocol.PrefixQuote + strings.Repeat("a", c.size-len(protocol.PrefixQuote))
+			reader := bufio.NewReader(strings.NewReader(line + "\r\n"))
+
+			msg, err := protocol.Decode(reader)
+			if c.tooLong {
+				assert.ErrorIs(t, err, protocol.ErrMessageTooLarge)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixQuote, msg.Prefix)
+			assert.True(t, msg.String() == line, "Decoded message differs from the sent line")
+		})
+	}
+}
+
+// TestDecodeScanner ensures lines up to the scanner limit are decoded and longer ones rejected
+func TestDecodeScanner(t *testing.T) {
+	cases := []struct {
+		name    string
+		size    int
+		tooLong bool
+	}{
+		{"under the limit", protocol.MaxMessageSize - 1, false},
+		{"at the limit", protocol.MaxMessageSize, false},
+		{"over the limit", protocol.MaxMessageSize + 1, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			line := protocol.PrefixQuote + strings.Repeat("a", c.size-len(protocol.PrefixQuote))
+			scanner := protocol.Scanner(strings.NewReader(line + "\r\n"))
+
+			msg, err := protocol.DecodeScanner(scanner)
+			if c.tooLong {
+				assert.ErrorIs(t, err, protocol.ErrMessageTooLarge)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.PrefixQuote, msg.Prefix)
+			assert.True(t, msg.String() == line, "Decoded message differs from the sent line")
+
+			_, err = protocol.DecodeScanner(scanner)
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+// TestParseMessage ensures known prefixes are split off and other lines kept whole
+func TestParseMessage(t *testing.T) {
+	assert.Equal(t, protocol.Message{Prefix: protocol.PrefixChallenge, Payload: "4:1a2b"}, protocol.ParseMessage("CHALLENGE:4:1a2b\n"))
+	assert.Equal(t, protocol.Message{Prefix: protocol.PrefixDone}, protocol.ParseMessage("DONE"))
+	assert.Equal(t, protocol.Message{Payload: "12345"}, protocol.ParseMessage("12345\n"))
+}
+
+// TestRejection ensures structured rejections round-trip and plain errors are not mistaken for them
+func TestRejection(t *testing.T) {
+	rejection := protocol.Rejection{Reason: protocol.RejectionRateLimited, Difficulty: 4, RetryAfterMS: 100}
+
+	line := protocol.FormatRejection(rejection)
+	assert.Equal(t, `ERROR:{"reason":"rate_limited","difficulty":4,"retry_after_ms":100}`, line)
+
+	msg := protocol.ParseMessage(line)
+	assert.Equal(t, protocol.PrefixError, msg.Prefix)
+
+	parsed, ok := protocol.ParseRejection(msg.Payload)
+	assert.True(t, ok)
+	assert.Equal(t, rejection, parsed)
+
+	_, ok = protocol.ParseRejection("Too many requests. Please try again later.")
+	assert.False(t, ok)
+}
+
+// TestFraming ensures the line protocol appends its line ending and the framed protocol does not
+func TestFraming(t *testing.T) {
+	var line bytes.Buffer
+	assert.NoError(t, protocol.LineFraming{}.WriteMessage(&line, protocol.PrefixQuote+"text"))
+	assert.Equal(t, protocol.PrefixQuote+"text\n", line.String())
+
+	var crlf bytes.Buffer
+	assert.NoError(t, protocol.LineFraming{Ending: protocol.CRLF}.WriteMessage(&crlf, protocol.PrefixQuote+"text"))
+	assert.Equal(t, protocol.PrefixQuote+"text\r\n", crlf.String())
+
+	var framed bytes.Buffer
+	assert.NoError(t, protocol.LengthPrefixedFraming{}.WriteMessage(&framed, protocol.PrefixQuote+"text"))
+	assert.Equal(t, []byte{0, 0, 0, 10}, framed.Bytes()[:4])
+	assert.Equal(t, protocol.PrefixQuote+"text", framed.String()[4:])
+
+	message, err := protocol.ReadFrame(&framed, protocol.MaxMessageSize)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.PrefixQuote+"text", message)
+
+	assert.NoError(t, protocol.LengthPrefixedFraming{}.WriteMessage(&framed, "too long"))
+	_, err = protocol.ReadFrame(&framed, 4)
+	assert.ErrorIs(t, err, protocol.ErrMessageTooLarge)
+}
+
+// TestHasReservedPrefix ensures texts starting with any message prefix are detected
+func TestHasReservedPrefix(t *testing.T) {
+	for _, prefix := range protocol.KnownPrefixes() {
+		assert.True(t, protocol.HasReservedPrefix(prefix+"text"), prefix)
+	}
+	assert.False(t, protocol.HasReservedPrefix("Plain quote"))
+	assert.False(t, protocol.HasReservedPrefix("Quote: not the prefix"))
+}
+
+// TestSplitLines ensures LF, CRLF and mixed line endings are split alike and empty lines dropped
+func TestSplitLines(t *testing.T) {
+	want := []string{"CHALLENGE:4:ab", "QUOTE:text"}
+
+	for name, data := range map[string]string{
+		"lf":       "CHALLENGE:4:ab\nQUOTE:text\n",
+		"crlf":     "CHALLENGE:4:ab\r\nQUOTE:text\r\n",
+		"mixed":    "CHALLENGE:4:ab\r\n\nQUOTE:text",
+		"trailing": "\r\nCHALLENGE:4:ab\n\r\nQUOTE:text\n\n",
+	} {
+		assert.Equal(t, want, protocol.SplitLines([]byte(data)), name)
+	}
+	assert.Empty(t, protocol.SplitLines(nil))
+}
+
+// TestScanner ensures lines are scanned with any line ending and oversized lines stop scanning
+func TestScanner(t *testing.T) {
+	for name, data := range map[string]string{
+		"lf":    "first\nsecond\n\nthird\n",
+		"crlf":  "first\r\nsecond\r\n\r\nthird\r\n",
+		"mixed": "first\r\nsecond\n\r\nthird\nunfinished",
+	} {
+		scanner := protocol.Scanner(strings.NewReader(data))
+
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		assert.NoError(t, scanner.Err(), name)
+		assert.Equal(t, []string{"first", "second", "", "third"}, lines, name)
+	}
+
+	scanner := protocol.ScannerWithLimit(strings.NewReader("short\r\n"+strings.Repeat("a", 11)+"\nnext\n"), 10)
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, "short", scanner.Text())
+	assert.False(t, scanner.Scan())
+	assert.ErrorIs(t, scanner.Err(), protocol.ErrMessageTooLarge)
+
+	// The limit excludes the line ending
+	scanner = protocol.ScannerWithLimit(strings.NewReader(strings.Repeat("a", 10)+"\r\n"), 10)
+	assert.True(t, scanner.Scan())
+	assert.NoError(t, scanner.Err())
+}
+
+// TestParseVersionLine ensures version lines are decoded with and without an instance ID
+func TestParseVersionLine(t *testing.T) {
+	version, instanceID, err := protocol.ParseVersionLine(protocol.FormatVersionLine(protocol.Version, "wow-2") + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.Version, version)
+	assert.Equal(t, "wow-2", instanceID)
+
+	// The instance ID is omitted when unknown
+	line := protocol.FormatVersionLine(protocol.Version, "")
+	assert.Equal(t, "VERSION 1", line)
+	version, instanceID, err = protocol.ParseVersionLine(line)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.Empty(t, instanceID)
+
+	// Unknown fields of later servers are skipped
+	version, instanceID, err = protocol.ParseVersionLine("VERSION 2 region=eu instance=wow-3")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, "wow-3", instanceID)
+
+	for _, invalid := range []string{"", "VERSION ", "VERSION x", "VERSION 0", "VERSION:1", "1 instance=wow-2", protocol.PrefixChallenge + "1"} {
+		_, _, err := protocol.ParseVersionLine(invalid)
+		assert.ErrorIs(t, err, protocol.ErrInvalidVersion, invalid)
+	}
+}
+
+// TestValidSeed ensures seeds are bounded to a safe character set
+func TestValidSeed(t *testing.T) {
+	for _, seed := range []string{"a", "game-42", "Player_1.round.2", strings.Repeat("x", protocol.MaxSeedLength)} {
+		assert.True(t, protocol.ValidSeed(seed), seed)
+	}
+	for _, seed := range []string{"", strings.Repeat("x", protocol.MaxSeedLength+1), "with space", "emoji😀", "semi;colon", "new\nline"} {
+		assert.False(t, protocol.ValidSeed(seed), seed)
+	}
+}
+
+// TestCommitChallenge ensures a commitment only verifies against the committed challenge
+func TestCommitChallenge(t *testing.T) {
+	commitment := protocol.CommitChallenge("4:1a2b3c")
+	assert.Len(t, commitment, 64)
+	assert.True(t, protocol.VerifyCommitment(commitment, "4:1a2b3c"))
+	assert.False(t, protocol.VerifyCommitment(commitment, "3:1a2b3c"))
+	assert.False(t, protocol.VerifyCommitment("", "4:1a2b3c"))
+
+	message := protocol.ParseMessage(protocol.PrefixCommitment + commitment)
+	assert.Equal(t, protocol.PrefixCommitment, message.Prefix, "COMMITMENT must not be parsed as COMMIT")
+}
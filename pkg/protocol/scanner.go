@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SplitLines splits data holding several messages, e.g. from a single read,
+// into lines without line endings. Empty lines are dropped.
+func SplitLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Scanner reads messages of at most MaxMessageSize bytes line by line
+func Scanner(r io.Reader) *bufio.Scanner {
+	return ScannerWithLimit(r, MaxMessageSize)
+}
+
+// ScannerWithLimit reads messages line by line, accepting LF and CRLF line
+// endings. Scanning stops with ErrMessageTooLarge as soon as a line exceeds
+// limit bytes without the line ending, so at most limit bytes are buffered.
+// A last line without line ending is unfinished and dropped.
+func ScannerWithLimit(r io.Reader, limit int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// Room for the longest line with CRLF, so the split function rejects it before the buffer is full
+	scanner.Buffer(make([]byte, 0, min(limit+2, bufio.MaxScanTokenSize)), limit+2)
+	scanner.Split(splitLines(limit))
+	return scanner
+}
+
+// splitLines is bufio.ScanLines with a line length limit, not returning unterminated lines at EOF
+func splitLines(limit int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if end := bytes.IndexByte(data, '\n'); end >= 0 {
+			line := bytes.TrimSuffix(data[:end], []byte("\r"))
+			if len(line) > limit {
+				return 0, nil, fmt.Errorf("%w: exceeds %d bytes", ErrMessageTooLarge, limit)
+			}
+			return end + 1, line, nil
+		}
+
+		if len(bytes.TrimSuffix(data, []byte("\r"))) > limit {
+			return 0, nil, fmt.Errorf("%w: exceeds %d bytes", ErrMessageTooLarge, limit)
+		}
+		return 0, nil, nil
+	}
+}
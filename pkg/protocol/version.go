@@ -0,0 +1,53 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the protocol version announced by the server
+const Version = 1
+
+// versionInstanceField names the server instance in the version line
+const versionInstanceField = "instance="
+
+var ErrInvalidVersion = errors.New("invalid version line")
+
+// FormatVersionLine encodes the version announcement, without the line
+// ending, e.g. "VERSION 1 instance=wow-2". An empty instanceID is omitted.
+func FormatVersionLine(version int, instanceID string) string {
+	line := PrefixVersion + strconv.Itoa(version)
+	if instanceID != "" {
+		line += " " + versionInstanceField + instanceID
+	}
+	return line
+}
+
+// ParseVersionLine decodes a version announcement. instanceID is empty when
+// the server does not name its instance. Unknown fields are ignored so later
+// servers may add more.
+func ParseVersionLine(line string) (version int, instanceID string, err error) {
+	payload, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), PrefixVersion)
+	if !ok {
+		return 0, "", fmt.Errorf("%w: missing %s prefix", ErrInvalidVersion, PrefixVersion)
+	}
+
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		return 0, "", fmt.Errorf("%w: missing version", ErrInvalidVersion)
+	}
+
+	version, err = strconv.Atoi(fields[0])
+	if err != nil || version < 1 {
+		return 0, "", fmt.Errorf("%w: bad version %q", ErrInvalidVersion, fields[0])
+	}
+
+	for _, field := range fields[1:] {
+		if id, ok := strings.CutPrefix(field, versionInstanceField); ok {
+			instanceID = id
+		}
+	}
+	return version, instanceID, nil
+}
@@ -0,0 +1,476 @@
+package wowclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"word-of-wisdom/pkg/protocol"
+)
+
+const defaultTimeout = 30 * time.Second
+
+const (
+	// MaxDialAttempts bounds the attempts of DialWithRetry
+	MaxDialAttempts = 10
+	// MinDialDelay is the shortest delay between attempts of DialWithRetry
+	MinDialDelay = 10 * time.Millisecond
+)
+
+var ErrUnexpectedResponse = errors.New("unexpected server response")
+
+// ErrCommitmentMismatch is returned when the revealed challenge does not match
+// the commitment the server sent before, see protocol.CommitChallenge
+var ErrCommitmentMismatch = errors.New("challenge does not match the commitment")
+
+// ProtocolError is returned when the server answers with an error message,
+// e.g. an invalid PoW solution or a rate limit rejection.
+type ProtocolError struct {
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return "server error: " + e.Message
+}
+
+type options struct {
+	timeout    time.Duration
+	collection string
+	seed       string
+	hello      bool
+	echo       bool
+	legacy     bool
+	retry      *retryPolicy
+	onVersion  func(version int, instanceID string)
+}
+
+// retryPolicy configures DialWithRetry for Fetch and Stream
+type retryPolicy struct {
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	jitter    bool
+}
+
+// Option configures Fetch and Stream
+type Option func(*options)
+
+// WithTimeout limits the whole exchange when the context has no deadline
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithCollection starts the exchange with a hello requesting the named quote
+// collection. The server must be configured with quote collections.
+func WithCollection(name string) Option {
+	return func(o *options) {
+		o.collection = name
+	}
+}
+
+// WithSeed starts the exchange with a hello carrying the seed, so clients
+// with the same seed get the same quote. The server must serve seeded quotes,
+// see protocol.ValidSeed for the allowed seeds. WithCollection takes precedence.
+func WithSeed(seed string) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// WithVersionHello starts the exchange with a hello announcing the protocol
+// version, telling servers serving legacy clients that the client understands
+// the advertised difficulty. WithCollection and WithSeed send a hello anyway.
+func WithVersionHello() Option {
+	return func(o *options) {
+		o.hello = true
+	}
+}
+
+// WithChallengeEcho sends every solution together with its challenge as
+// "challenge:solution". The server must require challenge echoes.
+func WithChallengeEcho() Option {
+	return func(o *options) {
+		o.echo = true
+	}
+}
+
+// WithLegacyHashing solves challenges the way servers predating
+// protocol.SolutionSeparator validate them
+func WithLegacyHashing() Option {
+	return func(o *options) {
+		o.legacy = true
+	}
+}
+
+// WithVersionHandler calls fn with the protocol version and the server
+// instance announced by the server, if it announces them. instanceID is
+// empty when the server does not name its instance.
+func WithVersionHandler(fn func(version int, instanceID string)) Option {
+	return func(o *options) {
+		o.onVersion = fn
+	}
+}
+
+// WithRetry retries dialing with exponential backoff until MaxDialAttempts
+// failed or the context is done, e.g. while the server is starting up. See
+// DialWithRetry.
+func WithRetry(baseDelay, maxDelay time.Duration, jitter bool) Option {
+	return func(o *options) {
+		o.retry = &retryPolicy{baseDelay: baseDelay, maxDelay: maxDelay, jitter: jitter}
+	}
+}
+
+// DialWithRetry dials the server until it succeeds, MaxDialAttempts failed or
+// the context is done. The delay between attempts starts at baseDelay, but no
+// less than MinDialDelay, and doubles up to maxDelay, randomized by ±25% when
+// jitter is set. If no attempt succeeds, the errors of all attempts are
+// returned.
+func DialWithRetry(ctx context.Context, addr string, baseDelay, maxDelay time.Duration, jitter bool) (net.Conn, error) {
+	var (
+		dialer net.Dialer
+		errs   []error
+	)
+
+	delay := max(baseDelay, MinDialDelay)
+	maxDelay = max(maxDelay, delay)
+	for attempt := 1; ; attempt++ {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if attempt == MaxDialAttempts {
+			return nil, errors.Join(errs...)
+		}
+
+		wait := delay
+		if jitter {
+			wait += time.Duration((rand.Float64()*0.5 - 0.25) * float64(delay))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(append(errs, ctx.Err())...)
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// session is a single client connection to the server
+type session struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	echo   bool
+	legacy bool
+	// difficulty is the one announced for the next challenge, zero if none
+	difficulty int
+	// commitment is the one sent for the next challenge, empty if none
+	commitment string
+	onVersion  func(version int, instanceID string)
+}
+
+// dial connects to the server, applying options to the context.
+// The returned func releases the connection and the context.
+func dial(ctx context.Context, addr string, opts []Option) (context.Context, *session, func(), error) {
+	o := options{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if o.retry != nil {
+		conn, err = DialWithRetry(ctx, addr, o.retry.baseDelay, o.retry.maxDelay, o.retry.jitter)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	// Unblock pending reads and writes when the context is done
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	closeFn := func() {
+		stop()
+		_ = conn.Close()
+		cancel()
+	}
+
+	hello := ""
+	switch {
+	case o.collection != "":
+		hello = protocol.PrefixCollection + o.collection
+	case o.seed != "":
+		hello = protocol.PrefixSeed + o.seed
+	case o.hello:
+		hello = protocol.FormatVersionLine(protocol.Version, "")
+	}
+	if hello != "" {
+		if _, err := fmt.Fprintln(conn, hello); err != nil {
+			closeFn()
+			return nil, nil, nil, fmt.Errorf("failed to send hello: %w", err)
+		}
+	}
+
+	return ctx, &session{conn: conn, reader: bufio.NewReader(conn), echo: o.echo, legacy: o.legacy, onVersion: o.onVersion}, closeFn, nil
+}
+
+// readChallenge reads the next challenge, handling the version, the
+// difficulty announced and the commitment sent before it, if any
+func (s *session) readChallenge() (string, error) {
+	for {
+		line, err := readLine(s.reader)
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case strings.HasPrefix(line, protocol.PrefixVersion):
+			if err := s.version(line); err != nil {
+				return "", err
+			}
+		case strings.HasPrefix(line, protocol.PrefixDifficulty):
+			if err := s.announce(strings.TrimPrefix(line, protocol.PrefixDifficulty)); err != nil {
+				return "", err
+			}
+		case strings.HasPrefix(line, protocol.PrefixCommitment):
+			if err := s.commit(strings.TrimPrefix(line, protocol.PrefixCommitment)); err != nil {
+				return "", err
+			}
+		default:
+			return parseMessage(line, protocol.PrefixChallenge)
+		}
+	}
+}
+
+// version reports the version announcement to the version handler, if any
+func (s *session) version(line string) error {
+	version, instanceID, err := protocol.ParseVersionLine(line)
+	if err != nil {
+		return err
+	}
+	if s.onVersion != nil {
+		s.onVersion(version, instanceID)
+	}
+	return nil
+}
+
+// announce records the difficulty announced for the next challenge
+func (s *session) announce(difficulty string) error {
+	n, err := strconv.Atoi(difficulty)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%w: invalid difficulty %q", ErrUnexpectedResponse, difficulty)
+	}
+	s.difficulty = n
+	return nil
+}
+
+// commit records the commitment to the next challenge and commits to solving
+// it, so the server reveals the challenge
+func (s *session) commit(commitment string) error {
+	s.commitment = commitment
+	if _, err := fmt.Fprintln(s.conn, protocol.PrefixCommit); err != nil {
+		return fmt.Errorf("failed to send commit: %w", err)
+	}
+	return nil
+}
+
+// ack acknowledges the received quotes after DONE, for servers requiring it.
+// Other servers may already have closed the connection, so errors are ignored.
+func (s *session) ack() {
+	_, _ = fmt.Fprintln(s.conn, protocol.PrefixAck)
+}
+
+// answer solves the challenge and sends the solution to the server. An
+// announced difficulty takes precedence over the one embedded in the challenge.
+// A challenge not matching the commitment sent before is not solved.
+func (s *session) answer(ctx context.Context, challenge string) error {
+	commitment := s.commitment
+	s.commitment = ""
+	if commitment != "" && !protocol.VerifyCommitment(commitment, challenge) {
+		return fmt.Errorf("%w: %q", ErrCommitmentMismatch, challenge)
+	}
+
+	difficulty := s.difficulty
+	s.difficulty = 0
+	if difficulty == 0 {
+		var err error
+		if difficulty, err = protocol.ChallengeDifficulty(challenge); err != nil {
+			return err
+		}
+	}
+
+	solution, err := solve(ctx, challenge, difficulty, s.legacy)
+	if err != nil {
+		return err
+	}
+
+	if s.echo {
+		solution = challenge + protocol.ChallengeSeparator + solution
+	}
+
+	if _, err := fmt.Fprintln(s.conn, solution); err != nil {
+		return fmt.Errorf("failed to send solution: %w", err)
+	}
+
+	return nil
+}
+
+// Fetch dials the server, solves its PoW challenge and returns the quote
+func Fetch(ctx context.Context, addr string, opts ...Option) (string, error) {
+	ctx, s, closeFn, err := dial(ctx, addr, opts)
+	if err != nil {
+		return "", err
+	}
+	defer closeFn()
+
+	challenge, err := s.readChallenge()
+	if err != nil {
+		return "", fmt.Errorf("failed to read challenge: %w", err)
+	}
+
+	if err := s.answer(ctx, challenge); err != nil {
+		return "", err
+	}
+
+	quote, err := readMessage(s.reader, protocol.PrefixQuote)
+	if err != nil {
+		return "", fmt.Errorf("failed to read quote: %w", err)
+	}
+
+	// Servers requiring an acknowledgement end with DONE, keep-alive servers
+	// go on with the next challenge, which is left unanswered
+	if line, err := readLine(s.reader); err == nil && line == protocol.PrefixDone {
+		s.ack()
+	}
+
+	return quote, nil
+}
+
+// Stream keeps a single connection open, solving every challenge the server
+// sends and calling onQuote for each quote received. It returns when the
+// server sends DONE or closes the connection, or the context is cancelled.
+func Stream(ctx context.Context, addr string, onQuote func(quote string), opts ...Option) error {
+	ctx, s, closeFn, err := dial(ctx, addr, opts)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	for {
+		line, err := readLine(s.reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, protocol.PrefixChallenge):
+			if err := s.answer(ctx, strings.TrimPrefix(line, protocol.PrefixChallenge)); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, protocol.PrefixVersion):
+			if err := s.version(line); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, protocol.PrefixDifficulty):
+			if err := s.announce(strings.TrimPrefix(line, protocol.PrefixDifficulty)); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, protocol.PrefixCommitment):
+			if err := s.commit(strings.TrimPrefix(line, protocol.PrefixCommitment)); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, protocol.PrefixQuote):
+			onQuote(strings.TrimPrefix(line, protocol.PrefixQuote))
+		case line == protocol.PrefixDone:
+			s.ack()
+			return nil
+		case strings.HasPrefix(line, protocol.PrefixError):
+			return &ProtocolError{Message: strings.TrimPrefix(line, protocol.PrefixError)}
+		default:
+			return fmt.Errorf("%w: %q", ErrUnexpectedResponse, line)
+		}
+	}
+}
+
+// Solve finds a nonce whose SHA-256 hash together with the challenge has the
+// required number of leading zeros, see protocol.SolutionHashInput
+func Solve(ctx context.Context, challenge string, difficulty int) (string, error) {
+	return solve(ctx, challenge, difficulty, false)
+}
+
+func solve(ctx context.Context, challenge string, difficulty int, legacy bool) (string, error) {
+	prefix := strings.Repeat("0", difficulty)
+	for nonce := int64(0); ; nonce++ {
+		// Check for cancellation periodically without slowing down hashing
+		if nonce%4096 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		solution := strconv.FormatInt(nonce, 10)
+		hash := sha256.Sum256(protocol.SolutionHashInput(challenge, solution, legacy))
+		if strings.HasPrefix(hex.EncodeToString(hash[:]), prefix) {
+			return solution, nil
+		}
+	}
+}
+
+// readLine reads a single line without the line ending
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readMessage reads a single line and strips the expected prefix.
+// Error messages are returned as *ProtocolError.
+func readMessage(reader *bufio.Reader, prefix string) (string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return "", err
+	}
+	return parseMessage(line, prefix)
+}
+
+// parseMessage strips the expected prefix from a line read from the server
+func parseMessage(line, prefix string) (string, error) {
+	switch {
+	case strings.HasPrefix(line, prefix):
+		return strings.TrimPrefix(line, prefix), nil
+	case strings.HasPrefix(line, protocol.PrefixError):
+		return "", &ProtocolError{Message: strings.TrimPrefix(line, protocol.PrefixError)}
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnexpectedResponse, line)
+	}
+}
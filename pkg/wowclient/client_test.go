@@ -0,0 +1,415 @@
+package wowclient_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+	"word-of-wisdom/internal/app"
+	"word-of-wisdom/internal/config"
+	"word-of-wisdom/internal/pow"
+	"word-of-wisdom/internal/quotes"
+	"word-of-wisdom/pkg/logger"
+	"word-of-wisdom/pkg/protocol"
+	"word-of-wisdom/pkg/wowclient"
+)
+
+const testQuote = "Do what you can, with what you have, where you are."
+
+// rejectingPoW issues real challenges but rejects every solution
+type rejectingPoW struct {
+	pow.PoW
+}
+
+func (rejectingPoW) ValidateChallenge(_, _ string) bool {
+	return false
+}
+
+// startServer runs a real server on an ephemeral port and returns its address
+func startServer(t *testing.T, rateLimit int, powChallenge pow.PoW, opts ...app.HandlerOption) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serve(t, listener, rateLimit, powChallenge, opts...)
+
+	return listener.Addr().String()
+}
+
+// serve runs a real server on the listener until the test ends
+func serve(t *testing.T, listener net.Listener, rateLimit int, powChallenge pow.PoW, opts ...app.HandlerOption) {
+	t.Helper()
+
+	cfg := config.Config{
+		MaxConnections:      10,
+		ConnectionTimeout:   5 * time.Second,
+		ShutdownTimeout:     time.Second,
+		RateLimitEvery100MS: rateLimit,
+	}
+
+	server := app.NewServer(cfg, logger.GetLogger(), app.NewHandler(
+		quotes.NewRandomQuoteProvider([]string{testQuote}),
+		powChallenge,
+		opts...,
+	))
+
+	go server.Serve(listener)
+	t.Cleanup(server.Shutdown)
+}
+
+// TestFetch ensures the full handshake returns a quote
+func TestFetch(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2))
+
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchInvalidPoW ensures a rejected solution is returned as a protocol error
+func TestFetchInvalidPoW(t *testing.T) {
+	addr := startServer(t, 5, rejectingPoW{pow.NewSHA256PoW(2)})
+
+	_, err := wowclient.Fetch(context.Background(), addr)
+
+	var protoErr *wowclient.ProtocolError
+	require.True(t, errors.As(err, &protoErr), "expected protocol error, got %v", err)
+	assert.Equal(t, app.InvalidMsg, protoErr.Message)
+}
+
+// TestFetchRateLimited ensures a rate limit rejection is returned as a protocol error
+func TestFetchRateLimited(t *testing.T) {
+	addr := startServer(t, 1, pow.NewSHA256PoW(2))
+
+	_, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+
+	_, err = wowclient.Fetch(context.Background(), addr)
+
+	var protoErr *wowclient.ProtocolError
+	require.True(t, errors.As(err, &protoErr), "expected protocol error, got %v", err)
+	assert.Contains(t, app.MsgOnManyReq, protoErr.Message)
+}
+
+// TestFetchCooldown ensures a client reconnecting right after a quote is told
+// to come back later, while the rate limiter would still admit it
+func TestFetchCooldown(t *testing.T) {
+	cooldown := 300 * time.Millisecond
+	addr := startServer(t, 10, pow.NewSHA256PoW(2), app.WithGrantCooldown(cooldown))
+
+	_, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	granted := time.Now()
+
+	_, err = wowclient.Fetch(context.Background(), addr)
+
+	var protoErr *wowclient.ProtocolError
+	require.True(t, errors.As(err, &protoErr), "expected protocol error, got %v", err)
+	assert.Equal(t, app.CooldownMsg, protoErr.Message)
+	require.Less(t, time.Since(granted), cooldown, "The second attempt should happen within the cooldown")
+
+	time.Sleep(cooldown - time.Since(granted))
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchCooldownConcurrent ensures concurrent connections of an IP get a
+// single grant, and connections ending without a quote do not start the cooldown
+func TestFetchCooldownConcurrent(t *testing.T) {
+	addr := startServer(t, 50, pow.NewSHA256PoW(2), app.WithGrantCooldown(time.Minute))
+
+	// A client leaving before solving releases its reservation
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	conn.Close()
+
+	const clients = 5
+	errs := make(chan error, clients)
+	require.Eventually(t, func() bool {
+		// Retried until the server noticed the client leaving
+		for range clients {
+			go func() {
+				_, err := wowclient.Fetch(context.Background(), addr)
+				errs <- err
+			}()
+		}
+
+		var served int
+		for range clients {
+			err := <-errs
+			if err == nil {
+				served++
+				continue
+			}
+			var protoErr *wowclient.ProtocolError
+			if assert.True(t, errors.As(err, &protoErr), "expected protocol error, got %v", err) {
+				assert.Equal(t, app.CooldownMsg, protoErr.Message)
+			}
+		}
+		assert.LessOrEqual(t, served, 1, "Concurrent connections should share a single grant")
+		return served == 1
+	}, time.Second, 50*time.Millisecond)
+}
+
+// TestSolveCancelled ensures solving stops when the context is cancelled
+func TestSolveCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := wowclient.Solve(ctx, "challenge", 64)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestStream ensures every quote of a keep-alive session is delivered
+func TestStream(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2), app.WithMaxRequestsPerConnection(3))
+
+	var received []string
+	err := wowclient.Stream(context.Background(), addr, func(quote string) {
+		received = append(received, quote)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testQuote, testQuote, testQuote}, received)
+}
+
+// TestStreamProgressiveDifficulty ensures the client solves at the difficulty announced for each round
+func TestStreamProgressiveDifficulty(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(1),
+		app.WithMaxRequestsPerConnection(3),
+		app.WithProgressiveDifficulty(1, 3, 1),
+	)
+
+	var received []string
+	err := wowclient.Stream(context.Background(), addr, func(quote string) {
+		received = append(received, quote)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testQuote, testQuote, testQuote}, received)
+
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchVersionAnnouncement ensures the announced server instance is reported
+func TestFetchVersionAnnouncement(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2), app.WithInstanceID("wow-2"))
+
+	var version int
+	var instanceID string
+	quote, err := wowclient.Fetch(context.Background(), addr, wowclient.WithVersionHandler(func(v int, id string) {
+		version, instanceID = v, id
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, "wow-2", instanceID)
+
+	// Clients without a version handler skip the announcement
+	quote, err = wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchSeed ensures clients with the same seed get the same quote
+func TestFetchSeed(t *testing.T) {
+	seeded := []protocol.QuoteMessage{{Text: "first"}, {Text: "second"}, {Text: "third"}}
+	addr := startServer(t, 10, pow.NewSHA256PoW(2), app.WithSeededQuotes(quotes.NewDeterministicProvider(seeded)))
+
+	first, err := wowclient.Fetch(context.Background(), addr, wowclient.WithSeed("game-42"))
+	require.NoError(t, err)
+	second, err := wowclient.Fetch(context.Background(), addr, wowclient.WithSeed("game-42"))
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, testQuote, first)
+
+	// Clients without a seed send no hello and get a quote of the default source
+	start := time.Now()
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+	assert.Less(t, time.Since(start), time.Second, "The server should not wait for the connection timeout")
+}
+
+// TestFetchAck ensures the client acknowledges quotes to servers requiring it
+func TestFetchAck(t *testing.T) {
+	addr := startServer(t, 10, pow.NewSHA256PoW(2), app.WithRequireAck(time.Second), app.WithMaxRequestsPerConnection(2))
+
+	start := time.Now()
+	var received []string
+	err := wowclient.Stream(context.Background(), addr, func(quote string) {
+		received = append(received, quote)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testQuote, testQuote}, received)
+
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+	assert.Less(t, time.Since(start), time.Second, "Acknowledged connections should not wait for the ack timeout")
+}
+
+// TestFetchChallengeCommitment ensures clients commit to challenges kept secret by the server
+func TestFetchChallengeCommitment(t *testing.T) {
+	addr := startServer(t, 10, pow.NewSHA256PoW(2), app.WithChallengeCommitment(),
+		app.WithMaxRequestsPerConnection(2), app.WithProgressiveDifficulty(1, 3, 1))
+
+	var received []string
+	err := wowclient.Stream(context.Background(), addr, func(quote string) {
+		received = append(received, quote)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testQuote, testQuote}, received)
+
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchCommitmentMismatch ensures a challenge not matching the commitment is not solved
+func TestFetchCommitmentMismatch(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = fmt.Fprintln(conn, protocol.PrefixCommitment+protocol.CommitChallenge("2:committed"))
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+		_, _ = fmt.Fprintln(conn, protocol.PrefixChallenge+"1:revealed")
+	}()
+
+	_, err = wowclient.Fetch(context.Background(), listener.Addr().String(), wowclient.WithTimeout(time.Second))
+	assert.ErrorIs(t, err, wowclient.ErrCommitmentMismatch)
+}
+
+// TestStreamSingleRequest ensures streaming ends cleanly against a single-request server
+func TestStreamSingleRequest(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2))
+
+	calls := 0
+	err := wowclient.Stream(context.Background(), addr, func(string) { calls++ })
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestFetchCollection ensures the requested collection is served
+func TestFetchCollection(t *testing.T) {
+	registry := quotes.NewRegistry(quotes.NewRandomQuoteProvider([]string{testQuote}))
+	registry.Register("stoicism", quotes.NewRandomQuoteProvider([]string{"Waste no more time arguing what a good man should be. Be one."}))
+
+	addr := startServer(t, 5, pow.NewSHA256PoW(2), app.WithQuoteCollections(registry))
+
+	quote, err := wowclient.Fetch(context.Background(), addr, wowclient.WithCollection("stoicism"))
+	require.NoError(t, err)
+	assert.Equal(t, "Waste no more time arguing what a good man should be. Be one.", quote)
+}
+
+// TestFetchSaltedChallenge ensures clients solve salted challenges without knowing the salt
+func TestFetchSaltedChallenge(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2, pow.WithSalt("deployment-a")))
+
+	quote, err := wowclient.Fetch(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestFetchChallengeEcho ensures echoing clients are served and non-echoing ones rejected
+func TestFetchChallengeEcho(t *testing.T) {
+	addr := startServer(t, 5, pow.NewSHA256PoW(2), app.WithChallengeEcho())
+
+	quote, err := wowclient.Fetch(context.Background(), addr, wowclient.WithChallengeEcho())
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+
+	_, err = wowclient.Fetch(context.Background(), addr)
+
+	var protoErr *wowclient.ProtocolError
+	require.True(t, errors.As(err, &protoErr), "expected protocol error, got %v", err)
+	assert.Equal(t, app.ChallengeMismatchMsg, protoErr.Message)
+}
+
+// TestFetchRetry ensures the client keeps dialing until a late server starts
+func TestFetchRetry(t *testing.T) {
+	// Reserve a free port and release it for the late server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(300 * time.Millisecond)
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("Failed to start late server: %v", err)
+			return
+		}
+		serve(t, listener, 5, pow.NewSHA256PoW(2))
+	}()
+
+	quote, err := wowclient.Fetch(context.Background(), addr, wowclient.WithRetry(20*time.Millisecond, 100*time.Millisecond, true))
+	<-started
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+}
+
+// TestDialWithRetryGivesUp ensures the errors of all attempts are returned once the context is done
+func TestDialWithRetryGivesUp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = wowclient.DialWithRetry(ctx, addr, 10*time.Millisecond, 20*time.Millisecond, false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var opErr *net.OpError
+	assert.True(t, errors.As(err, &opErr), "expected dial errors, got %v", err)
+}
+
+// TestDialWithRetryMaxAttempts ensures dialing gives up after MaxDialAttempts even without a delay or deadline
+func TestDialWithRetryMaxAttempts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	start := time.Now()
+	_, err = wowclient.DialWithRetry(context.Background(), addr, 0, 0, false)
+	require.Error(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), (wowclient.MaxDialAttempts-1)*wowclient.MinDialDelay)
+
+	var joined interface{ Unwrap() []error }
+	require.True(t, errors.As(err, &joined), "expected joined errors, got %v", err)
+	assert.Len(t, joined.Unwrap(), wowclient.MaxDialAttempts)
+}
+
+// TestFetchVersionHello ensures clients announcing the version get the advertised difficulty from servers serving legacy clients
+func TestFetchVersionHello(t *testing.T) {
+	addr := startServer(t, 10, pow.NewSHA256PoW(2, pow.WithCompatDifficulty(1)), app.WithLegacyClients(time.Second))
+
+	start := time.Now()
+	quote, err := wowclient.Fetch(context.Background(), addr, wowclient.WithVersionHello())
+	require.NoError(t, err)
+	assert.Equal(t, testQuote, quote)
+	assert.Less(t, time.Since(start), time.Second, "The hello should spare the client the legacy wait")
+}
@@ -0,0 +1,65 @@
+#!/usr/bin/env bash
+# Integration test for zero-downtime restarts: keeps clients connecting while
+# the server is upgraded with SIGUSR2 and fails if any connection is dropped.
+set -euo pipefail
+
+PORT=9000
+CLIENTS=${CLIENTS:-40}
+WORKDIR=$(mktemp -d)
+BIN="$WORKDIR/server"
+PID_FILE="$WORKDIR/server.pid"
+
+cleanup() {
+	if [[ -f "$PID_FILE" ]]; then
+		kill "$(cat "$PID_FILE")" 2>/dev/null || true
+	fi
+	rm -rf "$WORKDIR"
+}
+trap cleanup EXIT
+
+go build -o "$BIN" ./cmd/server
+"$BIN" --pid-file "$PID_FILE" >"$WORKDIR/server.log" 2>&1 &
+
+for _ in $(seq 1 50); do
+	[[ -s "$PID_FILE" ]] && break
+	sleep 0.1
+done
+OLD_PID=$(cat "$PID_FILE")
+
+# request_challenge connects to the server and expects a challenge line
+request_challenge() {
+	local line
+	exec 3<>"/dev/tcp/127.0.0.1/$PORT" || return 1
+	read -r -t 2 line <&3 || true
+	exec 3<&-
+	[[ "$line" == CHALLENGE:* ]]
+}
+
+failed=0
+for i in $(seq 1 "$CLIENTS"); do
+	if [[ "$i" -eq $((CLIENTS / 2)) ]]; then
+		echo "Sending SIGUSR2 to $OLD_PID"
+		kill -USR2 "$OLD_PID"
+	fi
+
+	if ! request_challenge; then
+		echo "Client $i: connection dropped"
+		failed=$((failed + 1))
+	fi
+
+	# Stay under the per-IP rate limit
+	sleep 0.15
+done
+
+NEW_PID=$(cat "$PID_FILE")
+if [[ "$NEW_PID" == "$OLD_PID" ]]; then
+	echo "FAIL: server was not upgraded (pid $OLD_PID)"
+	exit 1
+fi
+
+if [[ "$failed" -ne 0 ]]; then
+	echo "FAIL: $failed of $CLIENTS connections dropped"
+	exit 1
+fi
+
+echo "OK: upgraded $OLD_PID -> $NEW_PID without dropping $CLIENTS connections"